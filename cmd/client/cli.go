@@ -0,0 +1,296 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"minecraft-server-manager/internal/cli"
+	"minecraft-server-manager/internal/server"
+)
+
+// defaultAddr is where subcommands find the manager unless -addr or MSM_ADDR say otherwise
+const defaultAddr = "http://localhost:8080"
+
+// subcommands talk to a running manager instead of starting one
+var subcommands = map[string]struct {
+	usage  string
+	run    func(ctx context.Context, client *cli.Client, args []string, opts outputOptions) error
+	json   bool // Takes -json
+	follow bool // Takes -f
+}{
+	"status":  {"status [server]", runStatus, true, false},
+	"logs":    {"logs <server> [-f]", runLogs, true, true},
+	"console": {"console <server>", runConsole, false, false},
+	"restart": {"restart <server>", runRestart, true, false},
+	"backup":  {"backup <server>", runBackup, true, false},
+	"reload":  {"reload", runReload, true, false},
+}
+
+// outputOptions are the subcommand flags that change what is printed
+type outputOptions struct {
+	json   bool
+	follow bool
+}
+
+var errUsage = errors.New("usage")
+
+func isSubcommand(arg string) bool {
+	_, ok := subcommands[arg]
+	return ok || arg == "help"
+}
+
+// runSubcommand runs an operator subcommand and returns the exit code
+func runSubcommand(args []string) int {
+	command, ok := subcommands[args[0]]
+	if !ok {
+		printSubcommandUsage()
+		return 0
+	}
+
+	flags := flag.NewFlagSet(args[0], flag.ContinueOnError)
+	addr := flags.String("addr", envOr("MSM_ADDR", defaultAddr), "Manager address: http://host:port, https://host:port or unix:///path/to/socket (env MSM_ADDR)")
+	token := flags.String("token", os.Getenv("MSM_TOKEN"), "API token (env MSM_TOKEN)")
+	caFile := flags.String("ca", os.Getenv("MSM_CA"), "CA certificate to verify an HTTPS manager with (env MSM_CA)")
+	certFile := flags.String("cert", "", "Client certificate for a manager that requires one")
+	keyFile := flags.String("key", "", "Key of the client certificate")
+	insecure := flags.Bool("insecure", false, "Do not verify the manager's certificate")
+	var opts outputOptions
+	if command.json {
+		flags.BoolVar(&opts.json, "json", false, "Print JSON instead of tables")
+	}
+	if command.follow {
+		flags.BoolVar(&opts.follow, "f", false, "Keep printing new lines")
+	}
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s %s [flags]\n", os.Args[0], command.usage)
+		flags.PrintDefaults()
+	}
+	positional, err := parseInterspersed(flags, args[1:])
+	if err != nil {
+		return 2
+	}
+
+	client, err := cli.New(cli.Options{
+		Addr:     *addr,
+		Token:    *token,
+		CAFile:   *caFile,
+		CertFile: *certFile,
+		KeyFile:  *keyFile,
+		Insecure: *insecure,
+	})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	if err := command.run(ctx, client, positional, opts); err != nil {
+		if err == errUsage {
+			flags.Usage()
+			return 2
+		}
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		if cli.IsStatus(err, http.StatusUnauthorized) {
+			fmt.Fprintln(os.Stderr, "Set MSM_TOKEN or pass -token")
+		}
+		return 1
+	}
+	return 0
+}
+
+// parseInterspersed parses flags both before and after the positional arguments,
+// as in "logs survival -f", which the flag package alone stops short of
+func parseInterspersed(flags *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := flags.Parse(args); err != nil {
+			return nil, err
+		}
+		args = flags.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+func printSubcommandUsage() {
+	fmt.Fprintf(os.Stderr, "Usage: %s <command> [flags]\n\nCommands for a running manager:\n", os.Args[0])
+	for _, name := range []string{"status", "logs", "console", "restart", "backup", "reload"} {
+		fmt.Fprintf(os.Stderr, "  %s\n", subcommands[name].usage)
+	}
+	fmt.Fprintf(os.Stderr, "\nRun '%s <command> -h' for its flags, or '%s -h' for the manager's own.\n", os.Args[0], os.Args[0])
+}
+
+func envOr(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
+
+// serverArg returns the single server name a subcommand takes
+func serverArg(args []string) (string, error) {
+	if len(args) != 1 || args[0] == "" {
+		return "", errUsage
+	}
+	return args[0], nil
+}
+
+func runStatus(ctx context.Context, client *cli.Client, args []string, opts outputOptions) error {
+	if len(args) > 1 {
+		return errUsage
+	}
+	if len(args) == 1 {
+		status, err := client.Server(ctx, args[0])
+		if err != nil {
+			return err
+		}
+		if opts.json {
+			return cli.PrintJSON(os.Stdout, status)
+		}
+		return cli.PrintServer(os.Stdout, status)
+	}
+
+	status, err := client.Status(ctx)
+	if err != nil {
+		return err
+	}
+	servers, err := client.Servers(ctx)
+	if err != nil {
+		return err
+	}
+	if opts.json {
+		status.Servers = servers
+		return cli.PrintJSON(os.Stdout, status)
+	}
+	return cli.PrintStatus(os.Stdout, status, servers)
+}
+
+func runLogs(ctx context.Context, client *cli.Client, args []string, opts outputOptions) error {
+	name, err := serverArg(args)
+	if err != nil {
+		return err
+	}
+
+	if !opts.follow {
+		lines, err := client.Logs(ctx, name)
+		if err != nil {
+			return err
+		}
+		if opts.json {
+			return cli.PrintJSON(os.Stdout, lines)
+		}
+		for _, line := range lines {
+			fmt.Println(line)
+		}
+		return nil
+	}
+	return client.FollowLogs(ctx, name, func(line string) {
+		if opts.json {
+			cli.PrintJSON(os.Stdout, line)
+		} else {
+			fmt.Println(line)
+		}
+	})
+}
+
+// runConsole attaches to a server's console: its output is printed while each
+// line typed on stdin is sent as a command, until stdin ends, "exit" is typed or
+// the server stops
+func runConsole(ctx context.Context, client *cli.Client, args []string, _ outputOptions) error {
+	name, err := serverArg(args)
+	if err != nil {
+		return err
+	}
+
+	input, commands := io.Pipe()
+	go func() {
+		scanner := bufio.NewScanner(os.Stdin)
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if line == "exit" {
+				break
+			}
+			if _, err := fmt.Fprintln(commands, line); err != nil {
+				return
+			}
+		}
+		commands.Close()
+	}()
+
+	fmt.Fprintf(os.Stderr, "Attaching to %s. Type commands, or exit to leave.\n", name)
+	err = client.Attach(ctx, name, input, func(line string) {
+		if strings.HasPrefix(line, "! ") {
+			fmt.Fprintln(os.Stderr, strings.TrimPrefix(line, "! "))
+		} else {
+			fmt.Println(line)
+		}
+	})
+	input.Close()
+	return err
+}
+
+func runRestart(ctx context.Context, client *cli.Client, args []string, opts outputOptions) error {
+	name, err := serverArg(args)
+	if err != nil {
+		return err
+	}
+	op, err := client.Restart(ctx, name)
+	if err != nil {
+		return err
+	}
+	if opts.json {
+		cli.PrintJSON(os.Stdout, op)
+	}
+	if op.Status == server.OperationFailed {
+		return errors.New(op.Error)
+	}
+	if !opts.json {
+		cli.PrintOperation(os.Stdout, op)
+	}
+	return nil
+}
+
+func runBackup(ctx context.Context, client *cli.Client, args []string, opts outputOptions) error {
+	name, err := serverArg(args)
+	if err != nil {
+		return err
+	}
+	info, err := client.Backup(ctx, name)
+	if err != nil {
+		return err
+	}
+	if opts.json {
+		return cli.PrintJSON(os.Stdout, info)
+	}
+	cli.PrintBackup(os.Stdout, info)
+	return nil
+}
+
+func runReload(ctx context.Context, client *cli.Client, args []string, opts outputOptions) error {
+	if len(args) != 0 {
+		return errUsage
+	}
+	report, err := client.Reload(ctx)
+	if err != nil {
+		return err
+	}
+	if opts.json {
+		return cli.PrintJSON(os.Stdout, report)
+	}
+	cli.PrintReload(os.Stdout, report)
+	return nil
+}
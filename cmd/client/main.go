@@ -1,28 +1,60 @@
 package main
 
 import (
+	"bufio"
 	"context"
-	"encoding/json"
 	"flag"
 	"fmt"
 	"net/http"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
 	"syscall"
 	"time"
+	_ "time/tzdata" // Schedules need timezone data even on images without it
 
+	"minecraft-server-manager/internal/api"
+	"minecraft-server-manager/internal/archive"
+	"minecraft-server-manager/internal/auth"
+	"minecraft-server-manager/internal/backup"
+	"minecraft-server-manager/internal/certs"
 	"minecraft-server-manager/internal/config"
 	"minecraft-server-manager/internal/github"
+	"minecraft-server-manager/internal/installer"
+	"minecraft-server-manager/internal/layers"
 	"minecraft-server-manager/internal/server"
 
 	"github.com/sirupsen/logrus"
 )
 
+// bedrockArchive is the archive the manager splits into layers on start
+const bedrockArchive = "versions/bedrock-server.zip"
+
 func main() {
+	// Subcommands talk to a running manager
+	if len(os.Args) > 1 && isSubcommand(os.Args[1]) {
+		os.Exit(runSubcommand(os.Args[1:]))
+	}
+
 	// Parse command line flags
 	firstRun := flag.Bool("first-run", false, "Enable first run mode (ignores missing SHA files)")
+	verifyBackups := flag.Bool("verify-backups", false, "Re-hash every backup chunk, report problems and exit")
+	installBedrock := flag.String("install-bedrock", "", "Download a Bedrock version from bedrock_mirror into the versions store and exit")
+	bedrockSHA256 := flag.String("bedrock-sha256", "", "Pinned SHA-256 of the -install-bedrock zip")
+	splitBedrock := flag.Bool("split-bedrock", false, "Split versions/bedrock-server.zip into layers with a manifest and exit")
+	checkLayers := flag.Bool("check-layers", false, "Verify the Bedrock layers against their manifest, report problems and exit")
+	extractBedrock := flag.Bool("extract-bedrock", false, "Extract versions/bedrock-server-recombined.zip into versions/bedrock-server-extracted and exit")
+	hashToken := flag.Bool("hash-token", false, "Print the hash to configure for an API token read from stdin, or for a new random token, and exit")
+	overrides := config.RegisterFlags(flag.CommandLine)
 	flag.Parse()
 
+	if *hashToken {
+		os.Exit(runHashToken())
+	}
+
 	// Initialize logger
 	logger := logrus.New()
 	logger.SetFormatter(&logrus.TextFormatter{
@@ -30,49 +62,148 @@ func main() {
 	})
 
 	// Load configuration
-	cfg, err := config.Load()
+	cfg, err := config.LoadWithOverrides(overrides)
 	if err != nil {
 		logger.Fatalf("Failed to load configuration: %v", err)
 	}
 
+	if err := server.ConfigureLogger(logger, cfg.Log); err != nil {
+		logger.Fatalf("Failed to configure logging: %v", err)
+	}
+
+	if *verifyBackups {
+		os.Exit(runVerifyBackups(cfg, logger))
+	}
+	if *installBedrock != "" {
+		os.Exit(runInstallBedrock(cfg, logger, *installBedrock, *bedrockSHA256))
+	}
+	if *splitBedrock {
+		os.Exit(runSplitBedrock(cfg, logger))
+	}
+	if *checkLayers {
+		os.Exit(runCheckLayers(logger))
+	}
+	if *extractBedrock {
+		os.Exit(runExtractBedrock(logger))
+	}
+
 	// Set first run flag from command line
-	cfg.Server.FirstRun = *firstRun
 	if *firstRun {
+		cfg.Server.FirstRun = true
+	}
+	if cfg.Server.FirstRun {
 		logger.Info("First run mode enabled - will handle missing SHA files gracefully")
 	}
 
+	if _, err := os.Stat(config.BranchFile); err == nil {
+		logger.Warnf("The %s file is deprecated and will be removed; select the environment with an overlay instead (see README)", config.BranchFile)
+	}
+
 	// Log which branch is being used
 	logger.Infof("Using branch '%s' for configuration", cfg.GitHub.Branch)
 
-	// Create GitHub client for public repository
+	// Create GitHub client (authenticated when a token is configured)
 	githubClient := github.NewClient(cfg.GitHub.RepoOwner, cfg.GitHub.RepoName)
+	githubClient.SetToken(cfg.GitHub.Token)
 
 	// Create server manager
 	serverManager := server.NewManager(cfg, logger)
 
-	// Create HTTP server for health checks and status
-	mux := http.NewServeMux()
-	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
-		w.WriteHeader(http.StatusOK)
-		w.Write([]byte("OK"))
-	})
-	mux.HandleFunc("/status", func(w http.ResponseWriter, r *http.Request) {
-		status := serverManager.GetStatus()
-		json.NewEncoder(w).Encode(status)
-	})
+	// API tokens; without any only anonymous access, if allowed, gets through
+	authenticator, err := auth.New(cfg.HTTP)
+	if err != nil {
+		logger.Fatalf("Failed to load API tokens: %v", err)
+	}
+	switch role := authenticator.AnonymousRole(); {
+	case role >= auth.Operator:
+		logger.Warnf("Anonymous requests are allowed as %s, anyone who can reach the HTTP API can control the servers", role)
+	case role != auth.None:
+		logger.Infof("Anonymous requests are allowed as %s", role)
+	case !authenticator.Enabled():
+		logger.Warn("No API tokens configured, the HTTP API refuses every request; set http.tokens or http.allow_anonymous")
+	}
+
+	// Reload re-reads config.yaml and the tokens and applies what can change without a restart
+	var reloadMu sync.Mutex
+	tokens := cfg.HTTP.Tokens
+	reload := func() (server.ReloadReport, error) {
+		reloadMu.Lock()
+		defer reloadMu.Unlock()
+
+		newCfg, err := config.LoadWithOverrides(overrides)
+		if err != nil {
+			return server.ReloadReport{}, err
+		}
+		if *firstRun {
+			newCfg.Server.FirstRun = true
+		}
+		// Check the tokens first so a bad tokens file leaves everything unchanged
+		if _, err := auth.New(newCfg.HTTP); err != nil {
+			return server.ReloadReport{}, fmt.Errorf("failed to load API tokens: %w", err)
+		}
+		report, err := serverManager.Reload(newCfg)
+		if err != nil {
+			return report, err
+		}
+		if err := authenticator.Load(newCfg.HTTP); err != nil {
+			return report, err
+		}
+		// The token list is not a plain setting, so the manager cannot see it change
+		if !reflect.DeepEqual(tokens, newCfg.HTTP.Tokens) {
+			tokens = newCfg.HTTP.Tokens
+			report.Applied = append(report.Applied, "http.tokens")
+		}
+		return report, nil
+	}
+
+	// Create HTTP server for health checks, status and administration
+	apiServer := api.New(serverManager, logger, reload, authenticator)
+
+	tlsConfig, err := certs.ServerConfig(cfg.HTTP.TLS, cfg.Server.BaseDir, logger)
+	if err != nil {
+		logger.Fatalf("Failed to configure TLS: %v", err)
+	}
 
 	httpServer := &http.Server{
-		Addr:    fmt.Sprintf(":%d", cfg.HTTP.Port),
-		Handler: mux,
+		Addr:      fmt.Sprintf(":%d", cfg.HTTP.Port),
+		Handler:   apiServer.Handler(),
+		TLSConfig: tlsConfig,
 	}
 
 	// Start HTTP server
-	go func() {
-		logger.Infof("Starting HTTP server on port %d", cfg.HTTP.Port)
-		if err := httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			logger.Errorf("HTTP server error: %v", err)
+	if !cfg.HTTP.Socket.DisableTCP {
+		go func() {
+			var err error
+			if tlsConfig != nil {
+				logger.Infof("Starting HTTPS server on port %d", cfg.HTTP.Port)
+				// The certificate comes from TLSConfig.GetCertificate
+				err = httpServer.ListenAndServeTLS("", "")
+			} else {
+				logger.Infof("Starting HTTP server on port %d", cfg.HTTP.Port)
+				err = httpServer.ListenAndServe()
+			}
+			if err != nil && err != http.ErrServerClosed {
+				logger.Errorf("HTTP server error: %v", err)
+			}
+		}()
+	} else if cfg.HTTP.Socket.Path == "" {
+		logger.Fatal("http.socket.disable_tcp needs http.socket.path")
+	}
+
+	// Serve the same API on the admin socket, where its permissions replace tokens
+	socketServer := apiServer.SocketServer()
+	if cfg.HTTP.Socket.Path != "" {
+		listener, err := api.ListenSocket(cfg.HTTP.Socket)
+		if err != nil {
+			logger.Fatalf("Failed to open admin socket: %v", err)
 		}
-	}()
+		go func() {
+			logger.Infof("Serving the API on %s", cfg.HTTP.Socket.Path)
+			if err := socketServer.Serve(listener); err != nil && err != http.ErrServerClosed {
+				logger.Errorf("Admin socket error: %v", err)
+			}
+		}()
+	}
 
 	// Create context for graceful shutdown
 	ctx, cancel := context.WithCancel(context.Background())
@@ -82,6 +213,19 @@ func main() {
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
 
+	// Reload configuration on SIGHUP
+	hupChan := make(chan os.Signal, 1)
+	signal.Notify(hupChan, syscall.SIGHUP)
+
+	go func() {
+		for range hupChan {
+			logger.Info("Received SIGHUP, reloading configuration...")
+			if _, err := reload(); err != nil {
+				logger.Errorf("Failed to reload configuration: %v", err)
+			}
+		}
+	}()
+
 	go func() {
 		<-sigChan
 		logger.Info("Received shutdown signal, stopping servers...")
@@ -91,8 +235,120 @@ func main() {
 		shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 30*time.Second)
 		defer shutdownCancel()
 		httpServer.Shutdown(shutdownCtx)
+		socketServer.Shutdown(shutdownCtx)
 	}()
 
 	// Start the main polling loop
 	serverManager.Start(ctx, githubClient)
 }
+
+// runVerifyBackups checks every chunk in the backup store and returns the exit code
+func runVerifyBackups(cfg *config.Config, logger *logrus.Logger) int {
+	logger.Infof("Verifying backups in %s", cfg.Server.BackupDir)
+
+	result, err := backup.NewStore(cfg.Server.BackupDir).Verify()
+	if err != nil {
+		logger.Errorf("Failed to verify backups: %v", err)
+		return 1
+	}
+
+	for _, hash := range result.Corrupt {
+		logger.Errorf("Corrupt chunk: %s", hash)
+	}
+	for _, missing := range result.Missing {
+		logger.Errorf("Missing chunk: %s", missing)
+	}
+	if !result.OK() {
+		logger.Errorf("Verified %d chunks: %d corrupt, %d missing", result.Chunks, len(result.Corrupt), len(result.Missing))
+		return 1
+	}
+
+	logger.Infof("Verified %d chunks, all intact", result.Chunks)
+	return 0
+}
+
+// runInstallBedrock installs one Bedrock version and returns the exit code
+func runInstallBedrock(cfg *config.Config, logger *logrus.Logger, version, sha256 string) int {
+	inst := installer.New(cfg.Server.BedrockMirror, cfg.Server.VersionsDir, logger)
+	if inst.Installed(version) {
+		logger.Infof("Bedrock %s is already installed at %s", version, inst.ExecutablePath(version))
+		return 0
+	}
+	if err := inst.Install(context.Background(), version, sha256); err != nil {
+		logger.Errorf("Failed to install Bedrock %s: %v", version, err)
+		return 1
+	}
+	return 0
+}
+
+// runSplitBedrock splits the Bedrock archive into layers, ready to be published to
+// a mirror or the configuration repository, and returns the exit code
+func runSplitBedrock(cfg *config.Config, logger *logrus.Logger) int {
+	manifest, err := layers.Split(bedrockArchive, "versions", int64(cfg.Server.Layers.ChunkSizeMB)<<20)
+	if err != nil {
+		logger.Errorf("Failed to split %s: %v", bedrockArchive, err)
+		return 1
+	}
+	logger.Infof("Split %s into %d layers, manifest %s", bedrockArchive, len(manifest.Chunks), layers.ManifestPath("versions", manifest.File))
+	return 0
+}
+
+// runCheckLayers reports which Bedrock layers are missing or corrupt and returns
+// the exit code
+func runCheckLayers(logger *logrus.Logger) int {
+	manifest, err := layers.ReadManifest(layers.ManifestPath("versions", bedrockArchive))
+	if err != nil {
+		logger.Errorf("Failed to read layers manifest: %v", err)
+		return 1
+	}
+	if err := layers.Check("versions", manifest); err != nil {
+		logger.Errorf("Bedrock %v", err)
+		return 1
+	}
+	logger.Infof("All %d layers of %s are intact", len(manifest.Chunks), manifest.File)
+	return 0
+}
+
+// runExtractBedrock extracts the recombined Bedrock archive without relying on
+// unzip, which the image does not have, and returns the exit code
+func runExtractBedrock(logger *logrus.Logger) int {
+	source := filepath.Join("versions", "bedrock-server-recombined.zip")
+	target := filepath.Join("versions", "bedrock-server-extracted")
+	err := archive.Extract(source, target, archive.Options{
+		Progress: archive.Steps(10, func(done, total int64) {
+			logger.Infof("Extracting %s: %d%%", source, done*100/total)
+		}),
+		Replace: true,
+	})
+	if err != nil {
+		logger.Errorf("Failed to extract %s: %v", source, err)
+		return 1
+	}
+	executable := filepath.Join(target, "bedrock_server")
+	if err := os.Chmod(executable, 0755); err != nil {
+		logger.Errorf("Extracted %s, but no usable bedrock_server: %v", source, err)
+		return 1
+	}
+	logger.Infof("Extracted %s, executable %s", source, executable)
+	return 0
+}
+
+// runHashToken prints the hash of the token on stdin, generating a token when
+// stdin is empty, and returns the exit code
+func runHashToken() int {
+	scanner := bufio.NewScanner(os.Stdin)
+	secret := ""
+	if scanner.Scan() {
+		secret = strings.TrimSpace(scanner.Text())
+	}
+	if secret == "" {
+		var err error
+		if secret, err = auth.NewToken(); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to generate token: %v\n", err)
+			return 1
+		}
+		fmt.Printf("token: %s\n", secret)
+	}
+	fmt.Printf("hash: %s\n", auth.Hash(secret))
+	return 0
+}
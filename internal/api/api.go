@@ -0,0 +1,467 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"strings"
+	"time"
+
+	"minecraft-server-manager/internal/auth"
+	"minecraft-server-manager/internal/backup"
+	"minecraft-server-manager/internal/server"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// maxUploadSize bounds an uploaded .mcworld
+	maxUploadSize = 2 << 30
+
+	// logsKeepalive is how often an idle log stream is written to, so proxies keep it open
+	logsKeepalive = 30 * time.Second
+)
+
+// ReloadFunc re-reads the local configuration and applies it to the manager
+type ReloadFunc func() (server.ReloadReport, error)
+
+// Server serves the manager's HTTP API
+type Server struct {
+	manager *server.Manager
+	logger  *logrus.Logger
+	reload  ReloadFunc
+	auth    *auth.Authenticator
+}
+
+func New(manager *server.Manager, logger *logrus.Logger, reload ReloadFunc, authenticator *auth.Authenticator) *Server {
+	return &Server{
+		manager: manager,
+		logger:  logger,
+		reload:  reload,
+		auth:    authenticator,
+	}
+}
+
+// Handler returns the HTTP handler with every route registered behind authentication
+func (s *Server) Handler() http.Handler {
+	return s.authenticate(s.routes())
+}
+
+// routes registers every route, without authentication
+func (s *Server) routes() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", s.handleRoot)
+	mux.Handle("/dashboard/", dashboardHandler())
+	mux.HandleFunc("/health", s.handleHealth)
+	mux.HandleFunc("/status", s.handleStatus)
+	mux.HandleFunc("/admin/reload", s.handleReload)
+	mux.HandleFunc("/versions", s.handleVersions)
+	mux.HandleFunc("/versions/report", s.handleUpgradeReport)
+	mux.HandleFunc("/servers", s.handleListServers)
+	mux.HandleFunc("/servers/", s.handleServers)
+	mux.HandleFunc("/operations/", s.handleOperation)
+	return mux
+}
+
+// authenticate checks the caller's token against the role the route requires and
+// stores the caller's identity in the request context
+func (s *Server) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		required := requiredRole(r.Method, r.URL.Path)
+		if required == auth.None {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		identity, err := s.auth.Authenticate(r)
+		if err != nil {
+			s.logger.Warnf("Rejected %s %s from %s: %v", r.Method, r.URL.Path, r.RemoteAddr, err)
+			w.Header().Set("WWW-Authenticate", `Bearer realm="minecraft-server-manager"`)
+			writeError(w, http.StatusUnauthorized, err)
+			return
+		}
+		if identity.Role < required {
+			s.logger.Warnf("Denied %s %s to %s (%s, needs %s)", r.Method, r.URL.Path, identity.Name, identity.Role, required)
+			writeError(w, http.StatusForbidden, fmt.Errorf("%s role required", required))
+			return
+		}
+		if r.Method != http.MethodGet {
+			s.logger.Infof("%s %s by %s (%s)", r.Method, r.URL.Path, identity.Name, identity.Role)
+		}
+		next.ServeHTTP(w, r.WithContext(auth.WithIdentity(r.Context(), identity)))
+	})
+}
+
+// requiredRole returns the role needed for a request: viewers may read status,
+// operators may act on servers and their worlds, and only admins may reload
+// configuration
+func requiredRole(method, path string) auth.Role {
+	switch {
+	case path == "/health" || path == "/" || strings.HasPrefix(path, "/dashboard/"):
+		// The dashboard's files hold no data; it asks for a token itself
+		return auth.None
+	case strings.HasPrefix(path, "/admin/"):
+		return auth.Admin
+	case strings.HasPrefix(path, "/servers/") && (strings.Contains(path, "/backups") || strings.Contains(path, "/world/")):
+		// Backups and exports hold the whole world
+		return auth.Operator
+	case method == http.MethodGet || method == http.MethodHead:
+		return auth.Viewer
+	default:
+		return auth.Operator
+	}
+}
+
+// handleRoot sends browsers to the dashboard
+func (s *Server) handleRoot(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		writeError(w, http.StatusNotFound, errors.New("not found"))
+		return
+	}
+	http.Redirect(w, r, "/dashboard/", http.StatusFound)
+}
+
+func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("OK"))
+}
+
+func (s *Server) handleStatus(w http.ResponseWriter, r *http.Request) {
+	status := s.manager.GetStatus()
+	// Paths on the host are only for admins
+	if identity, _ := auth.FromContext(r.Context()); identity.Role < auth.Admin {
+		status.BedrockPath = ""
+	}
+	writeJSON(w, http.StatusOK, status)
+}
+
+func (s *Server) handleReload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+		return
+	}
+
+	report, err := s.reload()
+	if err != nil {
+		s.logger.Errorf("Failed to reload configuration: %v", err)
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, report)
+}
+
+func (s *Server) handleVersions(w http.ResponseWriter, r *http.Request) {
+	versions, err := s.manager.Versions()
+	if err != nil {
+		writeManagerError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, versions)
+}
+
+func (s *Server) handleUpgradeReport(w http.ResponseWriter, r *http.Request) {
+	report, err := s.manager.UpgradeReport()
+	if err != nil {
+		writeManagerError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, report)
+}
+
+func (s *Server) handleListServers(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+		return
+	}
+	writeJSON(w, http.StatusOK, s.manager.ListServers())
+}
+
+// handleServers routes /servers/{name}/... requests
+func (s *Server) handleServers(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/servers/"), "/"), "/")
+	if parts[0] == "" {
+		writeError(w, http.StatusNotFound, errors.New("not found"))
+		return
+	}
+	name := parts[0]
+
+	switch {
+	case len(parts) == 1 && r.Method == http.MethodGet:
+		s.handleGetServer(w, r, name)
+	case len(parts) == 2 && r.Method == http.MethodPost && (parts[1] == "start" || parts[1] == "stop" || parts[1] == "restart"):
+		s.handleLifecycle(w, r, name, parts[1])
+	case len(parts) == 2 && parts[1] == "logs" && r.Method == http.MethodGet:
+		s.handleLogs(w, r, name)
+	case len(parts) == 2 && parts[1] == "console" && r.Method == http.MethodGet:
+		s.handleConsoleSocket(w, r, name)
+	case len(parts) == 2 && parts[1] == "console" && r.Method == http.MethodPost:
+		s.handleConsole(w, r, name)
+	case len(parts) == 3 && parts[1] == "console" && parts[2] == "attach" && r.Method == http.MethodPost:
+		s.handleAttachConsole(w, r, name)
+	case len(parts) == 2 && parts[1] == "override" && r.Method == http.MethodDelete:
+		s.handleClearOverride(w, r, name)
+	case len(parts) == 2 && parts[1] == "backups" && r.Method == http.MethodGet:
+		s.handleListBackups(w, r, name)
+	case len(parts) == 2 && parts[1] == "backups" && r.Method == http.MethodPost:
+		s.handleCreateBackup(w, r, name)
+	case len(parts) == 4 && parts[1] == "backups" && parts[3] == "restore" && r.Method == http.MethodPost:
+		s.handleRestoreBackup(w, r, name, parts[2])
+	case len(parts) == 3 && parts[1] == "world" && parts[2] == "export" && r.Method == http.MethodGet:
+		s.handleExportWorld(w, r, name)
+	case len(parts) == 3 && parts[1] == "world" && parts[2] == "import" && r.Method == http.MethodPost:
+		s.handleImportWorld(w, r, name)
+	default:
+		writeError(w, http.StatusNotFound, errors.New("not found"))
+	}
+}
+
+func (s *Server) handleGetServer(w http.ResponseWriter, r *http.Request, name string) {
+	status, err := s.manager.GetServer(name)
+	if err != nil {
+		writeManagerError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, status)
+}
+
+// handleLifecycle starts, stops or restarts a server. The action runs in the
+// background; the response is the operation to poll at /operations/{id}.
+func (s *Server) handleLifecycle(w http.ResponseWriter, r *http.Request, name, action string) {
+	by := caller(r)
+
+	var op *server.Operation
+	var err error
+	switch action {
+	case "start":
+		op, err = s.manager.StartServer(name, by)
+	case "stop":
+		op, err = s.manager.StopServer(name, by)
+	default:
+		op, err = s.manager.RestartServer(name, by)
+	}
+	if err != nil {
+		writeManagerError(w, err)
+		return
+	}
+	w.Header().Set("Location", "/operations/"+op.ID)
+	writeJSON(w, http.StatusAccepted, op)
+}
+
+// handleLogs returns a server's recent console output, or with ?follow=true
+// streams it as server-sent events until the client goes away
+func (s *Server) handleLogs(w http.ResponseWriter, r *http.Request, name string) {
+	if r.URL.Query().Get("follow") != "true" {
+		lines, err := s.manager.Logs(name)
+		if err != nil {
+			writeManagerError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string][]string{"lines": lines})
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, errors.New("streaming not supported"))
+		return
+	}
+	recent, lines, stop, err := s.manager.FollowLogs(name)
+	if err != nil {
+		writeManagerError(w, err)
+		return
+	}
+	defer stop()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	for _, line := range recent {
+		fmt.Fprintf(w, "data: %s\n\n", line)
+	}
+	flusher.Flush()
+
+	keepalive := time.NewTicker(logsKeepalive)
+	defer keepalive.Stop()
+	for {
+		select {
+		case line, ok := <-lines:
+			if !ok {
+				fmt.Fprint(w, "event: exit\ndata: server stopped\n\n")
+				flusher.Flush()
+				return
+			}
+			fmt.Fprintf(w, "data: %s\n\n", line)
+		case <-keepalive.C:
+			fmt.Fprint(w, ": keepalive\n\n")
+		case <-r.Context().Done():
+			return
+		}
+		flusher.Flush()
+	}
+}
+
+// handleConsole sends a command to a server's console
+func (s *Server) handleConsole(w http.ResponseWriter, r *http.Request, name string) {
+	var body struct {
+		Command string `json:"command"`
+	}
+	if err := json.NewDecoder(io.LimitReader(r.Body, 4096)).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid request: %w", err))
+		return
+	}
+	if err := s.manager.RunCommand(name, body.Command, caller(r)); err != nil {
+		writeManagerError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleClearOverride(w http.ResponseWriter, r *http.Request, name string) {
+	if err := s.manager.ClearOverride(name, caller(r)); err != nil {
+		writeManagerError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleOperation(w http.ResponseWriter, r *http.Request) {
+	id := strings.Trim(strings.TrimPrefix(r.URL.Path, "/operations/"), "/")
+	if r.Method != http.MethodGet || id == "" || strings.Contains(id, "/") {
+		writeError(w, http.StatusNotFound, errors.New("not found"))
+		return
+	}
+
+	op, err := s.manager.Operation(id)
+	if err != nil {
+		writeManagerError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, op)
+}
+
+func (s *Server) handleCreateBackup(w http.ResponseWriter, r *http.Request, name string) {
+	info, err := s.manager.Backup(name)
+	if err != nil {
+		writeManagerError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, info)
+}
+
+func (s *Server) handleListBackups(w http.ResponseWriter, r *http.Request, name string) {
+	backups, err := s.manager.ListBackups(name, fromRemote(r))
+	if err != nil {
+		writeManagerError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, backups)
+}
+
+func (s *Server) handleRestoreBackup(w http.ResponseWriter, r *http.Request, name, id string) {
+	result, err := s.manager.RestoreBackup(name, id, fromRemote(r))
+	if err != nil {
+		writeManagerError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+func (s *Server) handleExportWorld(w http.ResponseWriter, r *http.Request, name string) {
+	// Headers are only sent with the first byte, so an early error can still replace them
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name+".mcworld"))
+
+	if err := s.manager.ExportWorld(name, w); err != nil {
+		s.logger.Errorf("Failed to export world of %s: %v", name, err)
+		w.Header().Del("Content-Disposition")
+		writeManagerError(w, err)
+	}
+}
+
+// handleImportWorld accepts a .mcworld either as the raw request body or as the
+// "world" file of a multipart form
+func (s *Server) handleImportWorld(w http.ResponseWriter, r *http.Request, name string) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxUploadSize)
+
+	upload := io.Reader(r.Body)
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		reader, err := r.MultipartReader()
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		part, err := findPart(reader, "world")
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		defer part.Close()
+		upload = part
+	}
+
+	result, err := s.manager.ImportWorld(name, upload)
+	if err != nil {
+		writeManagerError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+func findPart(reader *multipart.Reader, field string) (*multipart.Part, error) {
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			return nil, fmt.Errorf("form has no %q file", field)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if part.FormName() == field {
+			return part, nil
+		}
+		part.Close()
+	}
+}
+
+// caller identifies who made a request, for logs and overrides
+func caller(r *http.Request) string {
+	if identity, ok := auth.FromContext(r.Context()); ok {
+		return identity.Name
+	}
+	return r.RemoteAddr
+}
+
+// fromRemote reports whether a request asks for the backup remote with ?source=remote
+func fromRemote(r *http.Request) bool {
+	return r.URL.Query().Get("source") == "remote"
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
+
+// writeManagerError maps manager errors to HTTP status codes
+func writeManagerError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, server.ErrUnknownServer), errors.Is(err, backup.ErrNotFound), errors.Is(err, server.ErrNoUpgradeReport),
+		errors.Is(err, server.ErrUnknownOperation):
+		writeError(w, http.StatusNotFound, err)
+	case errors.Is(err, server.ErrBusy), errors.Is(err, server.ErrNotRunning):
+		writeError(w, http.StatusConflict, err)
+	case errors.Is(err, server.ErrNoRemote), errors.Is(err, backup.ErrInvalidWorld):
+		writeError(w, http.StatusBadRequest, err)
+	case errors.As(err, new(*http.MaxBytesError)):
+		writeError(w, http.StatusRequestEntityTooLarge, err)
+	default:
+		writeError(w, http.StatusInternalServerError, err)
+	}
+}
@@ -0,0 +1,78 @@
+package api
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"sync"
+)
+
+// handleAttachConsole streams a server's console both ways over one request: each
+// line of the request body is sent as a command while the response carries the
+// server's output, until the body ends, the client goes away or the server stops.
+func (s *Server) handleAttachConsole(w http.ResponseWriter, r *http.Request, name string) {
+	session, err := s.manager.AttachConsole(name, caller(r))
+	if err != nil {
+		writeManagerError(w, err)
+		return
+	}
+	defer session.Close()
+
+	// HTTP/1 servers stop reading the body once the response starts unless told otherwise
+	controller := http.NewResponseController(w)
+	controller.EnableFullDuplex()
+
+	// The input goroutine may still write an error after the handler has returned
+	var mu sync.Mutex
+	done := false
+	write := func(line string) {
+		mu.Lock()
+		defer mu.Unlock()
+		if !done {
+			fmt.Fprintln(w, line)
+			controller.Flush()
+		}
+	}
+	defer func() {
+		mu.Lock()
+		done = true
+		mu.Unlock()
+	}()
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	for _, line := range session.Recent {
+		fmt.Fprintln(w, line)
+	}
+	controller.Flush()
+
+	inputDone := make(chan struct{})
+	go func() {
+		defer close(inputDone)
+		scanner := bufio.NewScanner(r.Body)
+		for scanner.Scan() {
+			if scanner.Text() == "" {
+				continue
+			}
+			if err := session.Send(scanner.Text()); err != nil {
+				write("! " + err.Error())
+			}
+		}
+	}()
+
+	for {
+		select {
+		case line, ok := <-session.Lines:
+			if !ok {
+				write("! server stopped")
+				return
+			}
+			write(line)
+		case <-inputDone:
+			return
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
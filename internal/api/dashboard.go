@@ -0,0 +1,20 @@
+package api
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+)
+
+//go:embed dashboard
+var dashboardFiles embed.FS
+
+// dashboardHandler serves the single-page dashboard under /dashboard/. The page
+// itself calls the API with the token it asks for, so it needs no role.
+func dashboardHandler() http.Handler {
+	files, err := fs.Sub(dashboardFiles, "dashboard")
+	if err != nil {
+		panic(err)
+	}
+	return http.StripPrefix("/dashboard/", http.FileServer(http.FS(files)))
+}
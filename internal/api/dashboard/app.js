@@ -0,0 +1,296 @@
+// Dashboard for the manager's HTTP API. The token is kept in this browser only
+// and sent as a bearer token with every request, or as a subprotocol of the
+// console's WebSocket.
+"use strict";
+
+const tokenKey = "msm-token";
+const refreshInterval = 5000;
+const maxLogLines = 1000;
+
+let selected = null;
+let consoleSocket = null;
+
+const $ = (id) => document.getElementById(id);
+
+function token() {
+  return localStorage.getItem(tokenKey) || "";
+}
+
+async function api(method, path, body) {
+  const headers = {};
+  if (token()) {
+    headers.Authorization = "Bearer " + token();
+  }
+  if (body !== undefined) {
+    headers["Content-Type"] = "application/json";
+  }
+  const response = await fetch(path, {
+    method,
+    headers,
+    body: body === undefined ? undefined : JSON.stringify(body),
+  });
+  if (response.status === 401) {
+    showLogin();
+    throw new Error("Please sign in");
+  }
+  if (!response.ok) {
+    const error = await response.json().catch(() => ({}));
+    throw new Error(error.error || response.statusText);
+  }
+  return response.status === 204 ? null : response.json();
+}
+
+function showMessage(text, isError) {
+  const message = $("message");
+  message.textContent = text;
+  message.className = isError ? "error" : "";
+  message.hidden = !text;
+}
+
+function showLogin() {
+  $("login").hidden = false;
+  $("main").hidden = true;
+  $("signout").hidden = true;
+}
+
+function showMain() {
+  $("login").hidden = true;
+  $("main").hidden = false;
+  $("signout").hidden = !token();
+}
+
+async function refresh() {
+  try {
+    const [status, servers] = await Promise.all([api("GET", "/status"), api("GET", "/servers")]);
+    showMain();
+    renderSummary(status);
+    renderServers(servers);
+  } catch (error) {
+    showMessage(error.message, true);
+  }
+}
+
+function renderSummary(status) {
+  const parts = [status.running + " running", status.stopped + " stopped"];
+  if (status.config_commit) {
+    parts.push("config " + status.config_commit.slice(0, 8));
+  }
+  parts.push("updated " + new Date(status.last_update).toLocaleTimeString());
+  $("summary").textContent = parts.join(" · ");
+}
+
+function renderServers(servers) {
+  const body = $("servers").querySelector("tbody");
+  body.replaceChildren();
+
+  for (const server of servers) {
+    const row = document.createElement("tr");
+    row.className = server.name === selected ? "selected" : "";
+    row.addEventListener("click", () => select(server.name));
+
+    const name = cell(server.name);
+    if (server.override) {
+      const override = document.createElement("div");
+      override.className = "override";
+      override.textContent = "Manually " + server.override.state + " by " + (server.override.by || "unknown");
+      name.append(override);
+    }
+
+    const state = cell(server.status);
+    state.classList.add("state", server.status);
+
+    const running = server.status === "running" || server.status === "starting";
+    row.append(
+      name,
+      state,
+      cell(String(server.player_count || 0)),
+      cell(server.detected_version || server.version || ""),
+      cell(running ? formatUptime(server.uptime) : ""),
+      actions(server, running),
+    );
+    body.append(row);
+
+    if (server.name === selected) {
+      renderPlayers(server.players || []);
+      renderViewers(server.console_viewers || []);
+    }
+  }
+}
+
+function cell(text) {
+  const td = document.createElement("td");
+  td.textContent = text;
+  return td;
+}
+
+function actions(server, running) {
+  const td = document.createElement("td");
+  td.className = "actions";
+  td.append(
+    button(running ? "Stop" : "Start", () => lifecycle(server.name, running ? "stop" : "start")),
+    button("Restart", () => lifecycle(server.name, "restart"), !running),
+    button("Back up", () => backup(server.name), false, true),
+  );
+  return td;
+}
+
+function button(label, onClick, disabled, secondary) {
+  const b = document.createElement("button");
+  b.textContent = label;
+  b.disabled = !!disabled;
+  if (secondary) {
+    b.className = "secondary";
+  }
+  b.addEventListener("click", (event) => {
+    event.stopPropagation();
+    onClick();
+  });
+  return b;
+}
+
+// formatUptime shortens Go durations such as "2h30m15.123456s" to "2h30m"
+function formatUptime(uptime) {
+  const match = /^(?:(\d+)h)?(?:(\d+)m)?/.exec(uptime || "");
+  if (!match || (!match[1] && !match[2])) {
+    return "just now";
+  }
+  return (match[1] ? match[1] + "h" : "") + (match[2] ? match[2] + "m" : "");
+}
+
+function renderPlayers(players) {
+  $("players").textContent = players.length
+    ? "Online: " + players.map((p) => p.name).join(", ")
+    : "Nobody online";
+}
+
+function renderViewers(viewers) {
+  $("viewers").textContent = viewers.length ? "Console open: " + viewers.join(", ") : "";
+}
+
+async function lifecycle(name, action) {
+  try {
+    showMessage(action[0].toUpperCase() + action.slice(1) + " of " + name + " requested…");
+    const op = await api("POST", "/servers/" + encodeURIComponent(name) + "/" + action);
+    await waitFor(op);
+    showMessage("");
+  } catch (error) {
+    showMessage(error.message, true);
+  }
+  refresh();
+}
+
+async function waitFor(op) {
+  while (op.status === "running") {
+    await new Promise((resolve) => setTimeout(resolve, 1000));
+    op = await api("GET", "/operations/" + op.id);
+  }
+  if (op.status === "failed") {
+    throw new Error(op.action + " of " + op.server + " failed: " + op.error);
+  }
+}
+
+async function backup(name) {
+  try {
+    showMessage("Backing up " + name + "…");
+    const info = await api("POST", "/servers/" + encodeURIComponent(name) + "/backups");
+    showMessage("Backup " + info.id + " of " + name + " created");
+  } catch (error) {
+    showMessage(error.message, true);
+  }
+}
+
+function select(name) {
+  selected = name;
+  $("detail").hidden = false;
+  $("detail-name").textContent = name;
+  $("logs").textContent = "";
+  openConsole(name);
+  refresh();
+}
+
+// openConsole connects to the server's console, which everyone watching it shares
+function openConsole(name) {
+  closeConsole();
+  const scheme = location.protocol === "https:" ? "wss:" : "ws:";
+  const protocols = ["msm.console"];
+  if (token()) {
+    protocols.push("bearer." + token());
+  }
+  const socket = new WebSocket(scheme + "//" + location.host + "/servers/" + encodeURIComponent(name) + "/console", protocols);
+  consoleSocket = socket;
+
+  socket.addEventListener("message", (event) => {
+    const message = JSON.parse(event.data);
+    if (message.error) {
+      showMessage(message.error, true);
+    } else {
+      appendLog(message.line || "");
+    }
+  });
+  socket.addEventListener("close", (event) => {
+    if (socket !== consoleSocket) {
+      return;
+    }
+    consoleSocket = null;
+    appendLog("(" + (event.reason || "console closed") + ")");
+    refresh();
+  });
+}
+
+function closeConsole() {
+  if (consoleSocket) {
+    const socket = consoleSocket;
+    consoleSocket = null;
+    socket.close();
+  }
+}
+
+function appendLog(line) {
+  const logs = $("logs");
+  const atBottom = logs.scrollTop + logs.clientHeight >= logs.scrollHeight - 4;
+  logs.append(line + "\n");
+  while (logs.childNodes.length > maxLogLines) {
+    logs.removeChild(logs.firstChild);
+  }
+  if (atBottom) {
+    logs.scrollTop = logs.scrollHeight;
+  }
+}
+
+$("console-form").addEventListener("submit", (event) => {
+  event.preventDefault();
+  const command = $("command").value.trim();
+  if (!command) {
+    return;
+  }
+  if (!consoleSocket || consoleSocket.readyState !== WebSocket.OPEN) {
+    showMessage("The console is not connected", true);
+    return;
+  }
+  consoleSocket.send(JSON.stringify({ command }));
+  $("command").value = "";
+  showMessage("");
+});
+
+$("login-form").addEventListener("submit", (event) => {
+  event.preventDefault();
+  localStorage.setItem(tokenKey, $("token").value.trim());
+  $("token").value = "";
+  showMessage("");
+  refresh();
+});
+
+$("signout").addEventListener("click", () => {
+  localStorage.removeItem(tokenKey);
+  closeConsole();
+  selected = null;
+  $("detail").hidden = true;
+  showLogin();
+});
+
+refresh();
+setInterval(() => {
+  if (!$("main").hidden) {
+    refresh();
+  }
+}, refreshInterval);
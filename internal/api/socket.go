@@ -0,0 +1,110 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+
+	"minecraft-server-manager/internal/auth"
+	"minecraft-server-manager/internal/config"
+)
+
+// peerKey carries the identity of a socket connection's peer to its requests
+type peerKey struct{}
+
+// ListenSocket creates the admin socket with the configured owner group and
+// permissions, replacing one left behind by a previous run
+func ListenSocket(cfg config.SocketConfig) (net.Listener, error) {
+	mode, err := strconv.ParseUint(cfg.Mode, 8, 32)
+	if err != nil {
+		return nil, fmt.Errorf("invalid socket mode %q: %w", cfg.Mode, err)
+	}
+	gid := -1
+	if cfg.Group != "" {
+		group, err := user.LookupGroup(cfg.Group)
+		if err != nil {
+			return nil, fmt.Errorf("failed to look up socket group: %w", err)
+		}
+		gid, _ = strconv.Atoi(group.Gid)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(cfg.Path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create %s: %w", filepath.Dir(cfg.Path), err)
+	}
+	// A stale socket refuses connections; anything else at the path is left alone
+	if info, err := os.Lstat(cfg.Path); err == nil {
+		if info.Mode()&os.ModeSocket == 0 {
+			return nil, fmt.Errorf("%s exists and is not a socket", cfg.Path)
+		}
+		if conn, err := net.Dial("unix", cfg.Path); err == nil {
+			conn.Close()
+			return nil, fmt.Errorf("%s is in use by another process", cfg.Path)
+		}
+		if err := os.Remove(cfg.Path); err != nil {
+			return nil, fmt.Errorf("failed to remove stale socket: %w", err)
+		}
+	}
+
+	listener, err := net.Listen("unix", cfg.Path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %w", cfg.Path, err)
+	}
+	// The group goes first so the mode never applies to the wrong group
+	if gid >= 0 {
+		if err := os.Chown(cfg.Path, -1, gid); err != nil {
+			listener.Close()
+			return nil, fmt.Errorf("failed to set socket group: %w", err)
+		}
+	}
+	if err := os.Chmod(cfg.Path, os.FileMode(mode)); err != nil {
+		listener.Close()
+		return nil, fmt.Errorf("failed to set socket mode: %w", err)
+	}
+	return listener, nil
+}
+
+// SocketServer returns the HTTP server for the admin socket. It serves the same
+// routes as the TCP port, with the socket's permissions in place of tokens.
+func (s *Server) SocketServer() *http.Server {
+	return &http.Server{
+		Handler: s.socketAuthenticate(s.routes()),
+		ConnContext: func(ctx context.Context, conn net.Conn) context.Context {
+			return context.WithValue(ctx, peerKey{}, socketIdentity(conn))
+		},
+	}
+}
+
+// socketIdentity names the local user on the other end of a socket connection.
+// Anyone the socket's permissions let in is an admin.
+func socketIdentity(conn net.Conn) auth.Identity {
+	uid, ok := peerUID(conn)
+	if !ok {
+		return auth.Identity{Name: "unix", Role: auth.Admin}
+	}
+	name := "uid " + strconv.Itoa(uid)
+	if u, err := user.LookupId(strconv.Itoa(uid)); err == nil {
+		name = u.Username
+	}
+	return auth.Identity{Name: "unix:" + name, Role: auth.Admin}
+}
+
+// socketAuthenticate stores the socket peer's identity in the request context
+func (s *Server) socketAuthenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		identity, ok := r.Context().Value(peerKey{}).(auth.Identity)
+		if !ok {
+			writeError(w, http.StatusForbidden, errors.New("unknown socket peer"))
+			return
+		}
+		if r.Method != http.MethodGet {
+			s.logger.Infof("%s %s by %s (socket)", r.Method, r.URL.Path, identity.Name)
+		}
+		next.ServeHTTP(w, r.WithContext(auth.WithIdentity(r.Context(), identity)))
+	})
+}
@@ -0,0 +1,62 @@
+package api
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"minecraft-server-manager/internal/auth"
+	"minecraft-server-manager/internal/config"
+	"minecraft-server-manager/internal/server"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestSocket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "run", "admin.sock")
+	cfg := config.SocketConfig{Path: path, Mode: "0600"}
+
+	// A socket left behind by a previous run is replaced
+	os.MkdirAll(filepath.Dir(path), 0755)
+	stale, err := net.Listen("unix", path)
+	if err != nil {
+		t.Skipf("Unix sockets unavailable: %v", err)
+	}
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	stale.Close()
+
+	listener, err := ListenSocket(cfg)
+	if err != nil {
+		t.Fatalf("ListenSocket failed: %v", err)
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("Expected mode 0600, got %v (%v)", info.Mode(), err)
+	}
+	if _, err := ListenSocket(cfg); err == nil {
+		t.Error("Expected a socket in use to be refused")
+	}
+
+	// Tokens are configured, but the socket needs none
+	authenticator, _ := auth.New(config.HTTPConfig{Tokens: []config.TokenConfig{{Name: "ci", Role: "viewer", Hash: auth.Hash("x")}}})
+	manager := server.NewManager(&config.Config{Server: config.ServerConfig{BaseDir: t.TempDir()}}, logrus.New())
+	api := New(manager, logrus.New(), nil, authenticator).SocketServer()
+	go api.Serve(listener)
+	defer api.Close()
+
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", path)
+		},
+	}}
+	resp, err := client.Get("http://manager/servers")
+	if err != nil {
+		t.Fatalf("Request over the socket failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected 200 over the socket, got %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,122 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"minecraft-server-manager/internal/auth"
+
+	"github.com/gorilla/websocket"
+)
+
+// consoleProtocol is the WebSocket subprotocol of the console
+const consoleProtocol = "msm.console"
+
+const (
+	consolePingInterval = 30 * time.Second
+	consoleReadTimeout  = 2 * consolePingInterval
+	consoleWriteTimeout = 10 * time.Second
+)
+
+var upgrader = websocket.Upgrader{
+	Subprotocols: []string{consoleProtocol},
+}
+
+// consoleMessage is a WebSocket console message: output and errors are sent to
+// the client, commands are received from it
+type consoleMessage struct {
+	Line    string `json:"line,omitempty"`
+	Error   string `json:"error,omitempty"`
+	Command string `json:"command,omitempty"`
+}
+
+// handleConsoleSocket attaches a WebSocket to a server's console. Every viewer
+// sees the same output, including the commands others send; only operators may
+// send commands.
+func (s *Server) handleConsoleSocket(w http.ResponseWriter, r *http.Request, name string) {
+	if !websocket.IsWebSocketUpgrade(r) {
+		writeError(w, http.StatusBadRequest, errors.New("WebSocket upgrade required"))
+		return
+	}
+	identity, _ := auth.FromContext(r.Context())
+	session, err := s.manager.AttachConsole(name, caller(r))
+	if err != nil {
+		writeManagerError(w, err)
+		return
+	}
+	defer session.Close()
+
+	// The upgrader has already written an error response on failure
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	// Only this goroutine writes; the reader hands it errors to report
+	outgoing := make(chan consoleMessage, 16)
+	readDone := make(chan struct{})
+	go func() {
+		defer close(readDone)
+		conn.SetReadLimit(4096)
+		conn.SetReadDeadline(time.Now().Add(consoleReadTimeout))
+		conn.SetPongHandler(func(string) error {
+			return conn.SetReadDeadline(time.Now().Add(consoleReadTimeout))
+		})
+		for {
+			var msg consoleMessage
+			if err := conn.ReadJSON(&msg); err != nil {
+				return
+			}
+			if msg.Command == "" {
+				continue
+			}
+			var err error
+			if identity.Role < auth.Operator {
+				err = errors.New("operator role required to send commands")
+			} else {
+				err = session.Send(msg.Command)
+			}
+			if err != nil {
+				select {
+				case outgoing <- consoleMessage{Error: err.Error()}:
+				default:
+				}
+			}
+		}
+	}()
+
+	send := func(msg consoleMessage) error {
+		conn.SetWriteDeadline(time.Now().Add(consoleWriteTimeout))
+		return conn.WriteJSON(msg)
+	}
+	for _, line := range session.Recent {
+		if send(consoleMessage{Line: line}) != nil {
+			return
+		}
+	}
+
+	ping := time.NewTicker(consolePingInterval)
+	defer ping.Stop()
+	for {
+		var err error
+		select {
+		case line, ok := <-session.Lines:
+			if !ok {
+				conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, "server stopped"), time.Now().Add(consoleWriteTimeout))
+				return
+			}
+			err = send(consoleMessage{Line: line})
+		case msg := <-outgoing:
+			err = send(msg)
+		case <-ping.C:
+			err = conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(consoleWriteTimeout))
+		case <-readDone:
+			return
+		}
+		if err != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,434 @@
+// Package archive extracts zip and tar (plain, gzip or zstd) archives without
+// external tools, refusing entries that would land outside the target directory.
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Format is an archive format, detected from the archive's first bytes
+type Format string
+
+const (
+	Zip     Format = "zip"
+	Tar     Format = "tar"
+	TarGzip Format = "tar.gz"
+	TarZstd Format = "tar.zst"
+)
+
+var (
+	// ErrUnknownFormat is returned for a file that is none of the supported formats
+	ErrUnknownFormat = errors.New("unknown archive format")
+
+	// ErrUnsafePath is returned for an entry or link that would leave the target
+	ErrUnsafePath = errors.New("unsafe path in archive")
+)
+
+// Progress is called after each entry with the work done so far out of total.
+// Zip archives count unpacked bytes; tar archives count bytes of the archive read.
+type Progress func(done, total int64)
+
+type Options struct {
+	Progress Progress
+
+	// Verify is called on the unpacked tree before it is moved into place
+	Verify func(dir string) error
+
+	// Replace allows an existing destination to be replaced; otherwise Extract
+	// fails with fs.ErrExist
+	Replace bool
+}
+
+// Detect returns the format of an archive from its magic bytes
+func Detect(r io.ReaderAt) (Format, error) {
+	header := make([]byte, 512)
+	n, err := r.ReadAt(header, 0)
+	if err != nil && err != io.EOF {
+		return "", err
+	}
+	header = header[:n]
+
+	switch {
+	case bytes.HasPrefix(header, []byte("PK\x03\x04")), bytes.HasPrefix(header, []byte("PK\x05\x06")):
+		return Zip, nil
+	case bytes.HasPrefix(header, []byte{0x1f, 0x8b}):
+		return TarGzip, nil
+	case bytes.HasPrefix(header, []byte{0x28, 0xb5, 0x2f, 0xfd}):
+		return TarZstd, nil
+	case len(header) >= 262 && string(header[257:262]) == "ustar":
+		return Tar, nil
+	}
+	return "", ErrUnknownFormat
+}
+
+// Extract unpacks an archive into dest. Everything is unpacked into a temporary
+// directory next to dest first and renamed into place once complete, so dest is
+// either the whole archive or untouched.
+func Extract(archivePath, dest string, opts Options) error {
+	in, err := os.Open(archivePath)
+	if err != nil {
+		return fmt.Errorf("failed to open archive: %w", err)
+	}
+	defer in.Close()
+
+	stat, err := in.Stat()
+	if err != nil {
+		return err
+	}
+	format, err := Detect(in)
+	if err != nil {
+		return fmt.Errorf("%s: %w", archivePath, err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", filepath.Dir(dest), err)
+	}
+	tmpDir, err := os.MkdirTemp(filepath.Dir(dest), "."+filepath.Base(dest)+"-")
+	if err != nil {
+		return fmt.Errorf("failed to create extraction directory: %w", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	x := &extractor{root: tmpDir, progress: opts.Progress}
+	if format == Zip {
+		err = x.extractZip(in, stat.Size())
+	} else {
+		err = x.extractTar(in, stat.Size(), format)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to extract %s: %w", archivePath, err)
+	}
+
+	if opts.Verify != nil {
+		if err := opts.Verify(tmpDir); err != nil {
+			return err
+		}
+	}
+	// MkdirTemp creates a private directory
+	if err := os.Chmod(tmpDir, 0755); err != nil {
+		return err
+	}
+	return moveIntoPlace(tmpDir, dest, opts.Replace)
+}
+
+// moveIntoPlace renames dir to dest. An existing dest is moved aside first and put
+// back if the rename fails.
+func moveIntoPlace(dir, dest string, replace bool) error {
+	if _, err := os.Lstat(dest); err != nil {
+		if !os.IsNotExist(err) {
+			return err
+		}
+		if err := os.Rename(dir, dest); err != nil {
+			return fmt.Errorf("failed to move extracted files into place: %w", err)
+		}
+		return nil
+	}
+
+	if !replace {
+		return fmt.Errorf("%s: %w", dest, fs.ErrExist)
+	}
+	old := fmt.Sprintf("%s.old-%d", dest, time.Now().UnixNano())
+	if err := os.Rename(dest, old); err != nil {
+		return fmt.Errorf("failed to move %s aside: %w", dest, err)
+	}
+	if err := os.Rename(dir, dest); err != nil {
+		os.Rename(old, dest)
+		return fmt.Errorf("failed to move extracted files into place: %w", err)
+	}
+	return os.RemoveAll(old)
+}
+
+// Steps wraps fn so it is called only when another step percent of the work is done
+func Steps(step int64, fn Progress) Progress {
+	next := int64(0)
+	return func(done, total int64) {
+		if total <= 0 {
+			return
+		}
+		if percent := done * 100 / total; percent >= next {
+			fn(done, total)
+			next = (percent/step + 1) * step
+		}
+	}
+}
+
+type extractor struct {
+	root     string
+	progress Progress
+}
+
+func (x *extractor) report(done, total int64) {
+	if x.progress != nil {
+		x.progress(done, total)
+	}
+}
+
+func (x *extractor) extractZip(r io.ReaderAt, size int64) error {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return err
+	}
+
+	var total, done int64
+	for _, file := range zr.File {
+		total += int64(file.UncompressedSize64)
+	}
+
+	for _, file := range zr.File {
+		mode := file.Mode()
+		switch {
+		case mode.IsDir():
+			err = x.mkdir(file.Name)
+		case mode&fs.ModeSymlink != 0:
+			err = x.zipSymlink(file)
+		case mode.IsRegular():
+			err = x.zipFile(file)
+		default:
+			// Devices, pipes and the like have no place in a release
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		done += int64(file.UncompressedSize64)
+		x.report(done, total)
+	}
+	return nil
+}
+
+func (x *extractor) zipFile(file *zip.File) error {
+	src, err := file.Open()
+	if err != nil {
+		return fmt.Errorf("%s: %w", file.Name, err)
+	}
+	defer src.Close()
+	return x.writeFile(file.Name, src, file.Mode())
+}
+
+func (x *extractor) zipSymlink(file *zip.File) error {
+	src, err := file.Open()
+	if err != nil {
+		return fmt.Errorf("%s: %w", file.Name, err)
+	}
+	defer src.Close()
+
+	target, err := io.ReadAll(io.LimitReader(src, 4096))
+	if err != nil {
+		return fmt.Errorf("%s: %w", file.Name, err)
+	}
+	return x.symlink(file.Name, string(target))
+}
+
+func (x *extractor) extractTar(r io.Reader, size int64, format Format) error {
+	counter := &countingReader{r: r}
+
+	var stream io.Reader = counter
+	switch format {
+	case TarGzip:
+		gz, err := gzip.NewReader(counter)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		stream = gz
+	case TarZstd:
+		zr, err := zstd.NewReader(counter)
+		if err != nil {
+			return err
+		}
+		defer zr.Close()
+		stream = zr
+	}
+
+	tr := tar.NewReader(stream)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			x.report(size, size)
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			err = x.mkdir(header.Name)
+		case tar.TypeReg:
+			err = x.writeFile(header.Name, tr, header.FileInfo().Mode())
+		case tar.TypeSymlink:
+			err = x.symlink(header.Name, header.Linkname)
+		case tar.TypeLink:
+			err = x.hardlink(header.Name, header.Linkname)
+		default:
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		x.report(counter.n, size)
+	}
+}
+
+// target returns where an entry named name is unpacked. Names must be relative,
+// stay inside the root and not pass through a symlink unpacked earlier.
+func (x *extractor) target(name string) (string, string, error) {
+	name = strings.ReplaceAll(name, "\\", "/")
+	rel := path.Clean(name)
+	if path.IsAbs(name) || rel == ".." || strings.HasPrefix(rel, "../") {
+		return "", "", fmt.Errorf("%w: %s", ErrUnsafePath, name)
+	}
+	if rel == "." {
+		return x.root, rel, nil
+	}
+
+	dir := x.root
+	parts := strings.Split(rel, "/")
+	for _, part := range parts[:len(parts)-1] {
+		dir = filepath.Join(dir, part)
+		info, err := os.Lstat(dir)
+		if os.IsNotExist(err) {
+			break
+		}
+		if err != nil {
+			return "", "", err
+		}
+		if !info.IsDir() {
+			return "", "", fmt.Errorf("%w: %s passes through a link", ErrUnsafePath, name)
+		}
+	}
+	return filepath.Join(x.root, filepath.FromSlash(rel)), rel, nil
+}
+
+func (x *extractor) mkdir(name string) error {
+	target, _, err := x.target(name)
+	if err != nil {
+		return err
+	}
+	return os.MkdirAll(target, 0755)
+}
+
+// writeFile unpacks a regular file, keeping its permission bits (and so whether
+// it is executable) but never set-id bits
+func (x *extractor) writeFile(name string, r io.Reader, mode fs.FileMode) error {
+	target, _, err := x.target(name)
+	if err != nil {
+		return err
+	}
+	if err := x.prepare(target); err != nil {
+		return err
+	}
+
+	out, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode.Perm()|0600)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, r); err != nil {
+		out.Close()
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	// OpenFile only applies the mode to new files, and the umask to those
+	return os.Chmod(target, mode.Perm()|0600)
+}
+
+// symlink creates a link whose target stays inside the root. Leading ".." are
+// resolved against real directories, so they are checked lexically; any later
+// ".." could climb out through another link and is refused.
+func (x *extractor) symlink(name, linkname string) error {
+	target, rel, err := x.target(name)
+	if err != nil {
+		return err
+	}
+
+	link := strings.ReplaceAll(linkname, "\\", "/")
+	if link == "" || path.IsAbs(link) {
+		return fmt.Errorf("%w: %s links to %s", ErrUnsafePath, name, linkname)
+	}
+	climbing := true
+	for _, part := range strings.Split(link, "/") {
+		if part != ".." {
+			climbing = climbing && (part == "" || part == ".")
+			continue
+		}
+		if !climbing {
+			return fmt.Errorf("%w: %s links to %s", ErrUnsafePath, name, linkname)
+		}
+	}
+	resolved := path.Join(path.Dir(rel), link)
+	if resolved == ".." || strings.HasPrefix(resolved, "../") {
+		return fmt.Errorf("%w: %s links to %s", ErrUnsafePath, name, linkname)
+	}
+
+	if err := x.prepare(target); err != nil {
+		return err
+	}
+	return os.Symlink(filepath.FromSlash(link), target)
+}
+
+// hardlink links name to a file unpacked earlier from the same archive
+func (x *extractor) hardlink(name, linkname string) error {
+	target, _, err := x.target(name)
+	if err != nil {
+		return err
+	}
+	source, _, err := x.target(linkname)
+	if err != nil {
+		return err
+	}
+	info, err := os.Lstat(source)
+	if err != nil || !info.Mode().IsRegular() {
+		return fmt.Errorf("%w: %s links to %s, which is not a file in the archive", ErrUnsafePath, name, linkname)
+	}
+
+	if err := x.prepare(target); err != nil {
+		return err
+	}
+	return os.Link(source, target)
+}
+
+// prepare creates target's directory and removes anything but a directory already
+// at target, so a file never writes through a link of the same name
+func (x *extractor) prepare(target string) error {
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+	info, err := os.Lstat(target)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		return fmt.Errorf("%s is a directory", target)
+	}
+	return os.Remove(target)
+}
+
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,180 @@
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+type entry struct {
+	name string
+	body string
+	mode int64
+	link string
+	typ  byte
+}
+
+func writeTar(t *testing.T, w io.Writer, entries []entry) {
+	tw := tar.NewWriter(w)
+	for _, e := range entries {
+		typ := e.typ
+		if typ == 0 {
+			typ = tar.TypeReg
+		}
+		mode := e.mode
+		if mode == 0 {
+			mode = 0644
+		}
+		header := &tar.Header{Name: e.name, Typeflag: typ, Mode: mode, Size: int64(len(e.body)), Linkname: e.link}
+		if err := tw.WriteHeader(header); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(e.body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// writeArchive writes entries in format and returns the archive's path
+func writeArchive(t *testing.T, format Format, entries []entry) string {
+	var buf bytes.Buffer
+	switch format {
+	case Zip:
+		zw := zip.NewWriter(&buf)
+		for _, e := range entries {
+			header := &zip.FileHeader{Name: e.name, Method: zip.Deflate}
+			mode := os.FileMode(e.mode)
+			if mode == 0 {
+				mode = 0644
+			}
+			if e.typ == tar.TypeSymlink {
+				mode |= os.ModeSymlink
+				e.body = e.link
+			}
+			header.SetMode(mode)
+			w, err := zw.CreateHeader(header)
+			if err != nil {
+				t.Fatal(err)
+			}
+			w.Write([]byte(e.body))
+		}
+		if err := zw.Close(); err != nil {
+			t.Fatal(err)
+		}
+	case Tar:
+		writeTar(t, &buf, entries)
+	case TarGzip:
+		gz := gzip.NewWriter(&buf)
+		writeTar(t, gz, entries)
+		gz.Close()
+	case TarZstd:
+		zw, err := zstd.NewWriter(&buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		writeTar(t, zw, entries)
+		zw.Close()
+	}
+
+	path := filepath.Join(t.TempDir(), "archive")
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestExtractFormats(t *testing.T) {
+	entries := []entry{
+		{name: "bedrock_server", body: "#!/bin/sh\n", mode: 0755},
+		{name: "behavior_packs/vanilla/manifest.json", body: "{}"},
+		{name: "libs/current", typ: tar.TypeSymlink, link: "../behavior_packs/vanilla"},
+	}
+
+	for _, format := range []Format{Zip, Tar, TarGzip, TarZstd} {
+		path := writeArchive(t, format, entries)
+		dest := filepath.Join(t.TempDir(), "release")
+
+		var last int64
+		err := Extract(path, dest, Options{Progress: func(done, total int64) { last = done * 100 / total }})
+		if err != nil {
+			t.Errorf("%s: Extract failed: %v", format, err)
+			continue
+		}
+
+		stat, err := os.Stat(filepath.Join(dest, "bedrock_server"))
+		if err != nil || stat.Mode().Perm()&0100 == 0 {
+			t.Errorf("%s: expected an executable bedrock_server, got %v (%v)", format, stat, err)
+		}
+		if data, err := os.ReadFile(filepath.Join(dest, "libs", "current", "manifest.json")); err != nil || string(data) != "{}" {
+			t.Errorf("%s: expected the link to resolve inside the archive, got %q (%v)", format, data, err)
+		}
+		if last != 100 {
+			t.Errorf("%s: expected progress to reach 100%%, got %d%%", format, last)
+		}
+	}
+}
+
+func TestExtractRejectsUnsafe(t *testing.T) {
+	cases := map[string][]entry{
+		"parent path":      {{name: "../evil", body: "x"}},
+		"absolute path":    {{name: "/tmp/evil", body: "x"}},
+		"absolute link":    {{name: "etc", typ: tar.TypeSymlink, link: "/etc"}},
+		"escaping link":    {{name: "a/up", typ: tar.TypeSymlink, link: "../../.."}},
+		"link then write":  {{name: "dir", typ: tar.TypeSymlink, link: "."}, {name: "dir/../../evil", body: "x"}},
+		"climb via link":   {{name: "self", typ: tar.TypeSymlink, link: "."}, {name: "out", typ: tar.TypeSymlink, link: "self/../x"}},
+		"write via link":   {{name: "sub", typ: tar.TypeDir}, {name: "in", typ: tar.TypeSymlink, link: "sub"}, {name: "in/file", body: "x"}},
+		"hardlink outside": {{name: "passwd", typ: tar.TypeLink, link: "../../etc/passwd"}},
+	}
+
+	for name, entries := range cases {
+		for _, format := range []Format{Tar, Zip} {
+			if format == Zip && (entries[0].typ == tar.TypeLink || entries[0].typ == tar.TypeDir) {
+				continue
+			}
+			path := writeArchive(t, format, entries)
+			parent := t.TempDir()
+			dest := filepath.Join(parent, "release")
+
+			err := Extract(path, dest, Options{})
+			if !errors.Is(err, ErrUnsafePath) {
+				t.Errorf("%s (%s): expected ErrUnsafePath, got %v", name, format, err)
+			}
+			// Nothing may be left behind, not even the temporary directory
+			if leftovers, _ := os.ReadDir(parent); len(leftovers) != 0 {
+				t.Errorf("%s (%s): expected an empty parent directory, found %d entries", name, format, len(leftovers))
+			}
+		}
+	}
+}
+
+func TestExtractReplace(t *testing.T) {
+	path := writeArchive(t, Zip, []entry{{name: "new", body: "x"}})
+	dest := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dest, "old"), []byte("x"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Extract(path, dest, Options{}); !errors.Is(err, os.ErrExist) {
+		t.Errorf("Expected ErrExist without Replace, got %v", err)
+	}
+	if err := Extract(path, dest, Options{Replace: true}); err != nil {
+		t.Fatalf("Extract failed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dest, "old")); !os.IsNotExist(err) {
+		t.Errorf("Expected the old contents to be replaced, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dest, "new")); err != nil {
+		t.Errorf("Expected the archive's contents: %v", err)
+	}
+}
@@ -0,0 +1,269 @@
+// Package auth checks API tokens and the roles they grant. Only the SHA-256 of
+// each token is configured, so config.yaml and the tokens file hold no secrets.
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+
+	"minecraft-server-manager/internal/config"
+
+	"gopkg.in/yaml.v3"
+)
+
+// hashPrefix marks the hash algorithm in configured token hashes
+const hashPrefix = "sha256:"
+
+// WebSocketTokenPrefix marks the subprotocol a browser sends its token in
+const WebSocketTokenPrefix = "bearer."
+
+// Role grants access to a set of routes. Each role includes the ones below it.
+type Role int
+
+const (
+	None Role = iota
+	Viewer
+	Operator
+	Admin
+)
+
+var roleNames = map[Role]string{None: "none", Viewer: "viewer", Operator: "operator", Admin: "admin"}
+
+func (r Role) String() string {
+	return roleNames[r]
+}
+
+// ParseRole returns the role with the given name
+func ParseRole(name string) (Role, error) {
+	for role, roleName := range roleNames {
+		if role != None && roleName == strings.ToLower(strings.TrimSpace(name)) {
+			return role, nil
+		}
+	}
+	return None, fmt.Errorf("unknown role %q (viewer, operator or admin)", name)
+}
+
+var (
+	// ErrNoToken is returned for a request without a bearer token
+	ErrNoToken = errors.New("missing API token")
+
+	// ErrBadToken is returned for a token that matches no configured hash
+	ErrBadToken = errors.New("invalid API token")
+)
+
+// Identity is who made a request
+type Identity struct {
+	Name string
+	Role Role
+}
+
+type token struct {
+	hash     []byte
+	identity Identity
+}
+
+// Authenticator checks bearer tokens against configured hashes, and otherwise
+// accepts a verified client certificate with the configured client role.
+// Requests with neither are refused unless anonymous access is allowed.
+type Authenticator struct {
+	mu            sync.RWMutex
+	tokens        []token
+	clientRole    Role
+	anonymousRole Role
+}
+
+// New loads the tokens of an HTTP configuration
+func New(cfg config.HTTPConfig) (*Authenticator, error) {
+	a := &Authenticator{}
+	if err := a.Load(cfg); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// Load replaces the tokens with those of cfg and its tokens file. Nothing is
+// replaced if any token is invalid.
+func (a *Authenticator) Load(cfg config.HTTPConfig) error {
+	configured := append([]config.TokenConfig{}, cfg.Tokens...)
+	if cfg.TokensFile != "" {
+		fromFile, err := readTokensFile(cfg.TokensFile)
+		if err != nil {
+			return err
+		}
+		configured = append(configured, fromFile...)
+	}
+
+	clientRole := None
+	if cfg.TLS.ClientRole != "" {
+		role, err := ParseRole(cfg.TLS.ClientRole)
+		if err != nil {
+			return fmt.Errorf("tls.client_role: %w", err)
+		}
+		clientRole = role
+	}
+
+	anonymousRole := None
+	if cfg.AllowAnonymous != "" {
+		role, err := ParseRole(cfg.AllowAnonymous)
+		if err != nil {
+			return fmt.Errorf("allow_anonymous: %w", err)
+		}
+		anonymousRole = role
+	}
+
+	tokens := make([]token, 0, len(configured))
+	names := make(map[string]bool)
+	for _, t := range configured {
+		if t.Name == "" {
+			return errors.New("API token without a name")
+		}
+		if names[t.Name] {
+			return fmt.Errorf("API token %s is configured twice", t.Name)
+		}
+		names[t.Name] = true
+
+		role, err := ParseRole(t.Role)
+		if err != nil {
+			return fmt.Errorf("API token %s: %w", t.Name, err)
+		}
+		hash, err := hex.DecodeString(strings.TrimPrefix(t.Hash, hashPrefix))
+		if err != nil || !strings.HasPrefix(t.Hash, hashPrefix) || len(hash) != sha256.Size {
+			return fmt.Errorf("API token %s: hash must be %s followed by 64 hex digits", t.Name, hashPrefix)
+		}
+		tokens = append(tokens, token{hash: hash, identity: Identity{Name: t.Name, Role: role}})
+	}
+
+	a.mu.Lock()
+	a.tokens = tokens
+	a.clientRole = clientRole
+	a.anonymousRole = anonymousRole
+	a.mu.Unlock()
+	return nil
+}
+
+func readTokensFile(path string) ([]config.TokenConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tokens file: %w", err)
+	}
+	var file struct {
+		Tokens []config.TokenConfig `yaml:"tokens"`
+	}
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse tokens file %s: %w", path, err)
+	}
+	return file.Tokens, nil
+}
+
+// Enabled reports whether any token is configured
+func (a *Authenticator) Enabled() bool {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return len(a.tokens) > 0
+}
+
+// AnonymousRole returns the role of requests without a token or certificate
+func (a *Authenticator) AnonymousRole() Role {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.anonymousRole
+}
+
+// Authenticate returns who sent a request, from its "Authorization: Bearer" header,
+// a "bearer.<token>" WebSocket subprotocol or else its verified client
+// certificate. Without any of them it is anonymous, if that is allowed.
+func (a *Authenticator) Authenticate(r *http.Request) (Identity, error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	scheme, secret, _ := strings.Cut(r.Header.Get("Authorization"), " ")
+	secret = strings.TrimSpace(secret)
+	if scheme == "" {
+		// Browsers cannot set headers on a WebSocket, only its subprotocols
+		scheme, secret = "Bearer", websocketToken(r)
+	}
+	if !strings.EqualFold(scheme, "Bearer") || secret == "" {
+		if certName := clientCertName(r); certName != "" && a.clientRole != None {
+			return Identity{Name: certName, Role: a.clientRole}, nil
+		}
+		if a.anonymousRole != None {
+			return Identity{Name: "anonymous", Role: a.anonymousRole}, nil
+		}
+		return Identity{}, ErrNoToken
+	}
+
+	sum := sha256.Sum256([]byte(secret))
+	match := Identity{}
+	found := false
+	// Compare against every token so the time taken does not depend on which matched
+	for _, t := range a.tokens {
+		if subtle.ConstantTimeCompare(sum[:], t.hash) == 1 {
+			match = t.identity
+			found = true
+		}
+	}
+	if !found {
+		return Identity{}, ErrBadToken
+	}
+	return match, nil
+}
+
+// websocketToken returns the token offered as a "bearer.<token>" subprotocol, or ""
+func websocketToken(r *http.Request) string {
+	for _, header := range r.Header.Values("Sec-WebSocket-Protocol") {
+		for _, protocol := range strings.Split(header, ",") {
+			if token, ok := strings.CutPrefix(strings.TrimSpace(protocol), WebSocketTokenPrefix); ok {
+				return token
+			}
+		}
+	}
+	return ""
+}
+
+// clientCertName returns the common name of a verified client certificate, or ""
+func clientCertName(r *http.Request) string {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+		return ""
+	}
+	if name := r.TLS.VerifiedChains[0][0].Subject.CommonName; name != "" {
+		return "cert:" + name
+	}
+	return ""
+}
+
+// Hash returns the value to configure as the hash of a token
+func Hash(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hashPrefix + hex.EncodeToString(sum[:])
+}
+
+// NewToken returns a random token
+func NewToken() (string, error) {
+	secret := make([]byte, 24)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	return "msm_" + hex.EncodeToString(secret), nil
+}
+
+type contextKey struct{}
+
+// WithIdentity returns a context carrying the caller's identity
+func WithIdentity(ctx context.Context, identity Identity) context.Context {
+	return context.WithValue(ctx, contextKey{}, identity)
+}
+
+// FromContext returns the identity stored by WithIdentity
+func FromContext(ctx context.Context) (Identity, bool) {
+	identity, ok := ctx.Value(contextKey{}).(Identity)
+	return identity, ok
+}
@@ -0,0 +1,112 @@
+package auth
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"minecraft-server-manager/internal/config"
+)
+
+func TestAuthenticate(t *testing.T) {
+	tokensFile := filepath.Join(t.TempDir(), "tokens.yaml")
+	os.WriteFile(tokensFile, []byte("tokens:\n  - name: ci\n    role: admin\n    hash: "+Hash("ci-secret")+"\n"), 0600)
+
+	a, err := New(config.HTTPConfig{
+		Tokens:     []config.TokenConfig{{Name: "alice", Role: "operator", Hash: Hash("alice-secret")}},
+		TokensFile: tokensFile,
+	})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	cases := []struct {
+		header string
+		want   Identity
+		err    error
+	}{
+		{"Bearer alice-secret", Identity{Name: "alice", Role: Operator}, nil},
+		{"bearer ci-secret", Identity{Name: "ci", Role: Admin}, nil},
+		{"Bearer wrong", Identity{}, ErrBadToken},
+		{"Basic alice-secret", Identity{}, ErrNoToken},
+		{"", Identity{}, ErrNoToken},
+	}
+	for _, c := range cases {
+		r := httptest.NewRequest("GET", "/status", nil)
+		if c.header != "" {
+			r.Header.Set("Authorization", c.header)
+		}
+		identity, err := a.Authenticate(r)
+		if identity != c.want || err != c.err {
+			t.Errorf("%q: expected %+v (%v), got %+v (%v)", c.header, c.want, c.err, identity, err)
+		}
+	}
+
+	// Browsers send the token as a WebSocket subprotocol
+	r := httptest.NewRequest("GET", "/servers/survival/console", nil)
+	r.Header.Set("Sec-WebSocket-Protocol", "msm.console, bearer.alice-secret")
+	if identity, err := a.Authenticate(r); identity.Name != "alice" || err != nil {
+		t.Errorf("Expected alice from the subprotocol, got %+v (%v)", identity, err)
+	}
+
+	// A bad token leaves the loaded ones in place
+	if err := a.Load(config.HTTPConfig{Tokens: []config.TokenConfig{{Name: "bob", Role: "root", Hash: Hash("x")}}}); err == nil {
+		t.Fatal("Expected an unknown role to be rejected")
+	}
+	if err := a.Load(config.HTTPConfig{Tokens: []config.TokenConfig{{Name: "bob", Role: "viewer", Hash: "deadbeef"}}}); err == nil {
+		t.Fatal("Expected a malformed hash to be rejected")
+	}
+	if !a.Enabled() {
+		t.Fatal("Expected the previous tokens to be kept")
+	}
+}
+
+func TestNoTokens(t *testing.T) {
+	a, err := New(config.HTTPConfig{})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	if _, err := a.Authenticate(httptest.NewRequest("POST", "/admin/reload", nil)); err != ErrNoToken {
+		t.Errorf("Expected requests to be refused without tokens, got %v", err)
+	}
+
+	// Anonymous access has to be asked for
+	if err := a.Load(config.HTTPConfig{AllowAnonymous: "viewer"}); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	identity, err := a.Authenticate(httptest.NewRequest("GET", "/status", nil))
+	if err != nil || identity != (Identity{Name: "anonymous", Role: Viewer}) {
+		t.Errorf("Expected an anonymous viewer, got %+v (%v)", identity, err)
+	}
+	r := httptest.NewRequest("GET", "/status", nil)
+	r.Header.Set("Authorization", "Bearer guess")
+	if _, err := a.Authenticate(r); err != ErrBadToken {
+		t.Errorf("Expected a wrong token to be refused rather than treated as anonymous, got %v", err)
+	}
+}
+
+func TestClientCertificate(t *testing.T) {
+	a, err := New(config.HTTPConfig{
+		Tokens: []config.TokenConfig{{Name: "alice", Role: "admin", Hash: Hash("alice-secret")}},
+		TLS:    config.TLSConfig{ClientRole: "viewer"},
+	})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	r := httptest.NewRequest("GET", "/status", nil)
+	r.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{{Subject: pkix.Name{CommonName: "laptop"}}}}}
+	if identity, err := a.Authenticate(r); err != nil || identity != (Identity{Name: "cert:laptop", Role: Viewer}) {
+		t.Errorf("Expected the certificate to authenticate as a viewer, got %+v (%v)", identity, err)
+	}
+
+	// A token wins over the certificate
+	r.Header.Set("Authorization", "Bearer alice-secret")
+	if identity, _ := a.Authenticate(r); identity.Name != "alice" {
+		t.Errorf("Expected the token's identity, got %+v", identity)
+	}
+}
@@ -0,0 +1,338 @@
+package backup
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"minecraft-server-manager/internal/config"
+)
+
+// ErrNotFound is returned for a backup ID that does not exist
+var ErrNotFound = errors.New("backup not found")
+
+// File is a world file to back up. Path is relative to the worlds directory and
+// uses forward slashes; only the first Size bytes are copied.
+type File struct {
+	Path string `json:"path"`
+	Size int64  `json:"size"`
+}
+
+// Info describes a stored backup
+type Info struct {
+	ID        string    `json:"id"`
+	Server    string    `json:"server"`
+	World     string    `json:"world"`
+	CreatedAt time.Time `json:"created_at"`
+	Method    string    `json:"method"` // "hot" while running, "cold" while stopped
+	Format    string    `json:"format,omitempty"`
+	Files     int       `json:"files"`
+	Size      int64     `json:"size"`             // World bytes, or archive bytes for tar.gz backups
+	Stored    int64     `json:"stored,omitempty"` // Compressed bytes the backup added to the chunk store
+}
+
+// Backup formats. Backups made before the chunk store have no format and are
+// single tar.gz archives; they can still be listed and restored.
+const (
+	FormatChunked = "chunked"
+	FormatTarGz   = ""
+)
+
+// Store keeps backups below a directory, one subdirectory per server
+type Store struct {
+	dir string
+}
+
+func NewStore(dir string) *Store {
+	return &Store{dir: dir}
+}
+
+func (s *Store) serverDir(server string) string {
+	return filepath.Join(s.dir, server)
+}
+
+// Create copies files from worldsDir into the chunk store. Chunks the store
+// already holds from earlier backups are shared rather than copied again.
+func (s *Store) Create(server, world, worldsDir, method string, files []File) (*Info, error) {
+	dir := s.serverDir(server)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create backup directory: %w", err)
+	}
+
+	info := &Info{
+		ID:        s.newID(server),
+		Server:    server,
+		World:     world,
+		CreatedAt: time.Now().UTC(),
+		Method:    method,
+		Format:    FormatChunked,
+		Files:     len(files),
+	}
+
+	manifest, size, stored, err := s.writeSnapshot(info, worldsDir, files)
+	if err != nil {
+		return nil, err
+	}
+	info.Size = size
+	info.Stored = stored
+
+	// The metadata is written last so a failed backup never looks complete
+	if err := s.writeManifest(manifest); err != nil {
+		return nil, err
+	}
+	data, err := json.MarshalIndent(info, "", "  ")
+	if err != nil {
+		os.Remove(s.manifestPath(server, info.ID))
+		return nil, err
+	}
+	if err := os.WriteFile(filepath.Join(dir, info.ID+".json"), data, 0644); err != nil {
+		os.Remove(s.manifestPath(server, info.ID))
+		return nil, fmt.Errorf("failed to write backup metadata: %w", err)
+	}
+
+	return info, nil
+}
+
+// List returns a server's backups, newest first
+func (s *Store) List(server string) ([]Info, error) {
+	entries, err := os.ReadDir(s.serverDir(server))
+	if os.IsNotExist(err) {
+		return []Info{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list backups: %w", err)
+	}
+
+	backups := []Info{}
+	for _, entry := range entries {
+		id, ok := strings.CutSuffix(entry.Name(), ".json")
+		if !ok || strings.HasSuffix(id, ".manifest") {
+			continue
+		}
+		info, err := s.Get(server, id)
+		if err != nil {
+			// Metadata without its data is a backup that never finished
+			continue
+		}
+		backups = append(backups, *info)
+	}
+
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].CreatedAt.After(backups[j].CreatedAt)
+	})
+	return backups, nil
+}
+
+// Get returns a single backup's metadata
+func (s *Store) Get(server, id string) (*Info, error) {
+	if !validID(id) {
+		return nil, ErrNotFound
+	}
+
+	data, err := os.ReadFile(filepath.Join(s.serverDir(server), id+".json"))
+	if os.IsNotExist(err) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read backup metadata: %w", err)
+	}
+
+	var info Info
+	if err := json.Unmarshal(data, &info); err != nil {
+		return nil, fmt.Errorf("failed to parse backup metadata for %s: %w", id, err)
+	}
+	if !exists(s.dataPath(&info)) {
+		return nil, ErrNotFound
+	}
+	return &info, nil
+}
+
+// dataPath returns the manifest of a chunked backup or the archive of a tar.gz one
+func (s *Store) dataPath(info *Info) string {
+	if info.Format == FormatChunked {
+		return s.manifestPath(info.Server, info.ID)
+	}
+	return filepath.Join(s.serverDir(info.Server), info.ID+".tar.gz")
+}
+
+// Delete removes a backup's metadata and data. Chunks are left for GC, since
+// other backups may share them.
+func (s *Store) Delete(server, id string) error {
+	if !validID(id) {
+		return ErrNotFound
+	}
+
+	dir := s.serverDir(server)
+	for _, name := range []string{id + ".json", id + ".manifest.json", id + ".tar.gz"} {
+		if err := os.Remove(filepath.Join(dir, name)); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to delete backup %s: %w", id, err)
+		}
+	}
+	return nil
+}
+
+// Prune deletes the backups the retention policy no longer keeps and returns them
+func (s *Store) Prune(server string, policy config.RetentionConfig) ([]Info, error) {
+	backups, err := s.List(server)
+	if err != nil {
+		return nil, err
+	}
+
+	expired := SelectExpired(backups, policy)
+	for _, info := range expired {
+		if err := s.Delete(server, info.ID); err != nil {
+			return nil, err
+		}
+	}
+	return expired, nil
+}
+
+// Restore unpacks a backup as world inside worldsDir. An existing world is moved
+// aside rather than deleted, and its new path is returned so it can be rolled back.
+func (s *Store) Restore(info *Info, worldsDir, world string) (string, error) {
+	if _, err := cleanPath(world); err != nil || strings.Contains(world, "/") {
+		return "", fmt.Errorf("invalid world name %q", world)
+	}
+
+	// Unpack next to the world so the final rename stays on one filesystem
+	tmpDir := filepath.Join(worldsDir, ".restore-"+info.ID)
+	os.RemoveAll(tmpDir)
+	if err := os.MkdirAll(tmpDir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create restore directory: %w", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	if info.Format == FormatChunked {
+		manifest, err := s.readManifest(info.Server, info.ID)
+		if err != nil {
+			return "", fmt.Errorf("failed to read backup manifest: %w", err)
+		}
+		if err := s.restoreSnapshot(manifest, tmpDir); err != nil {
+			return "", err
+		}
+	} else if err := extractArchive(s.dataPath(info), info.World, tmpDir); err != nil {
+		return "", err
+	}
+
+	return InstallWorld(tmpDir, worldsDir, world, "pre-restore")
+}
+
+// extractArchive unpacks the files of world from a tar.gz backup into dir,
+// dropping the world prefix
+func extractArchive(archivePath, world, dir string) error {
+	in, err := os.Open(archivePath)
+	if err != nil {
+		return fmt.Errorf("failed to open backup archive: %w", err)
+	}
+	defer in.Close()
+
+	gz, err := gzip.NewReader(in)
+	if err != nil {
+		return fmt.Errorf("failed to read backup archive: %w", err)
+	}
+	defer gz.Close()
+
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read backup archive: %w", err)
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		name, err := cleanPath(header.Name)
+		if err != nil {
+			return err
+		}
+		rel, ok := strings.CutPrefix(name, world+"/")
+		if !ok {
+			return fmt.Errorf("backup entry %s is outside world %s", name, world)
+		}
+
+		if err := extractFile(tr, filepath.Join(dir, filepath.FromSlash(rel)), header); err != nil {
+			return err
+		}
+	}
+}
+
+func extractFile(r io.Reader, target string, header *tar.Header) error {
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+
+	out, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, os.FileMode(header.Mode).Perm()|0600)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", header.Name, err)
+	}
+	defer out.Close()
+
+	if _, err := io.CopyN(out, r, header.Size); err != nil {
+		return fmt.Errorf("failed to extract %s: %w", header.Name, err)
+	}
+	return nil
+}
+
+// validID rejects IDs that could name a file outside the server's backup directory
+func validID(id string) bool {
+	return id != "" && !strings.ContainsAny(id, "/\\.")
+}
+
+// newID returns a sortable, URL safe ID that is unique for the server
+func (s *Store) newID(server string) string {
+	base := time.Now().UTC().Format("20060102-150405")
+	id := base
+	for i := 2; ; i++ {
+		if _, err := os.Stat(filepath.Join(s.serverDir(server), id+".json")); os.IsNotExist(err) {
+			return id
+		}
+		id = fmt.Sprintf("%s-%d", base, i)
+	}
+}
+
+// WalkFiles lists every file of a world for a cold backup
+func WalkFiles(worldsDir, world string) ([]File, error) {
+	root := filepath.Join(worldsDir, world)
+	if _, err := os.Stat(root); err != nil {
+		return nil, fmt.Errorf("world %s not found: %w", world, err)
+	}
+
+	var files []File
+	err := filepath.Walk(root, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(worldsDir, p)
+		if err != nil {
+			return err
+		}
+		files = append(files, File{Path: filepath.ToSlash(rel), Size: info.Size()})
+		return nil
+	})
+	return files, err
+}
+
+// cleanPath rejects paths that would leave the worlds directory
+func cleanPath(p string) (string, error) {
+	cleaned := path.Clean(strings.TrimSpace(p))
+	if cleaned == "." || path.IsAbs(cleaned) || cleaned == ".." || strings.HasPrefix(cleaned, "../") {
+		return "", fmt.Errorf("invalid backup path %q", p)
+	}
+	return cleaned, nil
+}
@@ -0,0 +1,205 @@
+package backup
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"minecraft-server-manager/internal/checksum"
+	"minecraft-server-manager/internal/config"
+	"minecraft-server-manager/internal/testutil"
+)
+
+func TestSelectExpired(t *testing.T) {
+	start := time.Date(2026, 10, 5, 0, 30, 0, 0, time.Local) // A Monday
+	var backups []Info
+	// One backup every six hours for two weeks
+	for i := 0; i < 56; i++ {
+		created := start.Add(time.Duration(i) * 6 * time.Hour)
+		backups = append(backups, Info{ID: created.Format("20060102-150405"), CreatedAt: created})
+	}
+
+	expired := SelectExpired(backups, config.RetentionConfig{KeepLast: 2, KeepDaily: 3, KeepWeekly: 2})
+	kept := make(map[string]bool)
+	for _, info := range backups {
+		kept[info.ID] = true
+	}
+	for _, info := range expired {
+		delete(kept, info.ID)
+	}
+
+	want := []string{
+		"20261018-183000", // Last two
+		"20261018-123000",
+		"20261017-183000", // Newest of the two days before
+		"20261016-183000",
+		"20261011-183000", // Newest of the previous week
+	}
+	if len(kept) != len(want) {
+		t.Errorf("Expected %d backups kept, got %d: %v", len(want), len(kept), kept)
+	}
+	for _, id := range want {
+		if !kept[id] {
+			t.Errorf("Expected %s to be kept", id)
+		}
+	}
+
+	if expired := SelectExpired(backups, config.RetentionConfig{}); len(expired) != 0 {
+		t.Errorf("Expected an empty policy to keep everything, %d expired", len(expired))
+	}
+}
+
+func TestCreateAndRestore(t *testing.T) {
+	worldsDir := t.TempDir()
+	store := NewStore(t.TempDir())
+
+	testutil.WriteFile(t, filepath.Join(worldsDir, "world", "level.dat"), "level data and more")
+	testutil.WriteFile(t, filepath.Join(worldsDir, "world", "db", "CURRENT"), "MANIFEST-000001")
+
+	info, err := store.Create("survival", "world", worldsDir, "hot", []File{
+		{Path: "world/level.dat", Size: 10},
+		{Path: "world/db/CURRENT", Size: 15},
+	})
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	backups, err := store.List("survival")
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if len(backups) != 1 || backups[0].ID != info.ID {
+		t.Fatalf("Expected the new backup to be listed, got %+v", backups)
+	}
+
+	testutil.WriteFile(t, filepath.Join(worldsDir, "world", "level.dat"), "changed")
+
+	previous, err := store.Restore(info, worldsDir, "world")
+	if err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+
+	// The snapshot holds only the reported length of each file
+	if data, _ := os.ReadFile(filepath.Join(worldsDir, "world", "level.dat")); string(data) != "level data" {
+		t.Errorf("Expected restored level.dat, got %q", data)
+	}
+	if data, _ := os.ReadFile(filepath.Join(previous, "level.dat")); string(data) != "changed" {
+		t.Errorf("Expected the replaced world to be kept, got %q", data)
+	}
+}
+
+func TestChunkDeduplication(t *testing.T) {
+	worldsDir := t.TempDir()
+	store := NewStore(t.TempDir())
+
+	testutil.WriteFile(t, filepath.Join(worldsDir, "world", "level.dat"), "level data")
+	testutil.WriteFile(t, filepath.Join(worldsDir, "world", "db", "000005.ldb"), "table contents")
+
+	snapshot := func() *Info {
+		t.Helper()
+		files, err := WalkFiles(worldsDir, "world")
+		if err != nil {
+			t.Fatalf("WalkFiles failed: %v", err)
+		}
+		info, err := store.Create("survival", "world", worldsDir, "cold", files)
+		if err != nil {
+			t.Fatalf("Create failed: %v", err)
+		}
+		return info
+	}
+
+	first := snapshot()
+	if first.Stored == 0 {
+		t.Error("Expected the first backup to store chunks")
+	}
+	second := snapshot()
+	if second.Stored != 0 {
+		t.Errorf("Expected an unchanged world to store nothing, stored %d bytes", second.Stored)
+	}
+
+	testutil.WriteFile(t, filepath.Join(worldsDir, "world", "level.dat"), "new level data")
+	third := snapshot()
+
+	for _, info := range []*Info{first, second} {
+		if err := store.Delete("survival", info.ID); err != nil {
+			t.Fatalf("Delete failed: %v", err)
+		}
+	}
+	freed, err := store.GC()
+	if err != nil {
+		t.Fatalf("GC failed: %v", err)
+	}
+	if freed.Chunks != 1 {
+		t.Errorf("Expected only the old level.dat chunk to be freed, freed %d", freed.Chunks)
+	}
+
+	result, err := store.Verify()
+	if err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+	if !result.OK() || result.Chunks != 2 {
+		t.Errorf("Expected 2 intact chunks, got %+v", result)
+	}
+
+	if _, err := store.Restore(third, worldsDir, "world"); err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(worldsDir, "world", "db", "000005.ldb")); string(data) != "table contents" {
+		t.Errorf("Expected shared chunk to be restored, got %q", data)
+	}
+
+	// Corrupt a chunk in place
+	hash := checksum.Bytes([]byte("table contents"))
+	if err := os.WriteFile(store.chunkPath(hash), []byte("garbage"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	result, err = store.Verify()
+	if err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+	if len(result.Corrupt) != 1 || result.Corrupt[0] != hash {
+		t.Errorf("Expected chunk %s to be reported corrupt, got %+v", hash, result)
+	}
+}
+
+func TestRestoreLegacyArchive(t *testing.T) {
+	worldsDir := t.TempDir()
+	store := NewStore(t.TempDir())
+
+	info := &Info{ID: "20240101-120000", Server: "survival", World: "world", Format: FormatTarGz}
+	dir := store.serverDir("survival")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := os.Create(filepath.Join(dir, info.ID+".tar.gz"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	gz := gzip.NewWriter(out)
+	tw := tar.NewWriter(gz)
+	content := []byte("level data")
+	tw.WriteHeader(&tar.Header{Typeflag: tar.TypeReg, Name: "world/level.dat", Mode: 0644, Size: int64(len(content))})
+	tw.Write(content)
+	tw.Close()
+	gz.Close()
+	out.Close()
+
+	data, _ := json.Marshal(info)
+	testutil.WriteFile(t, filepath.Join(dir, info.ID+".json"), string(data))
+
+	stored, err := store.Get("survival", info.ID)
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if _, err := store.Restore(stored, worldsDir, "world"); err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(worldsDir, "world", "level.dat")); string(data) != "level data" {
+		t.Errorf("Expected restored level.dat, got %q", data)
+	}
+}
@@ -0,0 +1,411 @@
+package backup
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"minecraft-server-manager/internal/checksum"
+)
+
+// chunkSize is the unit of deduplication. Bedrock's LevelDB tables are written once
+// and never modified, so most of a world is shared by consecutive snapshots.
+const chunkSize = 1 << 20
+
+// Manifest lists the files of a snapshot and the chunks they are made of
+type Manifest struct {
+	ID     string         `json:"id"`
+	Server string         `json:"server"`
+	World  string         `json:"world"`
+	Files  []ManifestFile `json:"files"`
+}
+
+type ManifestFile struct {
+	Path   string   `json:"path"`
+	Size   int64    `json:"size"`
+	Mode   uint32   `json:"mode"`
+	Chunks []string `json:"chunks"` // SHA-256 of each chunk's uncompressed content, in order
+}
+
+// GCResult summarises a garbage collection run
+type GCResult struct {
+	Chunks int   `json:"chunks"`
+	Bytes  int64 `json:"bytes"`
+}
+
+// VerifyResult lists the problems found by Verify
+type VerifyResult struct {
+	Chunks  int      `json:"chunks"`
+	Corrupt []string `json:"corrupt,omitempty"` // Chunks whose content does not match their hash
+	Missing []string `json:"missing,omitempty"` // "server/id: hash" for chunks a manifest needs but the store lacks
+}
+
+// OK reports whether every chunk is intact and present
+func (r VerifyResult) OK() bool {
+	return len(r.Corrupt) == 0 && len(r.Missing) == 0
+}
+
+func (s *Store) chunksDir() string {
+	return filepath.Join(s.dir, "store", "chunks")
+}
+
+func (s *Store) chunkPath(hash string) string {
+	return filepath.Join(s.chunksDir(), hash[:2], hash)
+}
+
+func (s *Store) manifestPath(server, id string) string {
+	return filepath.Join(s.serverDir(server), id+".manifest.json")
+}
+
+// writeSnapshot stores the files' chunks and returns the manifest, the world bytes
+// it covers and the compressed bytes that were new to the store
+func (s *Store) writeSnapshot(info *Info, worldsDir string, files []File) (*Manifest, int64, int64, error) {
+	manifest := &Manifest{ID: info.ID, Server: info.Server, World: info.World}
+	var size, stored int64
+
+	buf := make([]byte, chunkSize)
+	for _, file := range files {
+		entry, added, err := s.writeFile(worldsDir, file, buf)
+		if err != nil {
+			return nil, 0, 0, err
+		}
+		manifest.Files = append(manifest.Files, *entry)
+		size += entry.Size
+		stored += added
+	}
+	return manifest, size, stored, nil
+}
+
+func (s *Store) writeFile(worldsDir string, file File, buf []byte) (*ManifestFile, int64, error) {
+	name, err := cleanPath(file.Path)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	src, err := os.Open(filepath.Join(worldsDir, filepath.FromSlash(name)))
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to open %s: %w", name, err)
+	}
+	defer src.Close()
+
+	stat, err := src.Stat()
+	if err != nil {
+		return nil, 0, err
+	}
+	if stat.Size() < file.Size {
+		return nil, 0, fmt.Errorf("%s is shorter (%d bytes) than reported (%d bytes)", name, stat.Size(), file.Size)
+	}
+
+	entry := &ManifestFile{Path: name, Size: file.Size, Mode: uint32(stat.Mode().Perm())}
+	var added int64
+
+	// Bytes beyond the reported length are still being written and are not part of the snapshot
+	r := io.LimitReader(src, file.Size)
+	for {
+		n, err := io.ReadFull(r, buf)
+		if n > 0 {
+			hash := checksum.Bytes(buf[:n])
+			written, err := s.writeChunk(hash, buf[:n])
+			if err != nil {
+				return nil, 0, err
+			}
+			entry.Chunks = append(entry.Chunks, hash)
+			added += written
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		}
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to read %s: %w", name, err)
+		}
+	}
+	return entry, added, nil
+}
+
+// writeChunk stores a chunk unless the store already has it and returns the bytes written
+func (s *Store) writeChunk(hash string, data []byte) (int64, error) {
+	path := s.chunkPath(hash)
+	if _, err := os.Stat(path); err == nil {
+		return 0, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return 0, fmt.Errorf("failed to create chunk directory: %w", err)
+	}
+
+	tmpPath := path + ".tmp"
+	out, err := os.Create(tmpPath)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create chunk: %w", err)
+	}
+
+	gz := gzip.NewWriter(out)
+	_, err = gz.Write(data)
+	if err == nil {
+		err = gz.Close()
+	}
+	if err == nil {
+		err = out.Sync()
+	}
+	out.Close()
+	if err != nil {
+		os.Remove(tmpPath)
+		return 0, fmt.Errorf("failed to write chunk %s: %w", hash, err)
+	}
+
+	stat, err := os.Stat(tmpPath)
+	if err != nil {
+		os.Remove(tmpPath)
+		return 0, err
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return 0, fmt.Errorf("failed to store chunk %s: %w", hash, err)
+	}
+	return stat.Size(), nil
+}
+
+// readChunk returns a chunk's uncompressed content
+func (s *Store) readChunk(hash string) ([]byte, error) {
+	if !validHash(hash) {
+		return nil, fmt.Errorf("invalid chunk hash %q", hash)
+	}
+	return readChunkFile(s.chunkPath(hash), hash)
+}
+
+func readChunkFile(path, hash string) ([]byte, error) {
+	in, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer in.Close()
+
+	gz, err := gzip.NewReader(in)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read chunk %s: %w", hash, err)
+	}
+	defer gz.Close()
+
+	data, err := io.ReadAll(gz)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read chunk %s: %w", hash, err)
+	}
+	return data, nil
+}
+
+// verifyChunkFile checks that a compressed chunk file holds the content its hash names
+func verifyChunkFile(path, hash string) error {
+	data, err := readChunkFile(path, hash)
+	if err != nil {
+		return err
+	}
+	if checksum.Bytes(data) != hash {
+		return fmt.Errorf("chunk %s is corrupt", hash)
+	}
+	return nil
+}
+
+func (s *Store) writeManifest(manifest *Manifest) error {
+	data, err := json.Marshal(manifest)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(s.manifestPath(manifest.Server, manifest.ID), data, 0644); err != nil {
+		return fmt.Errorf("failed to write backup manifest: %w", err)
+	}
+	return nil
+}
+
+func (s *Store) readManifest(server, id string) (*Manifest, error) {
+	data, err := os.ReadFile(s.manifestPath(server, id))
+	if err != nil {
+		return nil, err
+	}
+	var manifest Manifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("failed to parse backup manifest for %s: %w", id, err)
+	}
+	return &manifest, nil
+}
+
+// manifests returns every snapshot manifest of every server
+func (s *Store) manifests() ([]*Manifest, error) {
+	paths, err := filepath.Glob(filepath.Join(s.dir, "*", "*.manifest.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	var result []*Manifest
+	for _, path := range paths {
+		server := filepath.Base(filepath.Dir(path))
+		id := strings.TrimSuffix(filepath.Base(path), ".manifest.json")
+		manifest, err := s.readManifest(server, id)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, manifest)
+	}
+	return result, nil
+}
+
+// restoreSnapshot rebuilds the files of a manifest below dir, dropping the world prefix
+func (s *Store) restoreSnapshot(manifest *Manifest, dir string) error {
+	for _, file := range manifest.Files {
+		name, err := cleanPath(file.Path)
+		if err != nil {
+			return err
+		}
+		rel, ok := strings.CutPrefix(name, manifest.World+"/")
+		if !ok {
+			return fmt.Errorf("backup entry %s is outside world %s", name, manifest.World)
+		}
+
+		target := filepath.Join(dir, filepath.FromSlash(rel))
+		if err := s.restoreFile(file, target); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Store) restoreFile(file ManifestFile, target string) error {
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+
+	out, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, os.FileMode(file.Mode).Perm()|0600)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", file.Path, err)
+	}
+	defer out.Close()
+
+	var written int64
+	for _, hash := range file.Chunks {
+		data, err := s.readChunk(hash)
+		if err != nil {
+			return fmt.Errorf("failed to restore %s: %w", file.Path, err)
+		}
+		if checksum.Bytes(data) != hash {
+			return fmt.Errorf("failed to restore %s: chunk %s is corrupt", file.Path, hash)
+		}
+		if _, err := out.Write(data); err != nil {
+			return fmt.Errorf("failed to restore %s: %w", file.Path, err)
+		}
+		written += int64(len(data))
+	}
+	if written != file.Size {
+		return fmt.Errorf("failed to restore %s: got %d bytes, expected %d", file.Path, written, file.Size)
+	}
+	return nil
+}
+
+// walkChunks calls fn for every chunk file in the store
+func (s *Store) walkChunks(fn func(hash, path string, info os.FileInfo) error) error {
+	err := filepath.Walk(s.chunksDir(), func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		hash := info.Name()
+		if !validHash(hash) {
+			// Leftovers of an interrupted write
+			if strings.HasSuffix(hash, ".tmp") || strings.HasSuffix(hash, ".part") {
+				return fn("", path, info)
+			}
+			return nil
+		}
+		return fn(hash, path, info)
+	})
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// GC deletes chunks that no manifest references. It must not run while a backup
+// is being written, as that backup's chunks are not referenced yet.
+func (s *Store) GC() (GCResult, error) {
+	var result GCResult
+
+	manifests, err := s.manifests()
+	if err != nil {
+		return result, fmt.Errorf("failed to read backup manifests: %w", err)
+	}
+	referenced := make(map[string]bool)
+	for _, manifest := range manifests {
+		for _, file := range manifest.Files {
+			for _, hash := range file.Chunks {
+				referenced[hash] = true
+			}
+		}
+	}
+
+	err = s.walkChunks(func(hash, path string, info os.FileInfo) error {
+		if referenced[hash] {
+			return nil
+		}
+		if err := os.Remove(path); err != nil {
+			return fmt.Errorf("failed to delete chunk: %w", err)
+		}
+		result.Chunks++
+		result.Bytes += info.Size()
+		return nil
+	})
+	return result, err
+}
+
+// Verify re-hashes every chunk and checks that every manifest's chunks exist
+func (s *Store) Verify() (VerifyResult, error) {
+	var result VerifyResult
+
+	err := s.walkChunks(func(hash, path string, info os.FileInfo) error {
+		if hash == "" {
+			return nil
+		}
+		result.Chunks++
+		if err := verifyChunkFile(path, hash); err != nil {
+			result.Corrupt = append(result.Corrupt, hash)
+		}
+		return nil
+	})
+	if err != nil {
+		return result, fmt.Errorf("failed to read chunks: %w", err)
+	}
+
+	manifests, err := s.manifests()
+	if err != nil {
+		return result, fmt.Errorf("failed to read backup manifests: %w", err)
+	}
+	for _, manifest := range manifests {
+		for _, file := range manifest.Files {
+			for _, hash := range file.Chunks {
+				if !validHash(hash) || !exists(s.chunkPath(hash)) {
+					result.Missing = append(result.Missing, fmt.Sprintf("%s/%s: %s", manifest.Server, manifest.ID, hash))
+				}
+			}
+		}
+	}
+	return result, nil
+}
+
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+func validHash(hash string) bool {
+	if len(hash) != 64 {
+		return false
+	}
+	for _, c := range hash {
+		if !strings.ContainsRune("0123456789abcdef", c) {
+			return false
+		}
+	}
+	return true
+}
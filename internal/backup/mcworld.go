@@ -0,0 +1,251 @@
+package backup
+
+import (
+	"archive/zip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// ErrInvalidWorld is returned for an uploaded file that is not a usable .mcworld
+var ErrInvalidWorld = errors.New("invalid .mcworld")
+
+const (
+	// maxWorldSize bounds the unpacked size of an imported world
+	maxWorldSize = 8 << 30
+
+	// maxLevelNameSize bounds levelname.txt, which holds a single display name
+	maxLevelNameSize = 1024
+)
+
+// WriteMCWorld streams a world as a .mcworld zip, with the world's files at the
+// root as Bedrock expects. files are relative to worldsDir and are truncated to
+// their reported sizes, like a backup.
+func WriteMCWorld(w io.Writer, worldsDir, world string, files []File) error {
+	zw := zip.NewWriter(w)
+
+	for _, file := range files {
+		name, err := cleanPath(file.Path)
+		if err != nil {
+			return err
+		}
+		rel, ok := strings.CutPrefix(name, world+"/")
+		if !ok {
+			return fmt.Errorf("world file %s is outside world %s", name, world)
+		}
+		if err := addZipFile(zw, filepath.Join(worldsDir, filepath.FromSlash(name)), rel, file.Size); err != nil {
+			return err
+		}
+	}
+
+	return zw.Close()
+}
+
+func addZipFile(zw *zip.Writer, source, name string, size int64) error {
+	src, err := os.Open(source)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", name, err)
+	}
+	defer src.Close()
+
+	stat, err := src.Stat()
+	if err != nil {
+		return err
+	}
+
+	header := &zip.FileHeader{
+		Name:     name,
+		Method:   zip.Deflate,
+		Modified: stat.ModTime(),
+	}
+	header.SetMode(stat.Mode().Perm())
+
+	dst, err := zw.CreateHeader(header)
+	if err != nil {
+		return err
+	}
+	if _, err := io.CopyN(dst, src, size); err != nil {
+		return fmt.Errorf("failed to copy %s: %w", name, err)
+	}
+	return nil
+}
+
+// UnpackMCWorld validates a .mcworld and unpacks it into dir, returning the name
+// from levelname.txt. Worlds zipped inside a single folder are accepted too.
+func UnpackMCWorld(r io.ReaderAt, size int64, dir string) (string, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return "", fmt.Errorf("%w: not a zip file", ErrInvalidWorld)
+	}
+
+	root, err := worldRoot(zr.File)
+	if err != nil {
+		return "", err
+	}
+
+	var total uint64
+	levelName := ""
+	for _, file := range zr.File {
+		if file.FileInfo().IsDir() {
+			continue
+		}
+		name, err := cleanPath(file.Name)
+		if err != nil {
+			return "", fmt.Errorf("%w: %v", ErrInvalidWorld, err)
+		}
+		rel, ok := strings.CutPrefix(name, root)
+		if !ok {
+			return "", fmt.Errorf("%w: %s is outside the world folder", ErrInvalidWorld, name)
+		}
+		if !file.Mode().IsRegular() {
+			return "", fmt.Errorf("%w: %s is not a regular file", ErrInvalidWorld, name)
+		}
+
+		total += file.UncompressedSize64
+		if total > maxWorldSize {
+			return "", fmt.Errorf("%w: unpacks to more than %d bytes", ErrInvalidWorld, uint64(maxWorldSize))
+		}
+
+		target := filepath.Join(dir, filepath.FromSlash(rel))
+		if err := unzipFile(file, target); err != nil {
+			return "", err
+		}
+
+		switch rel {
+		case "level.dat":
+			if err := validateLevelDat(target); err != nil {
+				return "", err
+			}
+		case "levelname.txt":
+			if levelName, err = readLevelName(target); err != nil {
+				return "", err
+			}
+		}
+	}
+	return levelName, nil
+}
+
+// worldRoot returns the prefix of level.dat: empty for a world at the zip's root,
+// or "folder/" for one wrapped in a single folder
+func worldRoot(files []*zip.File) (string, error) {
+	var candidates []string
+	for _, file := range files {
+		name := path.Clean(file.Name)
+		if path.Base(name) != "level.dat" || strings.Count(name, "/") > 1 {
+			continue
+		}
+		candidates = append(candidates, strings.TrimSuffix(name, "level.dat"))
+	}
+
+	switch len(candidates) {
+	case 0:
+		return "", fmt.Errorf("%w: level.dat not found", ErrInvalidWorld)
+	case 1:
+		return candidates[0], nil
+	default:
+		// A root level.dat wins over one in a folder
+		for _, candidate := range candidates {
+			if candidate == "" {
+				return "", nil
+			}
+		}
+		return "", fmt.Errorf("%w: more than one world found", ErrInvalidWorld)
+	}
+}
+
+func unzipFile(file *zip.File, target string) error {
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+
+	src, err := file.Open()
+	if err != nil {
+		return fmt.Errorf("%w: %s: %v", ErrInvalidWorld, file.Name, err)
+	}
+	defer src.Close()
+
+	out, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", file.Name, err)
+	}
+	defer out.Close()
+
+	// The header's size is not trusted; never write more than it claims
+	written, err := io.Copy(out, io.LimitReader(src, int64(file.UncompressedSize64)+1))
+	if err != nil {
+		return fmt.Errorf("%w: %s: %v", ErrInvalidWorld, file.Name, err)
+	}
+	if uint64(written) != file.UncompressedSize64 {
+		return fmt.Errorf("%w: %s does not match its recorded size", ErrInvalidWorld, file.Name)
+	}
+	return nil
+}
+
+// validateLevelDat checks Bedrock's level.dat header: a storage version and the
+// length of the little-endian NBT that follows
+func validateLevelDat(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if len(data) < 8 {
+		return fmt.Errorf("%w: level.dat is too short", ErrInvalidWorld)
+	}
+	length := binary.LittleEndian.Uint32(data[4:8])
+	if uint64(length) != uint64(len(data)-8) {
+		return fmt.Errorf("%w: level.dat header says %d bytes, file has %d", ErrInvalidWorld, length, len(data)-8)
+	}
+	// The NBT root is a compound tag
+	if len(data) > 8 && data[8] != 0x0a {
+		return fmt.Errorf("%w: level.dat is not Bedrock NBT", ErrInvalidWorld)
+	}
+	return nil
+}
+
+func readLevelName(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	if len(data) > maxLevelNameSize || !utf8.Valid(data) {
+		return "", fmt.Errorf("%w: levelname.txt is not a world name", ErrInvalidWorld)
+	}
+	name := strings.TrimSpace(string(data))
+	if strings.ContainsAny(name, "\r\n") {
+		return "", fmt.Errorf("%w: levelname.txt has more than one line", ErrInvalidWorld)
+	}
+	return name, nil
+}
+
+// InstallWorld moves an unpacked world from dir into place as world inside
+// worldsDir. An existing world is moved aside to "<world>.<reason>-<time>" and
+// that path is returned so it can be rolled back.
+func InstallWorld(dir, worldsDir, world, reason string) (string, error) {
+	if _, err := cleanPath(world); err != nil || strings.Contains(world, "/") {
+		return "", fmt.Errorf("invalid world name %q", world)
+	}
+
+	worldDir := filepath.Join(worldsDir, world)
+	previous := ""
+	if _, err := os.Stat(worldDir); err == nil {
+		previous = worldDir + "." + reason + "-" + time.Now().UTC().Format("20060102-150405")
+		if err := os.Rename(worldDir, previous); err != nil {
+			return "", fmt.Errorf("failed to move current world aside: %w", err)
+		}
+	}
+
+	if err := os.Rename(dir, worldDir); err != nil {
+		if previous != "" {
+			os.Rename(previous, worldDir)
+		}
+		return "", fmt.Errorf("failed to move world into place: %w", err)
+	}
+	return previous, nil
+}
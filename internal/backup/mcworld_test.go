@@ -0,0 +1,75 @@
+package backup
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"minecraft-server-manager/internal/testutil"
+)
+
+// levelDat returns a minimal Bedrock level.dat: header plus an empty compound tag
+func levelDat() string {
+	nbt := []byte{0x0a, 0x00, 0x00, 0x00}
+	header := make([]byte, 8)
+	binary.LittleEndian.PutUint32(header[0:4], 10)
+	binary.LittleEndian.PutUint32(header[4:8], uint32(len(nbt)))
+	return string(append(header, nbt...))
+}
+
+func TestMCWorldRoundTrip(t *testing.T) {
+	worldsDir := t.TempDir()
+	testutil.WriteFile(t, filepath.Join(worldsDir, "world", "level.dat"), levelDat())
+	testutil.WriteFile(t, filepath.Join(worldsDir, "world", "levelname.txt"), "Castle\n")
+	testutil.WriteFile(t, filepath.Join(worldsDir, "world", "db", "000005.ldb"), "table contents")
+
+	files, err := WalkFiles(worldsDir, "world")
+	if err != nil {
+		t.Fatalf("WalkFiles failed: %v", err)
+	}
+	var buf bytes.Buffer
+	if err := WriteMCWorld(&buf, worldsDir, "world", files); err != nil {
+		t.Fatalf("WriteMCWorld failed: %v", err)
+	}
+
+	dir := t.TempDir()
+	name, err := UnpackMCWorld(bytes.NewReader(buf.Bytes()), int64(buf.Len()), dir)
+	if err != nil {
+		t.Fatalf("UnpackMCWorld failed: %v", err)
+	}
+	if name != "Castle" {
+		t.Errorf("Expected level name Castle, got %q", name)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "db", "000005.ldb")); string(data) != "table contents" {
+		t.Errorf("Expected the world's files at the root, got %q", data)
+	}
+}
+
+func TestUnpackMCWorldRejectsInvalid(t *testing.T) {
+	cases := map[string]map[string]string{
+		"no level.dat":      {"levelname.txt": "Castle"},
+		"bad level.dat":     {"level.dat": "not a level"},
+		"path traversal":    {"level.dat": levelDat(), "../../etc/cron.d/evil": "boom"},
+		"multi-line name":   {"level.dat": levelDat(), "levelname.txt": "Castle\nEvil"},
+		"two world folders": {"a/level.dat": levelDat(), "b/level.dat": levelDat()},
+	}
+
+	for name, files := range cases {
+		var buf bytes.Buffer
+		zw := zip.NewWriter(&buf)
+		for path, content := range files {
+			w, _ := zw.Create(path)
+			w.Write([]byte(content))
+		}
+		zw.Close()
+
+		_, err := UnpackMCWorld(bytes.NewReader(buf.Bytes()), int64(buf.Len()), t.TempDir())
+		if !errors.Is(err, ErrInvalidWorld) {
+			t.Errorf("%s: expected ErrInvalidWorld, got %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,313 @@
+package backup
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Remote is an object store that backups are replicated to. Keys use the same
+// layout as the local store, e.g. "store/chunks/ab/<hash>" and "<server>/<id>.json".
+type Remote interface {
+	Put(ctx context.Context, key string, r io.Reader, size int64) error
+	// Get returns ErrNotFound for a missing key
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	Exists(ctx context.Context, key string) (bool, error)
+	// List returns every key starting with prefix
+	List(ctx context.Context, prefix string) ([]string, error)
+}
+
+func chunkKey(hash string) string {
+	return path.Join("store", "chunks", hash[:2], hash)
+}
+
+// Replicate uploads a backup and the chunks the remote does not have yet. The
+// metadata goes last, so a backup only shows up remotely once it is complete.
+func (s *Store) Replicate(ctx context.Context, remote Remote, info *Info) error {
+	infoKey := path.Join(info.Server, info.ID+".json")
+	if done, err := remote.Exists(ctx, infoKey); err != nil {
+		return err
+	} else if done {
+		return nil
+	}
+
+	if info.Format == FormatChunked {
+		manifest, err := s.readManifest(info.Server, info.ID)
+		if err != nil {
+			return fmt.Errorf("failed to read backup manifest: %w", err)
+		}
+
+		uploaded := make(map[string]bool)
+		for _, file := range manifest.Files {
+			for _, hash := range file.Chunks {
+				if uploaded[hash] {
+					continue
+				}
+				if err := s.replicateChunk(ctx, remote, hash); err != nil {
+					return err
+				}
+				uploaded[hash] = true
+			}
+		}
+	}
+
+	if err := putFile(ctx, remote, s.dataPath(info), path.Join(info.Server, filepath.Base(s.dataPath(info)))); err != nil {
+		return err
+	}
+	return putFile(ctx, remote, filepath.Join(s.serverDir(info.Server), info.ID+".json"), infoKey)
+}
+
+func (s *Store) replicateChunk(ctx context.Context, remote Remote, hash string) error {
+	key := chunkKey(hash)
+	exists, err := remote.Exists(ctx, key)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return nil
+	}
+	return putFile(ctx, remote, s.chunkPath(hash), key)
+}
+
+func putFile(ctx context.Context, remote Remote, localPath, key string) error {
+	file, err := os.Open(localPath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	stat, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	if err := remote.Put(ctx, key, file, stat.Size()); err != nil {
+		return fmt.Errorf("failed to upload %s: %w", key, err)
+	}
+	return nil
+}
+
+// ListRemote returns a server's replicated backups, newest first
+func (s *Store) ListRemote(ctx context.Context, remote Remote, server string) ([]Info, error) {
+	keys, err := remote.List(ctx, server+"/")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list remote backups: %w", err)
+	}
+
+	backups := []Info{}
+	for _, key := range keys {
+		id, ok := strings.CutSuffix(path.Base(key), ".json")
+		if !ok || strings.HasSuffix(id, ".manifest") || path.Dir(key) != server {
+			continue
+		}
+		info, err := getInfo(ctx, remote, server, id)
+		if err != nil {
+			return nil, err
+		}
+		backups = append(backups, *info)
+	}
+
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].CreatedAt.After(backups[j].CreatedAt)
+	})
+	return backups, nil
+}
+
+func getInfo(ctx context.Context, remote Remote, server, id string) (*Info, error) {
+	if !validID(id) {
+		return nil, ErrNotFound
+	}
+
+	data, err := getObject(ctx, remote, path.Join(server, id+".json"))
+	if err != nil {
+		return nil, err
+	}
+	var info Info
+	if err := json.Unmarshal(data, &info); err != nil {
+		return nil, fmt.Errorf("failed to parse remote backup metadata for %s: %w", id, err)
+	}
+	if info.Server != server || info.ID != id {
+		return nil, fmt.Errorf("remote backup metadata for %s/%s names %s/%s", server, id, info.Server, info.ID)
+	}
+	return &info, nil
+}
+
+func getObject(ctx context.Context, remote Remote, key string) ([]byte, error) {
+	body, err := remote.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+	return io.ReadAll(body)
+}
+
+// Fetch downloads a replicated backup into the local store so it can be restored.
+// A backup the local store already has is returned as is.
+func (s *Store) Fetch(ctx context.Context, remote Remote, server, id string) (*Info, error) {
+	if info, err := s.Get(server, id); err == nil {
+		return info, nil
+	}
+
+	info, err := getInfo(ctx, remote, server, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(s.serverDir(server), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create backup directory: %w", err)
+	}
+
+	dataKey := path.Join(server, filepath.Base(s.dataPath(info)))
+	if info.Format == FormatChunked {
+		data, err := getObject(ctx, remote, dataKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to download backup manifest: %w", err)
+		}
+		var manifest Manifest
+		if err := json.Unmarshal(data, &manifest); err != nil {
+			return nil, fmt.Errorf("failed to parse remote backup manifest: %w", err)
+		}
+		for _, file := range manifest.Files {
+			for _, hash := range file.Chunks {
+				if err := s.fetchChunk(ctx, remote, hash); err != nil {
+					return nil, err
+				}
+			}
+		}
+		if err := writeFileAtomic(s.dataPath(info), bytes.NewReader(data)); err != nil {
+			return nil, err
+		}
+	} else {
+		body, err := remote.Get(ctx, dataKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to download backup archive: %w", err)
+		}
+		err = writeFileAtomic(s.dataPath(info), body)
+		body.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	data, err := json.MarshalIndent(info, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(filepath.Join(s.serverDir(server), id+".json"), data, 0644); err != nil {
+		return nil, fmt.Errorf("failed to write backup metadata: %w", err)
+	}
+	return info, nil
+}
+
+// fetchChunk downloads a missing chunk and only keeps it if its content matches its hash
+func (s *Store) fetchChunk(ctx context.Context, remote Remote, hash string) error {
+	if !validHash(hash) {
+		return fmt.Errorf("invalid chunk hash %q", hash)
+	}
+	if exists(s.chunkPath(hash)) {
+		return nil
+	}
+
+	body, err := remote.Get(ctx, chunkKey(hash))
+	if err != nil {
+		return fmt.Errorf("failed to download chunk %s: %w", hash, err)
+	}
+	defer body.Close()
+
+	if err := os.MkdirAll(filepath.Dir(s.chunkPath(hash)), 0755); err != nil {
+		return fmt.Errorf("failed to create chunk directory: %w", err)
+	}
+	tmpPath := s.chunkPath(hash) + ".tmp"
+	if err := writeFileAtomic(tmpPath, body); err != nil {
+		return err
+	}
+	if err := verifyChunkFile(tmpPath, hash); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	return os.Rename(tmpPath, s.chunkPath(hash))
+}
+
+// writeFileAtomic writes r to a temporary file and renames it into place
+func writeFileAtomic(target string, r io.Reader) error {
+	tmpPath := target + ".part"
+	out, err := os.Create(tmpPath)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", target, err)
+	}
+
+	_, err = io.Copy(out, r)
+	if err == nil {
+		err = out.Sync()
+	}
+	out.Close()
+	if err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to write %s: %w", target, err)
+	}
+	return os.Rename(tmpPath, target)
+}
+
+// MemoryRemote is an in-process Remote for tests and local experiments
+type MemoryRemote struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+}
+
+func NewMemoryRemote() *MemoryRemote {
+	return &MemoryRemote{objects: make(map[string][]byte)}
+}
+
+func (r *MemoryRemote) Put(ctx context.Context, key string, body io.Reader, size int64) error {
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return err
+	}
+	if int64(len(data)) != size {
+		return fmt.Errorf("read %d bytes of %s, expected %d", len(data), key, size)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.objects[key] = data
+	return nil
+}
+
+func (r *MemoryRemote) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	data, ok := r.objects[key]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+func (r *MemoryRemote) Exists(ctx context.Context, key string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	_, ok := r.objects[key]
+	return ok, nil
+}
+
+func (r *MemoryRemote) List(ctx context.Context, prefix string) ([]string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var keys []string
+	for key := range r.objects {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
@@ -0,0 +1,97 @@
+package backup
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"minecraft-server-manager/internal/config"
+	"minecraft-server-manager/internal/testutil"
+)
+
+func TestMemoryRemote(t *testing.T) {
+	testRemoteRoundTrip(t, NewMemoryRemote())
+}
+
+// TestS3Remote runs against a real S3-compatible store, e.g. a local MinIO:
+//
+//	docker run -p 9000:9000 minio/minio server /data
+//	MSM_TEST_S3_ENDPOINT=localhost:9000 MSM_TEST_S3_BUCKET=backups go test ./internal/backup/
+func TestS3Remote(t *testing.T) {
+	endpoint := os.Getenv("MSM_TEST_S3_ENDPOINT")
+	if endpoint == "" {
+		t.Skip("MSM_TEST_S3_ENDPOINT not set")
+	}
+
+	remote, err := NewS3Remote(config.RemoteConfig{
+		Endpoint:  endpoint,
+		Bucket:    os.Getenv("MSM_TEST_S3_BUCKET"),
+		Prefix:    "test-" + filepath.Base(t.TempDir()),
+		AccessKey: envOr("MSM_TEST_S3_ACCESS_KEY", "minioadmin"),
+		SecretKey: envOr("MSM_TEST_S3_SECRET_KEY", "minioadmin"),
+		PathStyle: true,
+	})
+	if err != nil {
+		t.Fatalf("NewS3Remote failed: %v", err)
+	}
+	testRemoteRoundTrip(t, remote)
+}
+
+func testRemoteRoundTrip(t *testing.T, remote Remote) {
+	ctx := context.Background()
+	worldsDir := t.TempDir()
+	local := NewStore(t.TempDir())
+
+	testutil.WriteFile(t, filepath.Join(worldsDir, "world", "level.dat"), "level data")
+	testutil.WriteFile(t, filepath.Join(worldsDir, "world", "db", "000005.ldb"), "table contents")
+	files, err := WalkFiles(worldsDir, "world")
+	if err != nil {
+		t.Fatalf("WalkFiles failed: %v", err)
+	}
+	info, err := local.Create("survival", "world", worldsDir, "cold", files)
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	if err := local.Replicate(ctx, remote, info); err != nil {
+		t.Fatalf("Replicate failed: %v", err)
+	}
+	// Replicating again is a no-op
+	if err := local.Replicate(ctx, remote, info); err != nil {
+		t.Fatalf("Second Replicate failed: %v", err)
+	}
+
+	// A fresh machine sees the backup and can restore it
+	other := NewStore(t.TempDir())
+	backups, err := other.ListRemote(ctx, remote, "survival")
+	if err != nil {
+		t.Fatalf("ListRemote failed: %v", err)
+	}
+	if len(backups) != 1 || backups[0].ID != info.ID {
+		t.Fatalf("Expected the replicated backup to be listed, got %+v", backups)
+	}
+
+	fetched, err := other.Fetch(ctx, remote, "survival", info.ID)
+	if err != nil {
+		t.Fatalf("Fetch failed: %v", err)
+	}
+	restoreDir := t.TempDir()
+	if _, err := other.Restore(fetched, restoreDir, "world"); err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(restoreDir, "world", "db", "000005.ldb")); string(data) != "table contents" {
+		t.Errorf("Expected restored table, got %q", data)
+	}
+
+	if _, err := other.Fetch(ctx, remote, "survival", "20000101-000000"); err == nil {
+		t.Error("Expected an error fetching a missing backup")
+	}
+}
+
+func envOr(name, fallback string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+	return fallback
+}
@@ -0,0 +1,62 @@
+package backup
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"minecraft-server-manager/internal/config"
+)
+
+// SelectExpired returns the backups that no retention rule keeps. Rules are applied
+// to the newest backups first, so a bucket is represented by its most recent backup.
+func SelectExpired(backups []Info, policy config.RetentionConfig) []Info {
+	if policy.IsZero() {
+		return nil
+	}
+
+	sorted := append([]Info(nil), backups...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].CreatedAt.After(sorted[j].CreatedAt)
+	})
+
+	keep := make(map[string]bool)
+	for i := 0; i < policy.KeepLast && i < len(sorted); i++ {
+		keep[sorted[i].ID] = true
+	}
+	keepBuckets(sorted, policy.KeepHourly, keep, func(t time.Time) string {
+		return t.Format("2006-01-02 15")
+	})
+	keepBuckets(sorted, policy.KeepDaily, keep, func(t time.Time) string {
+		return t.Format("2006-01-02")
+	})
+	keepBuckets(sorted, policy.KeepWeekly, keep, func(t time.Time) string {
+		year, week := t.ISOWeek()
+		return fmt.Sprintf("%d-W%02d", year, week)
+	})
+
+	var expired []Info
+	for _, info := range sorted {
+		if !keep[info.ID] {
+			expired = append(expired, info)
+		}
+	}
+	return expired
+}
+
+// keepBuckets keeps the newest backup of each of the first count buckets. Buckets
+// use local time so "daily" follows the host's calendar.
+func keepBuckets(sorted []Info, count int, keep map[string]bool, bucket func(time.Time) string) {
+	seen := make(map[string]bool)
+	for _, info := range sorted {
+		if len(seen) >= count {
+			return
+		}
+		key := bucket(info.CreatedAt.Local())
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		keep[info.ID] = true
+	}
+}
@@ -0,0 +1,124 @@
+package backup
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+
+	"minecraft-server-manager/internal/config"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+// S3Remote stores backups in an S3-compatible bucket such as AWS S3 or MinIO
+type S3Remote struct {
+	client   *minio.Client
+	bucket   string
+	prefix   string
+	partSize uint64
+}
+
+func NewS3Remote(cfg config.RemoteConfig) (*S3Remote, error) {
+	if cfg.Bucket == "" {
+		return nil, fmt.Errorf("backup remote needs a bucket")
+	}
+
+	lookup := minio.BucketLookupAuto
+	if cfg.PathStyle {
+		lookup = minio.BucketLookupPath
+	}
+
+	client, err := minio.New(cfg.Endpoint, &minio.Options{
+		Creds:        credentials.NewStaticV4(cfg.AccessKey, cfg.SecretKey, ""),
+		Secure:       cfg.UseSSL,
+		Region:       cfg.Region,
+		BucketLookup: lookup,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create S3 client: %w", err)
+	}
+
+	partSize := uint64(cfg.PartSizeMB) << 20
+	// S3 rejects parts smaller than 5 MiB
+	if partSize < 5<<20 {
+		partSize = 5 << 20
+	}
+
+	return &S3Remote{
+		client:   client,
+		bucket:   cfg.Bucket,
+		prefix:   strings.Trim(cfg.Prefix, "/"),
+		partSize: partSize,
+	}, nil
+}
+
+func (r *S3Remote) key(key string) string {
+	if r.prefix == "" {
+		return key
+	}
+	return path.Join(r.prefix, key)
+}
+
+// Put uploads an object, in parts once it is larger than the part size. Every part
+// carries a Content-MD5 header so the store rejects anything corrupted in transit.
+func (r *S3Remote) Put(ctx context.Context, key string, body io.Reader, size int64) error {
+	_, err := r.client.PutObject(ctx, r.bucket, r.key(key), body, size, minio.PutObjectOptions{
+		ContentType:    "application/octet-stream",
+		PartSize:       r.partSize,
+		SendContentMd5: true,
+	})
+	return err
+}
+
+func (r *S3Remote) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	object, err := r.client.GetObject(ctx, r.bucket, r.key(key), minio.GetObjectOptions{})
+	if err != nil {
+		return nil, err
+	}
+	// GetObject is lazy, so ask for the metadata to surface a missing key now
+	if _, err := object.Stat(); err != nil {
+		object.Close()
+		if isNotFound(err) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return object, nil
+}
+
+func (r *S3Remote) Exists(ctx context.Context, key string) (bool, error) {
+	_, err := r.client.StatObject(ctx, r.bucket, r.key(key), minio.StatObjectOptions{})
+	if err == nil {
+		return true, nil
+	}
+	if isNotFound(err) {
+		return false, nil
+	}
+	return false, err
+}
+
+func (r *S3Remote) List(ctx context.Context, prefix string) ([]string, error) {
+	var keys []string
+	for object := range r.client.ListObjects(ctx, r.bucket, minio.ListObjectsOptions{
+		Prefix:    r.key(prefix),
+		Recursive: true,
+	}) {
+		if object.Err != nil {
+			return nil, object.Err
+		}
+		key := object.Key
+		if r.prefix != "" {
+			key = strings.TrimPrefix(key, r.prefix+"/")
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+func isNotFound(err error) bool {
+	code := minio.ToErrorResponse(err).Code
+	return code == "NoSuchKey" || code == "NotFound"
+}
@@ -0,0 +1,279 @@
+// Package certs provides the TLS configuration of the management API: certificates
+// that are reloaded when rotated on disk, a self-signed certificate for LAN use and
+// optional client-certificate verification.
+package certs
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"minecraft-server-manager/internal/config"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// checkInterval is how often the certificate files are checked for changes
+	checkInterval = 10 * time.Second
+
+	// selfSignedValidity is how long a generated certificate is valid
+	selfSignedValidity = 2 * 365 * 24 * time.Hour
+
+	// renewBefore is how long before expiry a generated certificate is replaced
+	renewBefore = 30 * 24 * time.Hour
+)
+
+// ServerConfig returns the TLS configuration for the API, or nil when TLS is off.
+// A self-signed certificate is generated under baseDir/tls if needed.
+func ServerConfig(cfg config.TLSConfig, baseDir string, logger *logrus.Logger) (*tls.Config, error) {
+	if !cfg.Enabled() {
+		return nil, nil
+	}
+
+	certFile, keyFile := cfg.CertFile, cfg.KeyFile
+	switch {
+	case certFile != "" && keyFile == "":
+		return nil, errors.New("tls.key_file is required with tls.cert_file")
+	case certFile == "":
+		dir := filepath.Join(baseDir, "tls")
+		certFile, keyFile = filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+		created, err := EnsureSelfSigned(certFile, keyFile, cfg.Hosts)
+		if err != nil {
+			return nil, err
+		}
+		fingerprint, err := Fingerprint(certFile)
+		if err != nil {
+			return nil, err
+		}
+		if created {
+			logger.Infof("Generated self-signed certificate %s", certFile)
+		}
+		logger.Infof("Self-signed certificate SHA-256 fingerprint: %s", fingerprint)
+	}
+
+	reloader, err := NewReloader(certFile, keyFile, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	tlsConfig := &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: reloader.GetCertificate,
+	}
+	if cfg.ClientCA != "" {
+		pool, err := loadCA(cfg.ClientCA)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.ClientCAs = pool
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return tlsConfig, nil
+}
+
+func loadCA(path string) (*x509.CertPool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read client CA: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("no certificates found in client CA %s", path)
+	}
+	return pool, nil
+}
+
+// Reloader serves a certificate and key pair, loading them again when either
+// file changes. A pair that fails to load keeps the previous one in use.
+type Reloader struct {
+	certFile, keyFile string
+	logger            *logrus.Logger
+
+	mu      sync.Mutex
+	cert    *tls.Certificate
+	modTime time.Time
+	checked time.Time
+}
+
+// NewReloader loads a certificate and key pair
+func NewReloader(certFile, keyFile string, logger *logrus.Logger) (*Reloader, error) {
+	r := &Reloader{certFile: certFile, keyFile: keyFile, logger: logger}
+	modTime, err := r.latestModTime()
+	if err != nil {
+		return nil, err
+	}
+	if err := r.load(modTime); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// GetCertificate returns the current certificate, for tls.Config
+func (r *Reloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if time.Since(r.checked) >= checkInterval {
+		r.checked = time.Now()
+		modTime, err := r.latestModTime()
+		if err != nil {
+			r.logger.Warnf("Failed to check TLS certificate, keeping the current one: %v", err)
+		} else if !modTime.Equal(r.modTime) {
+			if err := r.load(modTime); err != nil {
+				r.logger.Warnf("Failed to reload TLS certificate, keeping the current one: %v", err)
+			} else {
+				r.logger.Infof("Reloaded TLS certificate %s", r.certFile)
+			}
+		}
+	}
+	return r.cert, nil
+}
+
+// latestModTime returns the later modification time of the certificate and key
+func (r *Reloader) latestModTime() (time.Time, error) {
+	var latest time.Time
+	for _, path := range []string{r.certFile, r.keyFile} {
+		info, err := os.Stat(path)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("failed to stat %s: %w", path, err)
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest, nil
+}
+
+func (r *Reloader) load(modTime time.Time) error {
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return fmt.Errorf("failed to load TLS certificate: %w", err)
+	}
+	r.cert = &cert
+	r.modTime = modTime
+	r.checked = time.Now()
+	return nil
+}
+
+// EnsureSelfSigned writes a self-signed certificate and key unless a valid one
+// already exists, and reports whether it wrote one. The certificate covers
+// localhost, the host name, the host's addresses and hosts.
+func EnsureSelfSigned(certFile, keyFile string, hosts []string) (bool, error) {
+	if cert, err := readCertificate(certFile); err == nil && time.Until(cert.NotAfter) > renewBefore {
+		if _, err := os.Stat(keyFile); err == nil {
+			return false, nil
+		}
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return false, fmt.Errorf("failed to generate key: %w", err)
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return false, fmt.Errorf("failed to generate serial number: %w", err)
+	}
+
+	hostname, _ := os.Hostname()
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: hostname, Organization: []string{"minecraft-server-manager"}},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(selfSignedValidity),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+	}
+	for _, host := range append([]string{"localhost", "127.0.0.1", "::1", hostname}, append(localAddresses(), hosts...)...) {
+		if ip := net.ParseIP(host); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else if host != "" {
+			template.DNSNames = append(template.DNSNames, host)
+		}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return false, fmt.Errorf("failed to create certificate: %w", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return false, fmt.Errorf("failed to encode key: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(certFile), 0700); err != nil {
+		return false, fmt.Errorf("failed to create %s: %w", filepath.Dir(certFile), err)
+	}
+	// The key goes first so the reloader never sees a new certificate with the old key
+	if err := writePEM(keyFile, "EC PRIVATE KEY", keyDER, 0600); err != nil {
+		return false, err
+	}
+	if err := writePEM(certFile, "CERTIFICATE", der, 0644); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// localAddresses returns the addresses of the host's interfaces
+func localAddresses() []string {
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return nil
+	}
+	var hosts []string
+	for _, addr := range addrs {
+		if ipNet, ok := addr.(*net.IPNet); ok && !ipNet.IP.IsLoopback() && !ipNet.IP.IsLinkLocalUnicast() {
+			hosts = append(hosts, ipNet.IP.String())
+		}
+	}
+	return hosts
+}
+
+func writePEM(path, blockType string, der []byte, perm os.FileMode) error {
+	data := pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der})
+	if err := os.WriteFile(path+".tmp", data, perm); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return os.Rename(path+".tmp", path)
+}
+
+func readCertificate(path string) (*x509.Certificate, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "CERTIFICATE" {
+		return nil, fmt.Errorf("no certificate in %s", path)
+	}
+	return x509.ParseCertificate(block.Bytes)
+}
+
+// Fingerprint returns the SHA-256 of a certificate, for clients to pin
+func Fingerprint(certFile string) (string, error) {
+	cert, err := readCertificate(certFile)
+	if err != nil {
+		return "", fmt.Errorf("failed to read certificate: %w", err)
+	}
+	return fingerprintOf(cert.Raw), nil
+}
+
+func fingerprintOf(der []byte) string {
+	sum := sha256.Sum256(der)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,70 @@
+package certs
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestSelfSignedReload(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+
+	created, err := EnsureSelfSigned(certFile, keyFile, []string{"mc.lan", "10.8.0.1"})
+	if err != nil || !created {
+		t.Fatalf("EnsureSelfSigned failed: %v (created %v)", err, created)
+	}
+	if created, err := EnsureSelfSigned(certFile, keyFile, nil); err != nil || created {
+		t.Fatalf("Expected the existing certificate to be kept, got %v (created %v)", err, created)
+	}
+
+	cert, err := readCertificate(certFile)
+	if err != nil {
+		t.Fatalf("Failed to read certificate: %v", err)
+	}
+	if err := cert.VerifyHostname("mc.lan"); err != nil {
+		t.Errorf("Expected the configured host to be covered: %v", err)
+	}
+	if err := cert.VerifyHostname("10.8.0.1"); err != nil {
+		t.Errorf("Expected the configured address to be covered: %v", err)
+	}
+
+	reloader, err := NewReloader(certFile, keyFile, logrus.New())
+	if err != nil {
+		t.Fatalf("NewReloader failed: %v", err)
+	}
+	before, _ := Fingerprint(certFile)
+
+	// Rotate the certificate; the reloader notices on its next check
+	os.Remove(certFile)
+	if _, err := EnsureSelfSigned(certFile, keyFile, nil); err != nil {
+		t.Fatalf("EnsureSelfSigned failed: %v", err)
+	}
+	future := time.Now().Add(time.Minute)
+	os.Chtimes(certFile, future, future)
+	after, _ := Fingerprint(certFile)
+	if before == after {
+		t.Fatal("Expected a new certificate")
+	}
+
+	served, _ := reloader.GetCertificate(nil)
+	if fingerprintOf(served.Certificate[0]) != before {
+		t.Error("Expected the old certificate until the next check")
+	}
+	reloader.checked = time.Time{}
+	served, _ = reloader.GetCertificate(nil)
+	if fingerprintOf(served.Certificate[0]) != after {
+		t.Error("Expected the rotated certificate after the check")
+	}
+
+	// A broken file keeps the current certificate
+	os.WriteFile(certFile, []byte("garbage"), 0644)
+	os.Chtimes(certFile, future.Add(time.Minute), future.Add(time.Minute))
+	reloader.checked = time.Time{}
+	if served, _ = reloader.GetCertificate(nil); fingerprintOf(served.Certificate[0]) != after {
+		t.Error("Expected the current certificate to be kept")
+	}
+}
@@ -0,0 +1,36 @@
+// Package checksum computes the SHA-256 digests used to verify downloads and
+// address backup chunks.
+package checksum
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+)
+
+// File returns the hex encoded SHA-256 of a file's contents
+func File(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	return Reader(file)
+}
+
+// Reader returns the hex encoded SHA-256 of everything read from r
+func Reader(r io.Reader) (string, error) {
+	hash := sha256.New()
+	if _, err := io.Copy(hash, r); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// Bytes returns the hex encoded SHA-256 of data
+func Bytes(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,294 @@
+// Package cli talks to a running manager over its HTTP API, on a TCP address or
+// a local Unix socket, for the operator subcommands of the binary.
+package cli
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"minecraft-server-manager/internal/backup"
+	"minecraft-server-manager/internal/server"
+)
+
+// unixPrefix marks an address as the path of a Unix socket
+const unixPrefix = "unix://"
+
+// Options says where the manager is and how to authenticate to it
+type Options struct {
+	Addr     string // http://host:port, https://host:port or unix:///path/to/socket
+	Token    string
+	CAFile   string // CA to verify an HTTPS manager with, such as its self-signed certificate
+	CertFile string // Client certificate for a manager requiring one
+	KeyFile  string
+	Insecure bool // Skip verifying the manager's certificate
+}
+
+// Client calls the manager's API
+type Client struct {
+	base  string
+	token string
+	http  *http.Client
+}
+
+// APIError is an error response from the manager
+type APIError struct {
+	Status  int
+	Message string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("%s (HTTP %d)", e.Message, e.Status)
+}
+
+// New returns a client for the manager at opts.Addr
+func New(opts Options) (*Client, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	c := &Client{token: opts.Token, http: &http.Client{Transport: transport}}
+
+	addr := opts.Addr
+	switch {
+	case strings.HasPrefix(addr, unixPrefix):
+		socket := strings.TrimPrefix(addr, unixPrefix)
+		transport.DialContext = func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var dialer net.Dialer
+			return dialer.DialContext(ctx, "unix", socket)
+		}
+		// The host is ignored by the dialer
+		c.base = "http://manager"
+		return c, nil
+	case !strings.Contains(addr, "://"):
+		addr = "http://" + addr
+	}
+
+	u, err := url.Parse(addr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid address %q: %w", opts.Addr, err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("invalid address %q: use http://, https:// or unix://", opts.Addr)
+	}
+	c.base = strings.TrimSuffix(u.String(), "/")
+
+	if u.Scheme == "https" {
+		tlsConfig := &tls.Config{InsecureSkipVerify: opts.Insecure}
+		if opts.CAFile != "" {
+			data, err := os.ReadFile(opts.CAFile)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read CA: %w", err)
+			}
+			pool := x509.NewCertPool()
+			if !pool.AppendCertsFromPEM(data) {
+				return nil, fmt.Errorf("no certificates found in %s", opts.CAFile)
+			}
+			tlsConfig.RootCAs = pool
+		}
+		if opts.CertFile != "" {
+			cert, err := tls.LoadX509KeyPair(opts.CertFile, opts.KeyFile)
+			if err != nil {
+				return nil, fmt.Errorf("failed to load client certificate: %w", err)
+			}
+			tlsConfig.Certificates = []tls.Certificate{cert}
+		}
+		transport.TLSClientConfig = tlsConfig
+	}
+	return c, nil
+}
+
+// Status returns the manager's status
+func (c *Client) Status(ctx context.Context) (*server.ManagerStatus, error) {
+	var status server.ManagerStatus
+	if err := c.do(ctx, http.MethodGet, "/status", nil, &status); err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+// Servers returns the status of every configured server
+func (c *Client) Servers(ctx context.Context) ([]server.ServerStatus, error) {
+	var servers []server.ServerStatus
+	if err := c.do(ctx, http.MethodGet, "/servers", nil, &servers); err != nil {
+		return nil, err
+	}
+	return servers, nil
+}
+
+// Server returns the status of one server
+func (c *Client) Server(ctx context.Context, name string) (*server.ServerStatus, error) {
+	var status server.ServerStatus
+	if err := c.do(ctx, http.MethodGet, serverPath(name, ""), nil, &status); err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+// Restart restarts a server and waits for the operation to finish
+func (c *Client) Restart(ctx context.Context, name string) (*server.Operation, error) {
+	var op server.Operation
+	if err := c.do(ctx, http.MethodPost, serverPath(name, "/restart"), nil, &op); err != nil {
+		return nil, err
+	}
+	for op.Status == server.OperationRunning {
+		select {
+		case <-ctx.Done():
+			return &op, ctx.Err()
+		case <-time.After(time.Second):
+		}
+		if err := c.do(ctx, http.MethodGet, "/operations/"+url.PathEscape(op.ID), nil, &op); err != nil {
+			return nil, err
+		}
+	}
+	return &op, nil
+}
+
+// Backup backs up a server's world
+func (c *Client) Backup(ctx context.Context, name string) (*backup.Info, error) {
+	var info backup.Info
+	if err := c.do(ctx, http.MethodPost, serverPath(name, "/backups"), nil, &info); err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
+
+// Reload makes the manager re-read its configuration
+func (c *Client) Reload(ctx context.Context) (*server.ReloadReport, error) {
+	var report server.ReloadReport
+	if err := c.do(ctx, http.MethodPost, "/admin/reload", nil, &report); err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
+
+// Logs returns a server's recent console output
+func (c *Client) Logs(ctx context.Context, name string) ([]string, error) {
+	var logs struct {
+		Lines []string `json:"lines"`
+	}
+	if err := c.do(ctx, http.MethodGet, serverPath(name, "/logs"), nil, &logs); err != nil {
+		return nil, err
+	}
+	return logs.Lines, nil
+}
+
+// FollowLogs calls fn with a server's recent console output and then every new
+// line, until the server stops or ctx is cancelled
+func (c *Client) FollowLogs(ctx context.Context, name string, fn func(line string)) error {
+	resp, err := c.request(ctx, http.MethodGet, serverPath(name, "/logs")+"?follow=true", "", nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	// Server-sent events: "data:" lines, an optional "event:" and a blank line
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	event := ""
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "event: "):
+			event = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			if event == "exit" {
+				return nil
+			}
+			fn(strings.TrimPrefix(line, "data: "))
+		case line == "":
+			event = ""
+		}
+	}
+	if ctx.Err() != nil {
+		return nil
+	}
+	return scanner.Err()
+}
+
+// Attach connects to a server's console: every line read from input is sent as a
+// command and fn is called with each line of output, until input ends, the
+// server stops or ctx is cancelled. Lines starting with "! " come from the manager.
+func (c *Client) Attach(ctx context.Context, name string, input io.Reader, fn func(line string)) error {
+	resp, err := c.request(ctx, http.MethodPost, serverPath(name, "/console/attach"), "text/plain", input)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		fn(scanner.Text())
+	}
+	if ctx.Err() != nil {
+		return nil
+	}
+	return scanner.Err()
+}
+
+func serverPath(name, suffix string) string {
+	return "/servers/" + url.PathEscape(name) + suffix
+}
+
+// do makes a request and decodes a JSON response into out, unless out is nil
+func (c *Client) do(ctx context.Context, method, path string, body io.Reader, out interface{}) error {
+	resp, err := c.request(ctx, method, path, "application/json", body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
+
+// request makes a request, turning an error status into an APIError
+func (c *Client) request(ctx context.Context, method, path, contentType string, body io.Reader) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, c.base+path, body)
+	if err != nil {
+		return nil, err
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach the manager: %w", err)
+	}
+	if resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		var apiErr struct {
+			Error string `json:"error"`
+		}
+		message := http.StatusText(resp.StatusCode)
+		if json.NewDecoder(resp.Body).Decode(&apiErr) == nil && apiErr.Error != "" {
+			message = apiErr.Error
+		}
+		return nil, &APIError{Status: resp.StatusCode, Message: message}
+	}
+	return resp, nil
+}
+
+// IsStatus reports whether err is an API error with the given HTTP status
+func IsStatus(err error, status int) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.Status == status
+}
@@ -0,0 +1,88 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"minecraft-server-manager/internal/server"
+)
+
+// fakeManager answers the routes the client uses
+func fakeManager() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/servers", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprint(w, `{"error":"missing API token"}`)
+			return
+		}
+		fmt.Fprint(w, `[{"name":"survival","status":"running","player_count":2,"version":"1.20.50"},
+			{"name":"creative","status":"stopped","override":{"state":"stopped","by":"alice"}}]`)
+	})
+	mux.HandleFunc("/servers/survival/logs", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "data: first\n\ndata: second\n\n: keepalive\n\nevent: exit\ndata: server stopped\n\n")
+	})
+	return mux
+}
+
+func TestClient(t *testing.T) {
+	api := httptest.NewServer(fakeManager())
+	defer api.Close()
+
+	client, err := New(Options{Addr: strings.TrimPrefix(api.URL, "http://"), Token: "secret"})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	servers, err := client.Servers(context.Background())
+	if err != nil || len(servers) != 2 {
+		t.Fatalf("Expected 2 servers, got %v (%v)", servers, err)
+	}
+
+	var table bytes.Buffer
+	PrintServers(&table, servers)
+	lines := strings.Split(strings.TrimSpace(table.String()), "\n")
+	if len(lines) != 3 || !strings.HasPrefix(lines[1], "survival  running  2") || !strings.HasSuffix(lines[2], "stopped by alice") {
+		t.Errorf("Unexpected table:\n%s", table.String())
+	}
+
+	var followed []string
+	if err := client.FollowLogs(context.Background(), "survival", func(line string) { followed = append(followed, line) }); err != nil {
+		t.Fatalf("FollowLogs failed: %v", err)
+	}
+	if strings.Join(followed, ",") != "first,second" {
+		t.Errorf("Expected the log lines before the exit event, got %v", followed)
+	}
+
+	anonymous, _ := New(Options{Addr: api.URL})
+	if _, err := anonymous.Servers(context.Background()); !IsStatus(err, http.StatusUnauthorized) || !strings.Contains(err.Error(), "missing API token") {
+		t.Errorf("Expected the API's 401 error, got %v", err)
+	}
+}
+
+func TestUnixSocket(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "admin.sock")
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Skipf("Unix sockets unavailable: %v", err)
+	}
+	api := &http.Server{Handler: fakeManager()}
+	go api.Serve(listener)
+	defer api.Close()
+
+	client, err := New(Options{Addr: "unix://" + socket, Token: "secret"})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	servers, err := client.Servers(context.Background())
+	if err != nil || len(servers) != 2 || servers[0].Status != "running" || servers[1].Override.State != server.OverrideStopped {
+		t.Errorf("Expected the servers over the socket, got %+v (%v)", servers, err)
+	}
+}
@@ -0,0 +1,129 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"minecraft-server-manager/internal/backup"
+	"minecraft-server-manager/internal/server"
+)
+
+// PrintJSON writes v as indented JSON
+func PrintJSON(w io.Writer, v interface{}) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
+
+// PrintStatus writes a summary of the manager and a table of its servers
+func PrintStatus(w io.Writer, status *server.ManagerStatus, servers []server.ServerStatus) error {
+	summary := fmt.Sprintf("%d running, %d stopped", status.Running, status.Stopped)
+	if status.ConfigCommit != "" {
+		summary += ", config " + shortCommit(status.ConfigCommit)
+	}
+	fmt.Fprintln(w, summary)
+	fmt.Fprintln(w)
+	return PrintServers(w, servers)
+}
+
+// PrintServers writes a table of servers
+func PrintServers(w io.Writer, servers []server.ServerStatus) error {
+	table := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(table, "NAME\tSTATE\tPLAYERS\tVERSION\tUPTIME\tOVERRIDE")
+	for _, s := range servers {
+		version := s.Version
+		if s.DetectedVersion != "" {
+			version = s.DetectedVersion
+		}
+		uptime := "-"
+		if s.Status == "running" || s.Status == "starting" {
+			uptime = formatUptime(s.StartTime)
+		}
+		override := "-"
+		if s.Override != nil {
+			override = s.Override.State + " by " + s.Override.By
+		}
+		fmt.Fprintf(table, "%s\t%s\t%s\t%s\t%s\t%s\n", s.Name, s.Status, strconv.Itoa(s.PlayerCount), orDash(version), uptime, override)
+	}
+	return table.Flush()
+}
+
+// PrintServer writes the details of one server
+func PrintServer(w io.Writer, s *server.ServerStatus) error {
+	table := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(table, "Name:\t%s\n", s.Name)
+	fmt.Fprintf(table, "State:\t%s\n", s.Status)
+	fmt.Fprintf(table, "Port:\t%d\n", s.Port)
+	fmt.Fprintf(table, "Version:\t%s\n", orDash(s.Version))
+	if s.DetectedVersion != "" {
+		fmt.Fprintf(table, "Running version:\t%s\n", s.DetectedVersion)
+	}
+	if s.Status == "running" || s.Status == "starting" {
+		fmt.Fprintf(table, "Uptime:\t%s\n", formatUptime(s.StartTime))
+	}
+	if s.Override != nil {
+		fmt.Fprintf(table, "Override:\t%s by %s since %s\n", s.Override.State, s.Override.By, s.Override.Time.Local().Format(time.RFC822))
+	}
+	names := make([]string, 0, len(s.Players))
+	for _, player := range s.Players {
+		names = append(names, player.Name)
+	}
+	fmt.Fprintf(table, "Players:\t%d %s\n", s.PlayerCount, strings.Join(names, ", "))
+	return table.Flush()
+}
+
+// PrintOperation writes the outcome of a lifecycle operation
+func PrintOperation(w io.Writer, op *server.Operation) {
+	if op.Status == server.OperationFailed {
+		fmt.Fprintf(w, "%s of %s failed: %s\n", op.Action, op.Server, op.Error)
+		return
+	}
+	fmt.Fprintf(w, "%s of %s %s\n", op.Action, op.Server, op.Status)
+}
+
+// PrintBackup writes a created backup
+func PrintBackup(w io.Writer, info *backup.Info) {
+	fmt.Fprintf(w, "Backup %s of %s (%s, world %s)\n", info.ID, info.Server, info.Method, info.World)
+}
+
+// PrintReload writes what a reload applied and what needs a restart
+func PrintReload(w io.Writer, report *server.ReloadReport) {
+	if len(report.Applied) == 0 && len(report.RestartRequired) == 0 {
+		fmt.Fprintln(w, "Configuration unchanged")
+		return
+	}
+	if len(report.Applied) > 0 {
+		fmt.Fprintf(w, "Applied: %s\n", strings.Join(report.Applied, ", "))
+	}
+	if len(report.RestartRequired) > 0 {
+		fmt.Fprintf(w, "Restart required: %s\n", strings.Join(report.RestartRequired, ", "))
+	}
+}
+
+// formatUptime rounds the time since start to minutes
+func formatUptime(start time.Time) string {
+	uptime := time.Since(start).Round(time.Minute)
+	if uptime < time.Minute {
+		return "<1m"
+	}
+	return strings.TrimSuffix(uptime.String(), "0s")
+}
+
+func shortCommit(sha string) string {
+	if len(sha) > 8 {
+		return sha[:8]
+	}
+	return sha
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
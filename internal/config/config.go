@@ -5,34 +5,127 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
-
-	"gopkg.in/yaml.v3"
 )
 
 type Config struct {
-	GitHub GitHubConfig `yaml:"github"`
-	HTTP   HTTPConfig   `yaml:"http"`
-	Server ServerConfig `yaml:"server"`
+	Environment string       `yaml:"environment"` // Selects overlays/<environment>.yaml
+	GitHub      GitHubConfig `yaml:"github"`
+	HTTP        HTTPConfig   `yaml:"http"`
+	Server      ServerConfig `yaml:"server"`
+	Log         LogConfig    `yaml:"log"`
 }
 
 type GitHubConfig struct {
-	RepoOwner    string `yaml:"repo_owner"`
-	RepoName     string `yaml:"repo_name"`
-	Branch       string `yaml:"branch"`
-	ConfigPath   string `yaml:"config_path"`
-	PollInterval int    `yaml:"poll_interval"`
+	RepoOwner    string   `yaml:"repo_owner"`
+	RepoName     string   `yaml:"repo_name"`
+	Branch       string   `yaml:"branch"`
+	ConfigPath   string   `yaml:"config_path"`
+	PollInterval int      `yaml:"poll_interval"`
+	OverlayDir   string   `yaml:"overlay_dir"` // Relative to the directory of config_path
+	AssetsPath   string   `yaml:"assets_path"` // Directory holding a <server name>/ asset folder per server
+	Token        string   `yaml:"token"`
+	AllowedEnv   []string `yaml:"allowed_env"`   // Variables servers.yaml may reference
+	AllowedFiles []string `yaml:"allowed_files"` // Files servers.yaml may reference
 }
 
 type HTTPConfig struct {
-	Port int `yaml:"port"`
+	Port           int           `yaml:"port"`
+	Tokens         []TokenConfig `yaml:"tokens"`
+	TokensFile     string        `yaml:"tokens_file"`     // YAML file with a tokens list, read in addition to tokens
+	AllowAnonymous string        `yaml:"allow_anonymous"` // Role of requests without a token; empty to refuse them
+	TLS            TLSConfig     `yaml:"tls"`
+	Socket         SocketConfig  `yaml:"socket"`
+}
+
+// SocketConfig serves the API on a local Unix socket. Whoever the socket's
+// permissions let connect is an admin; no token is needed.
+type SocketConfig struct {
+	Path       string `yaml:"path"`        // Such as /run/msm/admin.sock; empty for no socket
+	Mode       string `yaml:"mode"`        // Octal permissions of the socket
+	Group      string `yaml:"group"`       // Group owning the socket, so its members can connect
+	DisableTCP bool   `yaml:"disable_tcp"` // Serve the API on the socket only
+}
+
+// TLSConfig serves the API over HTTPS, either with a certificate from cert_file
+// and key_file or with a self-signed one generated on first start
+type TLSConfig struct {
+	CertFile   string   `yaml:"cert_file"` // Re-read when the file changes, so rotated certificates need no restart
+	KeyFile    string   `yaml:"key_file"`
+	SelfSigned bool     `yaml:"self_signed"` // Generate a certificate under <base_dir>/tls when cert_file is not set
+	Hosts      []string `yaml:"hosts"`       // Extra names and addresses for the self-signed certificate
+	ClientCA   string   `yaml:"client_ca"`   // Require client certificates signed by this CA
+	ClientRole string   `yaml:"client_role"` // Role of a client certificate presented without a token
+}
+
+// Enabled reports whether the API is served over HTTPS
+func (t TLSConfig) Enabled() bool {
+	return t.CertFile != "" || t.SelfSigned
+}
+
+// TokenConfig grants a role to whoever presents the token hashing to Hash
+type TokenConfig struct {
+	Name string `yaml:"name"` // Shown in logs as the caller
+	Role string `yaml:"role"` // viewer, operator or admin
+	Hash string `yaml:"hash"` // "sha256:" followed by the hex SHA-256 of the token
 }
 
 type ServerConfig struct {
-	BaseDir      string `yaml:"base_dir"`
-	MaxInstances int    `yaml:"max_instances"`
-	BedrockPath  string `yaml:"bedrock_path"`
-	MemoryLimit  string `yaml:"memory_limit"`
-	FirstRun     bool   `yaml:"first_run"`
+	BaseDir         string          `yaml:"base_dir"`
+	MaxInstances    int             `yaml:"max_instances"`
+	BedrockPath     string          `yaml:"bedrock_path"`   // Used by servers without an installed version
+	VersionsDir     string          `yaml:"versions_dir"`   // Holds <version>/bedrock_server
+	BedrockMirror   string          `yaml:"bedrock_mirror"` // Base URL serving bedrock-server-<version>.zip
+	ReadyTimeout    int             `yaml:"ready_timeout"`  // Seconds a server has to start on a new version
+	AssetsDir       string          `yaml:"assets_dir"`     // Local copy of the repository's asset folders
+	MemoryLimit     string          `yaml:"memory_limit"`
+	CPULimit        string          `yaml:"cpu_limit"`     // Cores per server, e.g. "2" or "0.5"
+	PidsLimit       int             `yaml:"pids_limit"`    // Processes and threads per server
+	CgroupParent    string          `yaml:"cgroup_parent"` // Delegated cgroup v2 group, defaults to the manager's own
+	BackupDir       string          `yaml:"backup_dir"`
+	BackupRetention RetentionConfig `yaml:"backup_retention"` // Default for every server
+	BackupRemote    RemoteConfig    `yaml:"backup_remote"`
+	Layers          LayersConfig    `yaml:"layers"`
+	FirstRun        bool            `yaml:"first_run"`
+}
+
+// LayersConfig controls how versions/bedrock-server.zip is split into layers and
+// where missing layers are fetched from when the archive itself is absent
+type LayersConfig struct {
+	ChunkSizeMB int    `yaml:"chunk_size_mb"`
+	Mirror      string `yaml:"mirror"`    // Base URL serving the manifest and layers
+	RepoPath    string `yaml:"repo_path"` // Directory in the configuration repository holding them
+}
+
+// RemoteConfig points at an S3-compatible bucket that backups are replicated to
+type RemoteConfig struct {
+	Endpoint   string `yaml:"endpoint"` // host[:port]; empty disables replication
+	Bucket     string `yaml:"bucket"`
+	Prefix     string `yaml:"prefix"` // Key prefix inside the bucket
+	Region     string `yaml:"region"`
+	AccessKey  string `yaml:"access_key"`
+	SecretKey  string `yaml:"secret_key"`
+	UseSSL     bool   `yaml:"use_ssl"`
+	PathStyle  bool   `yaml:"path_style"`   // Needed by MinIO and most self-hosted stores
+	PartSizeMB int    `yaml:"part_size_mb"` // Multipart upload part size
+}
+
+// RetentionConfig decides which backups are kept. A backup survives when any rule
+// selects it; a config with every rule at zero keeps all backups.
+type RetentionConfig struct {
+	KeepLast   int `yaml:"keep_last"`   // Most recent backups
+	KeepHourly int `yaml:"keep_hourly"` // Newest backup of each of the last N hours with backups
+	KeepDaily  int `yaml:"keep_daily"`  // Newest backup of each of the last N days with backups
+	KeepWeekly int `yaml:"keep_weekly"` // Newest backup of each of the last N ISO weeks with backups
+}
+
+// IsZero reports whether no rule is set
+func (r RetentionConfig) IsZero() bool {
+	return r == RetentionConfig{}
+}
+
+type LogConfig struct {
+	Level  string `yaml:"level"`  // panic, fatal, error, warn, info, debug or trace
+	Format string `yaml:"format"` // text or json
 }
 
 type MinecraftServerConfig struct {
@@ -60,18 +153,48 @@ type MinecraftServerConfig struct {
 	MaxThreads                   int               `yaml:"max_threads"`
 	PlayerIdleTimeout            int               `yaml:"player_idle_timeout"`
 	MaxWorldSize                 int               `yaml:"max_world_size"`
+	BehaviorPacks                []string          `yaml:"behavior_packs"` // Pack directories or .mcpack files under server.assets_dir
+	ResourcePacks                []string          `yaml:"resource_packs"`
+	TexturepackRequired          bool              `yaml:"texturepack_required"`
+	MemoryLimit                  string            `yaml:"memory_limit"` // Overrides server.memory_limit
+	CPULimit                     string            `yaml:"cpu_limit"`    // Overrides server.cpu_limit
+	PidsLimit                    int               `yaml:"pids_limit"`   // Overrides server.pids_limit
+	Schedule                     ScheduleConfig    `yaml:"schedule"`
+	BackupRetention              RetentionConfig   `yaml:"backup_retention"` // Replaces server.backup_retention when set
+}
+
+// ScheduleConfig holds timed actions for a server. Cron expressions use the
+// standard five fields and are evaluated in Timezone.
+type ScheduleConfig struct {
+	Timezone  string             `yaml:"timezone"` // IANA name such as "Europe/London", defaults to local time
+	Restarts  []string           `yaml:"restarts"` // Cron expressions
+	Commands  []ScheduledCommand `yaml:"commands"`
+	OpenHours []OpenHoursWindow  `yaml:"open_hours"` // When set, the server only runs inside these windows
+}
+
+type ScheduledCommand struct {
+	Cron    string `yaml:"cron"`
+	Command string `yaml:"command"` // Console command, e.g. "say Server restarts in 5 minutes"
+}
+
+type OpenHoursWindow struct {
+	Days []string `yaml:"days"` // mon, tue, wed, thu, fri, sat, sun; empty means every day
+	From string   `yaml:"from"` // HH:MM
+	To   string   `yaml:"to"`   // HH:MM, a time before From ends the window the next day
 }
 
 type RepoConfig struct {
-	Servers []MinecraftServerConfig `yaml:"servers"`
+	Servers         []MinecraftServerConfig `yaml:"servers"`
+	BedrockVersions map[string]string       `yaml:"bedrock_versions"` // Version to pinned SHA-256 of its zip
 }
 
+// BranchFile names the file in the working directory whose contents select the
+// branch. Deprecated: overlays replace a branch per environment.
+const BranchFile = "branch"
+
 // readBranchFile reads the branch from the branch file in the root directory
 func readBranchFile() (string, error) {
-	// Look for branch file in current directory
-	branchFile := "branch"
-
-	data, err := os.ReadFile(branchFile)
+	data, err := os.ReadFile(BranchFile)
 	if err != nil {
 		// If branch file doesn't exist, return empty string (will use default)
 		if os.IsNotExist(err) {
@@ -85,8 +208,19 @@ func readBranchFile() (string, error) {
 	return branch, nil
 }
 
+// Load reads config.yaml without command line overrides
 func Load() (*Config, error) {
+	return LoadWithOverrides(nil)
+}
+
+// LoadWithOverrides builds the configuration from, in increasing order of precedence:
+// built-in defaults, config.yaml, the branch file, MSM_* environment variables and
+// command line flags.
+func LoadWithOverrides(overrides *Overrides) (*Config, error) {
 	configPath := os.Getenv("CONFIG_PATH")
+	if overrides != nil && overrides.ConfigFile != "" {
+		configPath = overrides.ConfigFile
+	}
 	if configPath == "" {
 		configPath = "config.yaml"
 	}
@@ -96,8 +230,9 @@ func Load() (*Config, error) {
 		return nil, fmt.Errorf("failed to read config file: %w", err)
 	}
 
+	// Expand ${VAR} and ${file:/path} references
 	var config Config
-	if err := yaml.Unmarshal(data, &config); err != nil {
+	if err := newLocalInterpolator().decode(data, &config); err != nil {
 		return nil, fmt.Errorf("failed to parse config file: %w", err)
 	}
 
@@ -107,22 +242,44 @@ func Load() (*Config, error) {
 		return nil, fmt.Errorf("failed to read branch file: %w", err)
 	}
 
-	// Set defaults
 	if branchFromFile != "" {
 		config.GitHub.Branch = branchFromFile
-	} else if config.GitHub.Branch == "" {
+	}
+
+	// Environment variables and flags override everything read from disk
+	if err := applyEnv(&config); err != nil {
+		return nil, err
+	}
+	if err := overrides.apply(&config); err != nil {
+		return nil, err
+	}
+
+	// Set defaults
+	if config.GitHub.Branch == "" {
 		config.GitHub.Branch = "main"
 	}
 
 	if config.GitHub.ConfigPath == "" {
 		config.GitHub.ConfigPath = "servers.yaml"
 	}
+	if config.GitHub.OverlayDir == "" {
+		config.GitHub.OverlayDir = "overlays"
+	}
+	if config.GitHub.AssetsPath == "" {
+		config.GitHub.AssetsPath = "servers"
+	}
 	if config.GitHub.PollInterval == 0 {
 		config.GitHub.PollInterval = 60 // 60 seconds
 	}
 	if config.HTTP.Port == 0 {
 		config.HTTP.Port = 8080
 	}
+	if config.HTTP.TLS.ClientRole == "" {
+		config.HTTP.TLS.ClientRole = "viewer"
+	}
+	if config.HTTP.Socket.Mode == "" {
+		config.HTTP.Socket.Mode = "0660"
+	}
 	if config.Server.BaseDir == "" {
 		config.Server.BaseDir = "./servers"
 	}
@@ -132,9 +289,33 @@ func Load() (*Config, error) {
 	if config.Server.BedrockPath == "" {
 		config.Server.BedrockPath = "./bedrock_server"
 	}
+	if config.Server.VersionsDir == "" {
+		config.Server.VersionsDir = "./versions"
+	}
+	if config.Server.ReadyTimeout == 0 {
+		config.Server.ReadyTimeout = 300
+	}
+	if config.Server.AssetsDir == "" {
+		config.Server.AssetsDir = "./assets"
+	}
+	if config.Server.BackupDir == "" {
+		config.Server.BackupDir = "./backups"
+	}
+	if config.Server.BackupRemote.PartSizeMB == 0 {
+		config.Server.BackupRemote.PartSizeMB = 16
+	}
+	if config.Server.Layers.ChunkSizeMB == 0 {
+		config.Server.Layers.ChunkSizeMB = 16
+	}
 	if config.Server.MemoryLimit == "" {
 		config.Server.MemoryLimit = "1G"
 	}
+	if config.Log.Level == "" {
+		config.Log.Level = "info"
+	}
+	if config.Log.Format == "" {
+		config.Log.Format = "text"
+	}
 
 	return &config, nil
 }
@@ -143,6 +324,16 @@ func (c *Config) GetServerDir(serverName string) string {
 	return filepath.Join(c.Server.BaseDir, serverName)
 }
 
+// GetWorldsDir returns the directory holding a server's worlds
+func (c *Config) GetWorldsDir(serverName string) string {
+	return filepath.Join(c.GetServerDir(serverName), "worlds")
+}
+
+// GetVersionDir returns the directory of an installed Bedrock version
+func (c *Config) GetVersionDir(version string) string {
+	return filepath.Join(c.Server.VersionsDir, version)
+}
+
 func (c *Config) GetServerPropertiesPath(serverName string) string {
 	return filepath.Join(c.GetServerDir(serverName), "server.properties")
 }
@@ -154,3 +345,9 @@ func (c *Config) GetPermissionsPath(serverName string) string {
 func (c *Config) GetWhitelistPath(serverName string) string {
 	return filepath.Join(c.GetServerDir(serverName), "whitelist.json")
 }
+
+// GetAssetsDir returns the local copy of a server's asset folder from the
+// configuration repository. The assets directory mirrors the repository's layout.
+func (c *Config) GetAssetsDir(serverName string) string {
+	return filepath.Join(c.Server.AssetsDir, filepath.FromSlash(c.GitHub.AssetsPath), serverName)
+}
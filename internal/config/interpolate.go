@@ -0,0 +1,203 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// interpolator expands ${VAR} and ${file:/path} references in configuration values.
+// Local configuration may reference anything; remote configuration is restricted to
+// the variables and files allowlisted in config.yaml.
+type interpolator struct {
+	restricted   bool
+	allowedEnv   map[string]bool
+	allowedFiles map[string]bool
+}
+
+func newLocalInterpolator() *interpolator {
+	return &interpolator{}
+}
+
+func newRemoteInterpolator(allowedEnv, allowedFiles []string) *interpolator {
+	i := &interpolator{
+		restricted:   true,
+		allowedEnv:   make(map[string]bool),
+		allowedFiles: make(map[string]bool),
+	}
+	for _, name := range allowedEnv {
+		i.allowedEnv[name] = true
+	}
+	for _, path := range allowedFiles {
+		i.allowedFiles[filepath.Clean(path)] = true
+	}
+	return i
+}
+
+// expand replaces every reference in s. "$${" produces a literal "${".
+func (i *interpolator) expand(s string) (string, error) {
+	if !strings.Contains(s, "${") {
+		return s, nil
+	}
+
+	var out strings.Builder
+	for {
+		start := strings.Index(s, "${")
+		if start < 0 {
+			out.WriteString(s)
+			return out.String(), nil
+		}
+
+		// Escaped reference
+		if start > 0 && s[start-1] == '$' {
+			out.WriteString(s[:start-1])
+			out.WriteString("${")
+			s = s[start+2:]
+			continue
+		}
+
+		end := strings.Index(s[start:], "}")
+		if end < 0 {
+			return "", fmt.Errorf("unterminated reference in %q", s)
+		}
+		end += start
+
+		value, err := i.resolve(s[start+2 : end])
+		if err != nil {
+			return "", err
+		}
+
+		out.WriteString(s[:start])
+		out.WriteString(value)
+		s = s[end+1:]
+	}
+}
+
+func (i *interpolator) resolve(ref string) (string, error) {
+	if path, ok := strings.CutPrefix(ref, "file:"); ok {
+		return i.readFile(path)
+	}
+
+	if !validVariableName(ref) {
+		return "", fmt.Errorf("invalid variable name ${%s}", ref)
+	}
+	if i.restricted && !i.allowedEnv[ref] {
+		return "", fmt.Errorf("variable ${%s} is not in github.allowed_env", ref)
+	}
+
+	value, ok := os.LookupEnv(ref)
+	if !ok {
+		return "", fmt.Errorf("environment variable %s is not set", ref)
+	}
+	return value, nil
+}
+
+func (i *interpolator) readFile(path string) (string, error) {
+	if path == "" {
+		return "", fmt.Errorf("empty path in ${file:} reference")
+	}
+	if i.restricted && !i.allowedFiles[filepath.Clean(path)] {
+		return "", fmt.Errorf("file %s is not in github.allowed_files", path)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read secret file: %w", err)
+	}
+
+	// Secret files usually end with a newline that is not part of the value
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
+
+// expandNode interpolates every scalar value in a YAML document. Working on the
+// parsed tree rather than the raw text means a secret can never inject YAML structure.
+func (i *interpolator) expandNode(node *yaml.Node) error {
+	switch node.Kind {
+	case yaml.ScalarNode:
+		value, err := i.expand(node.Value)
+		if err != nil {
+			return fmt.Errorf("line %d: %w", node.Line, err)
+		}
+		node.Value = value
+	case yaml.MappingNode:
+		// Only interpolate values, never keys
+		for j := 1; j < len(node.Content); j += 2 {
+			if err := i.expandNode(node.Content[j]); err != nil {
+				return err
+			}
+		}
+	case yaml.DocumentNode, yaml.SequenceNode:
+		for _, child := range node.Content {
+			if err := i.expandNode(child); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// decode parses YAML, interpolates it and decodes the result into out
+func (i *interpolator) decode(data []byte, out interface{}) error {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return err
+	}
+	return i.decodeNode(&doc, out)
+}
+
+func (i *interpolator) decodeNode(doc *yaml.Node, out interface{}) error {
+	// Empty document
+	if doc.Kind == 0 {
+		return nil
+	}
+
+	if err := i.expandNode(doc); err != nil {
+		return err
+	}
+
+	return doc.Decode(out)
+}
+
+// ParseRepoConfig parses a remote servers.yaml and, when given, patches it with an
+// environment overlay. References are expanded after the overlay is applied and are
+// limited to the given environment variables and files.
+func ParseRepoConfig(data, overlay []byte, allowedEnv, allowedFiles []string) (*RepoConfig, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+
+	if overlay != nil {
+		var overlayDoc yaml.Node
+		if err := yaml.Unmarshal(overlay, &overlayDoc); err != nil {
+			return nil, fmt.Errorf("failed to parse overlay: %w", err)
+		}
+		if err := applyOverlay(&doc, &overlayDoc); err != nil {
+			return nil, err
+		}
+	}
+
+	var repoConfig RepoConfig
+	if err := newRemoteInterpolator(allowedEnv, allowedFiles).decodeNode(&doc, &repoConfig); err != nil {
+		return nil, err
+	}
+	return &repoConfig, nil
+}
+
+func validVariableName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for j, c := range name {
+		switch {
+		case c == '_', c >= 'A' && c <= 'Z', c >= 'a' && c <= 'z':
+		case c >= '0' && c <= '9' && j > 0:
+		default:
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,93 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestInterpolateLocalConfig(t *testing.T) {
+	dir := t.TempDir()
+	tokenFile := filepath.Join(dir, "token")
+	if err := os.WriteFile(tokenFile, []byte("s3cret\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("MSM_TEST_OWNER", "golangdaddy")
+
+	data := []byte(`
+github:
+  repo_owner: "${MSM_TEST_OWNER}"
+  repo_name: "literal-$${NOT_A_VAR}"
+  token: "${file:` + tokenFile + `}"
+`)
+
+	var cfg Config
+	if err := newLocalInterpolator().decode(data, &cfg); err != nil {
+		t.Fatalf("decode failed: %v", err)
+	}
+
+	if cfg.GitHub.RepoOwner != "golangdaddy" {
+		t.Errorf("repo_owner = %q, want golangdaddy", cfg.GitHub.RepoOwner)
+	}
+	if cfg.GitHub.RepoName != "literal-${NOT_A_VAR}" {
+		t.Errorf("repo_name = %q, want escaped reference", cfg.GitHub.RepoName)
+	}
+	if cfg.GitHub.Token != "s3cret" {
+		t.Errorf("token = %q, want s3cret", cfg.GitHub.Token)
+	}
+}
+
+func TestInterpolateUnsetVariable(t *testing.T) {
+	os.Unsetenv("MSM_TEST_MISSING")
+
+	var cfg Config
+	err := newLocalInterpolator().decode([]byte(`github: {token: "${MSM_TEST_MISSING}"}`), &cfg)
+	if err == nil {
+		t.Error("expected an error for an unset variable")
+	}
+}
+
+func TestRemoteConfigAllowlist(t *testing.T) {
+	t.Setenv("SURVIVAL_SEED", "42")
+	t.Setenv("GITHUB_TOKEN", "leaked")
+
+	allowed := []byte(`
+servers:
+  - name: survival
+    level_seed: "${SURVIVAL_SEED}"
+`)
+	repoConfig, err := ParseRepoConfig(allowed, nil, []string{"SURVIVAL_SEED"}, nil)
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	if repoConfig.Servers[0].LevelSeed != "42" {
+		t.Errorf("level_seed = %q, want 42", repoConfig.Servers[0].LevelSeed)
+	}
+
+	// Variables that are not allowlisted must not be readable from remote config
+	denied := []byte(`
+servers:
+  - name: survival
+    motd: "${GITHUB_TOKEN}"
+`)
+	if _, err := ParseRepoConfig(denied, nil, []string{"SURVIVAL_SEED"}, nil); err == nil {
+		t.Error("expected an error for a variable outside the allowlist")
+	}
+
+	// Files are denied unless explicitly allowlisted
+	if _, err := ParseRepoConfig([]byte(`servers: [{name: x, motd: "${file:/etc/hostname}"}]`), nil, nil, nil); err == nil {
+		t.Error("expected an error for a file outside the allowlist")
+	}
+}
+
+func TestInterpolateDoesNotInjectStructure(t *testing.T) {
+	t.Setenv("MSM_TEST_MOTD", "hello\nops: [attacker]")
+
+	repoConfig, err := ParseRepoConfig([]byte(`servers: [{name: x, motd: "${MSM_TEST_MOTD}"}]`), nil, []string{"MSM_TEST_MOTD"}, nil)
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	if len(repoConfig.Servers[0].Ops) != 0 {
+		t.Errorf("interpolated value changed document structure: ops = %v", repoConfig.Servers[0].Ops)
+	}
+}
@@ -0,0 +1,134 @@
+package config
+
+import (
+	"fmt"
+
+	"gopkg.in/yaml.v3"
+)
+
+// applyOverlay patches base with an environment overlay such as overlays/staging.yaml.
+//
+// Mappings are merged key by key and a null value removes the key. Lists whose
+// entries carry a "name" (like servers) are merged entry by entry: a matching
+// name patches the entry, an unknown name appends it and "remove: true" drops it.
+// Any other value, including plain lists such as whitelist, is replaced.
+func applyOverlay(base, overlay *yaml.Node) error {
+	base, overlay = documentRoot(base), documentRoot(overlay)
+	if overlay == nil || base == nil {
+		return nil
+	}
+	if base.Kind != yaml.MappingNode || overlay.Kind != yaml.MappingNode {
+		return fmt.Errorf("overlay and base configuration must both be mappings")
+	}
+
+	mergeMapping(base, overlay)
+	return nil
+}
+
+func documentRoot(node *yaml.Node) *yaml.Node {
+	if node == nil || node.Kind == 0 {
+		return nil
+	}
+	if node.Kind == yaml.DocumentNode {
+		if len(node.Content) == 0 {
+			return nil
+		}
+		return node.Content[0]
+	}
+	return node
+}
+
+func mergeMapping(base, overlay *yaml.Node) {
+	for i := 0; i+1 < len(overlay.Content); i += 2 {
+		key, value := overlay.Content[i], overlay.Content[i+1]
+		index := mappingIndex(base, key.Value)
+
+		switch {
+		case isNull(value):
+			if index >= 0 {
+				base.Content = append(base.Content[:index], base.Content[index+2:]...)
+			}
+		case index < 0:
+			base.Content = append(base.Content, key, value)
+		default:
+			base.Content[index+1] = mergeValue(base.Content[index+1], value)
+		}
+	}
+}
+
+func mergeValue(base, overlay *yaml.Node) *yaml.Node {
+	switch {
+	case base.Kind == yaml.MappingNode && overlay.Kind == yaml.MappingNode:
+		mergeMapping(base, overlay)
+		return base
+	case base.Kind == yaml.SequenceNode && overlay.Kind == yaml.SequenceNode && namedSequence(base) && namedSequence(overlay):
+		mergeNamedSequence(base, overlay)
+		return base
+	default:
+		return overlay
+	}
+}
+
+func mergeNamedSequence(base, overlay *yaml.Node) {
+	for _, entry := range overlay.Content {
+		name := scalarValue(entry, "name")
+
+		index := -1
+		for i, existing := range base.Content {
+			if scalarValue(existing, "name") == name {
+				index = i
+				break
+			}
+		}
+
+		if scalarValue(entry, "remove") == "true" {
+			if index >= 0 {
+				base.Content = append(base.Content[:index], base.Content[index+1:]...)
+			}
+			continue
+		}
+
+		if index < 0 {
+			base.Content = append(base.Content, entry)
+		} else {
+			mergeMapping(base.Content[index], entry)
+		}
+	}
+}
+
+// namedSequence reports whether every entry is a mapping with a name. An empty
+// list is not, so an overlay can clear a list by replacing it with [].
+func namedSequence(node *yaml.Node) bool {
+	if len(node.Content) == 0 {
+		return false
+	}
+	for _, entry := range node.Content {
+		if entry.Kind != yaml.MappingNode || scalarValue(entry, "name") == "" {
+			return false
+		}
+	}
+	return true
+}
+
+func mappingIndex(node *yaml.Node, key string) int {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return i
+		}
+	}
+	return -1
+}
+
+func scalarValue(node *yaml.Node, key string) string {
+	if node.Kind != yaml.MappingNode {
+		return ""
+	}
+	if index := mappingIndex(node, key); index >= 0 && node.Content[index+1].Kind == yaml.ScalarNode {
+		return node.Content[index+1].Value
+	}
+	return ""
+}
+
+func isNull(node *yaml.Node) bool {
+	return node.Kind == yaml.ScalarNode && node.Tag == "!!null"
+}
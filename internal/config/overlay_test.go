@@ -0,0 +1,91 @@
+package config
+
+import (
+	"testing"
+)
+
+func TestOverlayPatchesServersByName(t *testing.T) {
+	base := []byte(`
+servers:
+  - name: survival
+    port: 19132
+    motd: "Welcome"
+    whitelist: [alice, bob]
+    properties:
+      allow-cheats: "false"
+      keep-inventory: "true"
+  - name: creative
+    port: 19133
+  - name: minigames
+    port: 19134
+`)
+	overlay := []byte(`
+servers:
+  - name: survival
+    motd: "STAGING"
+    whitelist: [tester]
+    properties:
+      allow-cheats: "true"
+      keep-inventory: ~
+  - name: minigames
+    remove: true
+  - name: sandbox
+    port: 19135
+`)
+
+	repoConfig, err := ParseRepoConfig(base, overlay, nil, nil)
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+
+	names := make([]string, 0, len(repoConfig.Servers))
+	for _, s := range repoConfig.Servers {
+		names = append(names, s.Name)
+	}
+	if len(names) != 3 || names[0] != "survival" || names[1] != "creative" || names[2] != "sandbox" {
+		t.Fatalf("servers = %v, want [survival creative sandbox]", names)
+	}
+
+	survival := repoConfig.Servers[0]
+	if survival.Port != 19132 {
+		t.Errorf("port = %d, untouched fields should be kept", survival.Port)
+	}
+	if survival.Motd != "STAGING" {
+		t.Errorf("motd = %q, want overlay value", survival.Motd)
+	}
+	if len(survival.Whitelist) != 1 || survival.Whitelist[0] != "tester" {
+		t.Errorf("whitelist = %v, plain lists should be replaced", survival.Whitelist)
+	}
+	if survival.Properties["allow-cheats"] != "true" {
+		t.Errorf("allow-cheats = %q, want overlay value", survival.Properties["allow-cheats"])
+	}
+	if _, ok := survival.Properties["keep-inventory"]; ok {
+		t.Error("keep-inventory should be removed by a null overlay value")
+	}
+}
+
+func TestOverlayEmptyListClearsServers(t *testing.T) {
+	base := []byte("servers:\n  - name: survival\n    port: 19132\n")
+	repoConfig, err := ParseRepoConfig(base, []byte("servers: []\n"), nil, nil)
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	if len(repoConfig.Servers) != 0 {
+		t.Errorf("servers = %v, an empty overlay list should replace the base list", repoConfig.Servers)
+	}
+}
+
+func TestOverlayReferencesAreExpandedAfterMerge(t *testing.T) {
+	t.Setenv("STAGING_SEED", "7")
+
+	base := []byte(`servers: [{name: survival, level_seed: "1"}]`)
+	overlay := []byte(`servers: [{name: survival, level_seed: "${STAGING_SEED}"}]`)
+
+	repoConfig, err := ParseRepoConfig(base, overlay, []string{"STAGING_SEED"}, nil)
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	if repoConfig.Servers[0].LevelSeed != "7" {
+		t.Errorf("level_seed = %q, want 7", repoConfig.Servers[0].LevelSeed)
+	}
+}
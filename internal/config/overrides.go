@@ -0,0 +1,180 @@
+package config
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// envPrefix is prepended to every environment override, e.g. MSM_GITHUB_BRANCH
+const envPrefix = "MSM_"
+
+// Overrides holds values supplied on the command line. They take precedence over
+// the environment, the branch file and config.yaml.
+type Overrides struct {
+	ConfigFile string            // Path to config.yaml, overrides CONFIG_PATH
+	Values     map[string]string // Keyed by field name, e.g. "github.branch"
+}
+
+// field is a single overridable configuration value
+type field struct {
+	key   string // e.g. "http.port"
+	env   string // e.g. "MSM_HTTP_PORT"
+	value reflect.Value
+}
+
+// fields walks the configuration struct and returns every scalar or string list
+// value, named after its yaml tags. New fields get an override automatically.
+func fields(cfg *Config) []field {
+	var result []field
+	collectFields(reflect.ValueOf(cfg).Elem(), "", &result)
+	return result
+}
+
+func collectFields(v reflect.Value, prefix string, result *[]field) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		tag := strings.Split(t.Field(i).Tag.Get("yaml"), ",")[0]
+		if tag == "" || tag == "-" {
+			continue
+		}
+
+		key := tag
+		if prefix != "" {
+			key = prefix + "." + tag
+		}
+
+		fv := v.Field(i)
+		switch fv.Kind() {
+		case reflect.Struct:
+			collectFields(fv, key, result)
+		case reflect.String, reflect.Int, reflect.Bool:
+			*result = append(*result, fieldFor(key, fv))
+		case reflect.Slice:
+			if fv.Type().Elem().Kind() == reflect.String {
+				*result = append(*result, fieldFor(key, fv))
+			}
+		}
+	}
+}
+
+func fieldFor(key string, v reflect.Value) field {
+	env := envPrefix + strings.ToUpper(strings.ReplaceAll(key, ".", "_"))
+	return field{key: key, env: env, value: v}
+}
+
+func (f field) set(raw string) error {
+	switch f.value.Kind() {
+	case reflect.String:
+		f.value.SetString(raw)
+	case reflect.Int:
+		n, err := strconv.Atoi(strings.TrimSpace(raw))
+		if err != nil {
+			return fmt.Errorf("%s: invalid integer %q", f.key, raw)
+		}
+		f.value.SetInt(int64(n))
+	case reflect.Bool:
+		b, err := strconv.ParseBool(strings.TrimSpace(raw))
+		if err != nil {
+			return fmt.Errorf("%s: invalid boolean %q", f.key, raw)
+		}
+		f.value.SetBool(b)
+	case reflect.Slice:
+		// Lists are comma separated
+		var items []string
+		for _, item := range strings.Split(raw, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		f.value.Set(reflect.ValueOf(items))
+	}
+	return nil
+}
+
+// RegisterFlags adds a "-config" flag and one flag per configuration field, e.g.
+// "-github.branch", to fs. The returned Overrides is filled in when fs is parsed.
+func RegisterFlags(fs *flag.FlagSet) *Overrides {
+	overrides := &Overrides{Values: make(map[string]string)}
+
+	fs.StringVar(&overrides.ConfigFile, "config", "", "Path to config.yaml (overrides CONFIG_PATH)")
+	for _, f := range fields(&Config{}) {
+		key := f.key
+		fs.Func(key, fmt.Sprintf("Override %s (env %s)", key, f.env), func(value string) error {
+			overrides.Values[key] = value
+			return nil
+		})
+	}
+
+	return overrides
+}
+
+// applyEnv applies MSM_* environment variables to cfg
+func applyEnv(cfg *Config) error {
+	for _, f := range fields(cfg) {
+		if value, ok := os.LookupEnv(f.env); ok {
+			if err := f.set(value); err != nil {
+				return fmt.Errorf("environment variable %s: %w", f.env, err)
+			}
+		}
+	}
+	return nil
+}
+
+// apply applies command line values to cfg
+func (o *Overrides) apply(cfg *Config) error {
+	if o == nil {
+		return nil
+	}
+
+	known := make(map[string]field)
+	for _, f := range fields(cfg) {
+		known[f.key] = f
+	}
+
+	for key, value := range o.Values {
+		f, ok := known[key]
+		if !ok {
+			return fmt.Errorf("unknown configuration field %s", key)
+		}
+		if err := f.set(value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Diff returns the names of fields whose values differ between two configurations
+func Diff(old, new *Config) []string {
+	newFields := make(map[string]field)
+	for _, f := range fields(new) {
+		newFields[f.key] = f
+	}
+
+	var changed []string
+	for _, f := range fields(old) {
+		if !reflect.DeepEqual(f.value.Interface(), newFields[f.key].value.Interface()) {
+			changed = append(changed, f.key)
+		}
+	}
+	return changed
+}
+
+// CopyFields copies the named fields from src to dst
+func CopyFields(dst, src *Config, keys []string) {
+	srcFields := make(map[string]field)
+	for _, f := range fields(src) {
+		srcFields[f.key] = f
+	}
+
+	for _, f := range fields(dst) {
+		for _, key := range keys {
+			if f.key == key {
+				f.value.Set(srcFields[key].value)
+			}
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package config
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestOverridePrecedence(t *testing.T) {
+	dir := t.TempDir()
+	configFile := filepath.Join(dir, "config.yaml")
+	data := []byte(`
+github:
+  branch: "from-file"
+  poll_interval: 120
+http:
+  port: 9000
+server:
+  max_instances: 3
+`)
+	if err := os.WriteFile(configFile, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("MSM_HTTP_PORT", "9100")
+	t.Setenv("MSM_GITHUB_BRANCH", "from-env")
+	t.Setenv("MSM_GITHUB_ALLOWED_ENV", "A, B")
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	overrides := RegisterFlags(fs)
+	if err := fs.Parse([]string{"-config", configFile, "-github.branch", "from-flag"}); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := LoadWithOverrides(overrides)
+	if err != nil {
+		t.Fatalf("load failed: %v", err)
+	}
+
+	if cfg.GitHub.Branch != "from-flag" {
+		t.Errorf("branch = %q, flags should win", cfg.GitHub.Branch)
+	}
+	if cfg.HTTP.Port != 9100 {
+		t.Errorf("port = %d, environment should beat config.yaml", cfg.HTTP.Port)
+	}
+	if cfg.GitHub.PollInterval != 120 {
+		t.Errorf("poll_interval = %d, want value from config.yaml", cfg.GitHub.PollInterval)
+	}
+	if len(cfg.GitHub.AllowedEnv) != 2 || cfg.GitHub.AllowedEnv[1] != "B" {
+		t.Errorf("allowed_env = %v, want [A B]", cfg.GitHub.AllowedEnv)
+	}
+	if cfg.Server.BaseDir != "./servers" {
+		t.Errorf("base_dir = %q, want default", cfg.Server.BaseDir)
+	}
+}
+
+func TestInvalidEnvironmentOverride(t *testing.T) {
+	dir := t.TempDir()
+	configFile := filepath.Join(dir, "config.yaml")
+	if err := os.WriteFile(configFile, []byte("http:\n  port: 8080\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("MSM_HTTP_PORT", "not-a-port")
+	if _, err := LoadWithOverrides(&Overrides{ConfigFile: configFile}); err == nil {
+		t.Error("expected an error for a non-numeric port")
+	}
+}
@@ -4,20 +4,25 @@ import (
 	"context"
 	"encoding/base64"
 	"fmt"
+	"io"
+	"path"
 	"time"
 
 	"minecraft-server-manager/internal/config"
 
 	"github.com/google/go-github/v57/github"
-	"gopkg.in/yaml.v3"
 )
 
 type Client struct {
-	client     *github.Client
-	repoOwner  string
-	repoName   string
-	branch     string
-	configPath string
+	client       *github.Client
+	repoOwner    string
+	repoName     string
+	branch       string
+	configPath   string
+	environment  string
+	overlayDir   string
+	allowedEnv   []string
+	allowedFiles []string
 }
 
 func NewClient(repoOwner, repoName string) *Client {
@@ -41,35 +46,101 @@ func (c *Client) SetConfigPath(configPath string) {
 	c.configPath = configPath
 }
 
+// SetEnvironment selects the overlay (<overlayDir>/<environment>.yaml next to the
+// config file) applied on top of the base configuration. An empty environment
+// disables overlays.
+func (c *Client) SetEnvironment(environment, overlayDir string) {
+	c.environment = environment
+	c.overlayDir = overlayDir
+}
+
+// OverlayPath returns the repository path of the active overlay, or "" if none
+func (c *Client) OverlayPath() string {
+	if c.environment == "" {
+		return ""
+	}
+	return path.Join(path.Dir(c.configPath), c.overlayDir, c.environment+".yaml")
+}
+
+// SetToken authenticates API requests, which raises the rate limit and allows private repositories
+func (c *Client) SetToken(token string) {
+	// Build a fresh client so a changed token replaces the previous one
+	client := github.NewClient(nil)
+	if token != "" {
+		client = client.WithAuthToken(token)
+	}
+	c.client = client
+}
+
+// SetAllowedReferences limits which ${VAR} and ${file:/path} references the remote config may use
+func (c *Client) SetAllowedReferences(env, files []string) {
+	c.allowedEnv = env
+	c.allowedFiles = files
+}
+
 func (c *Client) GetConfig() (*config.RepoConfig, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
-	// Get the file content from GitHub
-	fileContent, _, resp, err := c.client.Repositories.GetContents(ctx, c.repoOwner, c.repoName, c.configPath, &github.RepositoryContentGetOptions{
+	content, err := c.getFile(ctx, c.configPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get config file from GitHub: %w", err)
+	}
+
+	// Get the environment overlay, if one is selected
+	var overlay []byte
+	if overlayPath := c.OverlayPath(); overlayPath != "" {
+		overlay, err = c.getFile(ctx, overlayPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get overlay %s from GitHub: %w", overlayPath, err)
+		}
+	}
+
+	// Parse the YAML configuration, expanding allowlisted references
+	repoConfig, err := config.ParseRepoConfig(content, overlay, c.allowedEnv, c.allowedFiles)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse config YAML: %w", err)
+	}
+
+	return repoConfig, nil
+}
+
+// getFile returns the decoded contents of a file on the configured branch
+func (c *Client) getFile(ctx context.Context, filePath string) ([]byte, error) {
+	fileContent, _, resp, err := c.client.Repositories.GetContents(ctx, c.repoOwner, c.repoName, filePath, &github.RepositoryContentGetOptions{
 		Ref: c.branch,
 	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to get config file from GitHub: %w", err)
+		return nil, err
 	}
 
 	if resp.StatusCode != 200 {
 		return nil, fmt.Errorf("GitHub API returned status %d", resp.StatusCode)
 	}
 
+	if fileContent == nil {
+		return nil, fmt.Errorf("%s is a directory", filePath)
+	}
+
 	// Decode the content
 	content, err := base64.StdEncoding.DecodeString(*fileContent.Content)
 	if err != nil {
 		return nil, fmt.Errorf("failed to decode file content: %w", err)
 	}
 
-	// Parse the YAML configuration
-	var repoConfig config.RepoConfig
-	if err := yaml.Unmarshal(content, &repoConfig); err != nil {
-		return nil, fmt.Errorf("failed to parse config YAML: %w", err)
-	}
+	return content, nil
+}
 
-	return &repoConfig, nil
+// DownloadFile streams a file on the configured branch. Unlike the contents API it
+// works for files larger than 1 MB.
+func (c *Client) DownloadFile(ctx context.Context, filePath string) (io.ReadCloser, error) {
+	body, _, err := c.client.Repositories.DownloadContents(ctx, c.repoOwner, c.repoName, filePath, &github.RepositoryContentGetOptions{
+		Ref: c.branch,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to download %s from GitHub: %w", filePath, err)
+	}
+	return body, nil
 }
 
 func (c *Client) GetLastCommitSHA() (string, error) {
@@ -0,0 +1,190 @@
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// blobCache records the blob SHA of every file synced into a directory, so later
+// syncs only download what changed
+const blobCache = ".blobs.json"
+
+// SyncResult describes what a directory sync changed
+type SyncResult struct {
+	Downloaded []string // Files fetched because they were new or changed
+	Removed    []string // Files deleted because they left the repository
+	Unchanged  int
+}
+
+// Changed reports whether the sync touched any file
+func (r *SyncResult) Changed() bool {
+	return len(r.Downloaded) > 0 || len(r.Removed) > 0
+}
+
+// SyncDir mirrors the repository directory dir on the configured branch into
+// localDir. The branch's tree is listed in one request and only blobs whose SHA
+// differs from the last sync are downloaded. A directory missing from the
+// repository empties localDir of everything an earlier sync put there.
+func (c *Client) SyncDir(ctx context.Context, dir, localDir string) (*SyncResult, error) {
+	remote, err := c.listBlobs(ctx, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	cache := make(map[string]string)
+	if data, err := os.ReadFile(filepath.Join(localDir, blobCache)); err == nil {
+		if err := json.Unmarshal(data, &cache); err != nil {
+			// Treat a damaged cache as empty; everything is downloaded again
+			cache = make(map[string]string)
+		}
+	}
+
+	result := &SyncResult{Downloaded: []string{}, Removed: []string{}}
+	synced := make(map[string]string)
+	for _, rel := range sortedKeys(remote) {
+		blob := remote[rel]
+		target := filepath.Join(localDir, filepath.FromSlash(rel))
+		if cache[rel] == blob.sha {
+			if _, err := os.Stat(target); err == nil {
+				synced[rel] = blob.sha
+				result.Unchanged++
+				continue
+			}
+		}
+
+		data, _, err := c.client.Git.GetBlobRaw(ctx, c.repoOwner, c.repoName, blob.sha)
+		if err != nil {
+			// Keep what was synced so far so the next attempt resumes from there
+			saveBlobCache(localDir, cache, synced)
+			return nil, fmt.Errorf("failed to download %s from GitHub: %w", path.Join(dir, rel), err)
+		}
+		if err := writeFileAtomic(target, data, blob.mode); err != nil {
+			saveBlobCache(localDir, cache, synced)
+			return nil, fmt.Errorf("failed to write %s: %w", target, err)
+		}
+		synced[rel] = blob.sha
+		result.Downloaded = append(result.Downloaded, rel)
+	}
+
+	for _, rel := range sortedKeys(cache) {
+		if _, ok := remote[rel]; ok {
+			continue
+		}
+		target := filepath.Join(localDir, filepath.FromSlash(rel))
+		if err := os.Remove(target); err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("failed to remove %s: %w", target, err)
+		}
+		removeEmptyParents(localDir, filepath.Dir(target))
+		result.Removed = append(result.Removed, rel)
+	}
+
+	if len(synced) == 0 && len(cache) == 0 {
+		// Nothing to mirror and nothing mirrored before
+		return result, nil
+	}
+	if err := writeCache(localDir, synced); err != nil {
+		return nil, fmt.Errorf("failed to save blob cache: %w", err)
+	}
+	return result, nil
+}
+
+type blobEntry struct {
+	sha  string
+	mode os.FileMode
+}
+
+// listBlobs returns the files under dir on the configured branch by path
+// relative to dir
+func (c *Client) listBlobs(ctx context.Context, dir string) (map[string]blobEntry, error) {
+	tree, _, err := c.client.Git.GetTree(ctx, c.repoOwner, c.repoName, c.branch, true)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list repository tree: %w", err)
+	}
+	if tree.GetTruncated() {
+		return nil, fmt.Errorf("repository tree of %s is too large to list in one request", c.branch)
+	}
+
+	prefix := strings.Trim(dir, "/") + "/"
+	blobs := make(map[string]blobEntry)
+	for _, entry := range tree.Entries {
+		if entry.GetType() != "blob" || !strings.HasPrefix(entry.GetPath(), prefix) {
+			continue
+		}
+		rel := strings.TrimPrefix(entry.GetPath(), prefix)
+		if clean := path.Clean(rel); clean != rel || clean == ".." || strings.HasPrefix(clean, "../") || rel == blobCache {
+			continue
+		}
+
+		mode := os.FileMode(0644)
+		switch entry.GetMode() {
+		case "100755":
+			mode = 0755
+		case "120000":
+			// Links could point anywhere on the host, so they are not synced
+			continue
+		}
+		blobs[rel] = blobEntry{sha: entry.GetSHA(), mode: mode}
+	}
+	return blobs, nil
+}
+
+// saveBlobCache records a partial sync: files already synced get their new SHA
+// and files not reached yet keep their old one
+func saveBlobCache(localDir string, cache, synced map[string]string) {
+	merged := make(map[string]string, len(cache))
+	for rel, sha := range cache {
+		merged[rel] = sha
+	}
+	for rel, sha := range synced {
+		merged[rel] = sha
+	}
+	writeCache(localDir, merged)
+}
+
+func writeCache(localDir string, cache map[string]string) error {
+	data, err := json.MarshalIndent(cache, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(filepath.Join(localDir, blobCache), data, 0644)
+}
+
+func writeFileAtomic(target string, data []byte, mode os.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+	tmp := target + ".tmp"
+	if err := os.WriteFile(tmp, data, mode); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp, mode); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, target)
+}
+
+// removeEmptyParents removes dir and its parents up to root while they are empty
+func removeEmptyParents(root, dir string) {
+	for dir != root && strings.HasPrefix(dir, root) {
+		if err := os.Remove(dir); err != nil {
+			return
+		}
+		dir = filepath.Dir(dir)
+	}
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,89 @@
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fakeRepo serves the git trees and blobs API for a branch of path → content,
+// using the content itself as the blob SHA
+type fakeRepo struct {
+	files     map[string]string
+	downloads []string
+}
+
+func (f *fakeRepo) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case strings.HasPrefix(r.URL.Path, "/repos/owner/repo/git/trees/"):
+		entries := []map[string]string{{"path": "servers", "type": "tree", "mode": "040000", "sha": "t"}}
+		for path, content := range f.files {
+			entries = append(entries, map[string]string{"path": path, "type": "blob", "mode": "100644", "sha": content})
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"sha": "root", "tree": entries, "truncated": false})
+	case strings.HasPrefix(r.URL.Path, "/repos/owner/repo/git/blobs/"):
+		sha := strings.TrimPrefix(r.URL.Path, "/repos/owner/repo/git/blobs/")
+		f.downloads = append(f.downloads, sha)
+		w.Write([]byte(sha))
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func TestSyncDir(t *testing.T) {
+	repo := &fakeRepo{files: map[string]string{
+		"servers/castle/server.properties":       "v1",
+		"servers/castle/packs/parkour/manifest":  "m1",
+		"servers/castle/packs/parkour/script.js": "s1",
+		"servers/other/server.properties":        "o1",
+		"servers.yaml":                           "y1",
+	}}
+	api := httptest.NewServer(repo)
+	defer api.Close()
+
+	client := NewClient("owner", "repo")
+	client.client.BaseURL, _ = url.Parse(api.URL + "/")
+	local := filepath.Join(t.TempDir(), "castle")
+
+	result, err := client.SyncDir(context.Background(), "servers/castle", local)
+	if err != nil {
+		t.Fatalf("SyncDir failed: %v", err)
+	}
+	if len(result.Downloaded) != 3 || len(repo.downloads) != 3 {
+		t.Fatalf("Expected 3 downloads, got %v", result.Downloaded)
+	}
+	if data, _ := os.ReadFile(filepath.Join(local, "packs", "parkour", "script.js")); string(data) != "s1" {
+		t.Errorf("Expected the synced file, got %q", data)
+	}
+
+	// Only the changed blob is downloaded; the removed file and its directory go
+	repo.files["servers/castle/server.properties"] = "v2"
+	delete(repo.files, "servers/castle/packs/parkour/manifest")
+	delete(repo.files, "servers/castle/packs/parkour/script.js")
+	repo.downloads = nil
+
+	result, err = client.SyncDir(context.Background(), "servers/castle", local)
+	if err != nil {
+		t.Fatalf("SyncDir failed: %v", err)
+	}
+	if len(repo.downloads) != 1 || repo.downloads[0] != "v2" {
+		t.Errorf("Expected only the changed blob to be downloaded, got %v", repo.downloads)
+	}
+	if len(result.Removed) != 2 {
+		t.Errorf("Expected 2 removed files, got %v", result.Removed)
+	}
+	if _, err := os.Stat(filepath.Join(local, "packs")); !os.IsNotExist(err) {
+		t.Errorf("Expected the emptied directory to be removed, got %v", err)
+	}
+
+	repo.downloads = nil
+	if result, err = client.SyncDir(context.Background(), "servers/castle", local); err != nil || result.Changed() {
+		t.Errorf("Expected nothing to change, got %+v (%v)", result, err)
+	}
+}
@@ -0,0 +1,151 @@
+// Package installer downloads Bedrock dedicated server releases into the
+// versions store, verifying each against a pinned SHA-256.
+package installer
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"minecraft-server-manager/internal/archive"
+
+	"github.com/sirupsen/logrus"
+)
+
+// versionPattern matches Bedrock versions such as "1.20.50" or "1.20.51.01"
+var versionPattern = regexp.MustCompile(`^[0-9]+(\.[0-9]+)*$`)
+
+// ErrNoChecksum is returned when a version has no pinned SHA-256
+var ErrNoChecksum = errors.New("no pinned SHA-256")
+
+// ValidVersion reports whether a version is safe to use as a directory name
+func ValidVersion(version string) bool {
+	return versionPattern.MatchString(version)
+}
+
+// Installer installs releases as <versionsDir>/<version>/bedrock_server
+type Installer struct {
+	mirror      string
+	versionsDir string
+	client      *http.Client
+	logger      *logrus.Logger
+}
+
+// New returns an installer that downloads <mirror>/bedrock-server-<version>.zip,
+// the naming of the official download site
+func New(mirror, versionsDir string, logger *logrus.Logger) *Installer {
+	return &Installer{
+		mirror:      strings.TrimSuffix(mirror, "/"),
+		versionsDir: versionsDir,
+		client:      &http.Client{Timeout: 30 * time.Minute},
+		logger:      logger,
+	}
+}
+
+// ExecutablePath returns where a version's binary is installed
+func (i *Installer) ExecutablePath(version string) string {
+	return filepath.Join(i.versionsDir, version, "bedrock_server")
+}
+
+// Installed reports whether a version is present in the versions store
+func (i *Installer) Installed(version string) bool {
+	_, err := os.Stat(i.ExecutablePath(version))
+	return err == nil
+}
+
+// Install downloads, verifies and unpacks a version unless it is already installed.
+// The version directory only appears once it is complete, so a server never
+// starts from a half-written release.
+func (i *Installer) Install(ctx context.Context, version, checksum string) error {
+	if !ValidVersion(version) {
+		return fmt.Errorf("invalid Bedrock version %q", version)
+	}
+	if i.Installed(version) {
+		return nil
+	}
+	if i.mirror == "" {
+		return fmt.Errorf("Bedrock %s is not installed and no mirror is configured", version)
+	}
+	checksum = strings.ToLower(strings.TrimSpace(checksum))
+	if checksum == "" {
+		return fmt.Errorf("%w for Bedrock %s", ErrNoChecksum, version)
+	}
+
+	if err := os.MkdirAll(i.versionsDir, 0755); err != nil {
+		return fmt.Errorf("failed to create versions directory: %w", err)
+	}
+
+	archiveFile, err := os.CreateTemp(i.versionsDir, ".download-"+version+"-*.zip")
+	if err != nil {
+		return fmt.Errorf("failed to create download file: %w", err)
+	}
+	defer os.Remove(archiveFile.Name())
+	defer archiveFile.Close()
+
+	url := fmt.Sprintf("%s/bedrock-server-%s.zip", i.mirror, version)
+	i.logger.Infof("Downloading Bedrock %s from %s", version, url)
+	size, sum, err := i.download(ctx, url, archiveFile)
+	if err != nil {
+		return err
+	}
+	if sum != checksum {
+		return fmt.Errorf("checksum mismatch for Bedrock %s: got %s, expected %s", version, sum, checksum)
+	}
+	i.logger.Infof("Verified Bedrock %s (%d bytes, sha256 %s)", version, size, sum)
+
+	// Unpacked next to the version directory and renamed into place once verified
+	err = archive.Extract(archiveFile.Name(), filepath.Join(i.versionsDir, version), archive.Options{
+		Progress: archive.Steps(25, func(done, total int64) {
+			i.logger.Infof("Unpacking Bedrock %s: %d%%", version, done*100/total)
+		}),
+		Verify: func(dir string) error {
+			executable := filepath.Join(dir, "bedrock_server")
+			if _, err := os.Stat(executable); err != nil {
+				return fmt.Errorf("Bedrock %s archive has no bedrock_server", version)
+			}
+			return os.Chmod(executable, 0755)
+		},
+	})
+	if err != nil {
+		// Another install of the same version may have finished first
+		if i.Installed(version) {
+			return nil
+		}
+		return fmt.Errorf("failed to install Bedrock %s: %w", version, err)
+	}
+	i.logger.Infof("Installed Bedrock %s to %s", version, i.ExecutablePath(version))
+	return nil
+}
+
+// download writes url to out and returns its size and SHA-256
+func (i *Installer) download(ctx context.Context, url string, out io.Writer) (int64, string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return 0, "", err
+	}
+	resp, err := i.client.Do(req)
+	if err != nil {
+		return 0, "", fmt.Errorf("failed to download %s: %w", url, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return 0, "", fmt.Errorf("failed to download %s: %s", url, resp.Status)
+	}
+
+	hash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(out, hash), resp.Body)
+	if err != nil {
+		return 0, "", fmt.Errorf("failed to download %s: %w", url, err)
+	}
+	return size, hex.EncodeToString(hash.Sum(nil)), nil
+}
@@ -0,0 +1,105 @@
+package installer
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+// releaseZip returns a minimal Bedrock release and its SHA-256
+func releaseZip(t *testing.T) ([]byte, string) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	header := &zip.FileHeader{Name: "bedrock_server", Method: zip.Deflate}
+	header.SetMode(0755)
+	w, err := zw.CreateHeader(header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write([]byte("#!/bin/sh\n"))
+	w, _ = zw.Create("behavior_packs/vanilla/manifest.json")
+	w.Write([]byte("{}"))
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	sum := sha256.Sum256(buf.Bytes())
+	return buf.Bytes(), hex.EncodeToString(sum[:])
+}
+
+func newTestInstaller(t *testing.T, release []byte) *Installer {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/bedrock-server-1.20.51.01.zip", func(w http.ResponseWriter, r *http.Request) {
+		w.Write(release)
+	})
+	mirror := httptest.NewServer(mux)
+	t.Cleanup(mirror.Close)
+
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	return New(mirror.URL, t.TempDir(), logger)
+}
+
+func TestInstall(t *testing.T) {
+	release, sum := releaseZip(t)
+	inst := newTestInstaller(t, release)
+
+	if err := inst.Install(context.Background(), "1.20.51.01", sum); err != nil {
+		t.Fatalf("Install failed: %v", err)
+	}
+
+	stat, err := os.Stat(inst.ExecutablePath("1.20.51.01"))
+	if err != nil {
+		t.Fatalf("Expected bedrock_server to be installed: %v", err)
+	}
+	if stat.Mode().Perm()&0100 == 0 {
+		t.Errorf("Expected bedrock_server to be executable, got %v", stat.Mode())
+	}
+	if _, err := os.Stat(filepath.Join(inst.versionsDir, "1.20.51.01", "behavior_packs", "vanilla", "manifest.json")); err != nil {
+		t.Errorf("Expected the rest of the release to be unpacked: %v", err)
+	}
+}
+
+func TestInstallRejectsBadRelease(t *testing.T) {
+	release, sum := releaseZip(t)
+
+	cases := map[string]struct {
+		version  string
+		checksum string
+		wantErr  error
+	}{
+		"checksum mismatch": {"1.20.51.01", "00" + sum[2:], nil},
+		"no pinned hash":    {"1.20.51.01", "", ErrNoChecksum},
+		"not on mirror":     {"1.20.60.04", sum, nil},
+		"invalid version":   {"../1.20", sum, nil},
+	}
+
+	for name, c := range cases {
+		inst := newTestInstaller(t, release)
+		err := inst.Install(context.Background(), c.version, c.checksum)
+		if err == nil {
+			t.Errorf("%s: expected Install to fail", name)
+			continue
+		}
+		if c.wantErr != nil && !errors.Is(err, c.wantErr) {
+			t.Errorf("%s: expected %v, got %v", name, c.wantErr, err)
+		}
+
+		// Nothing, not even a staging directory, may be left behind
+		entries, _ := os.ReadDir(inst.versionsDir)
+		if len(entries) != 0 {
+			t.Errorf("%s: expected an empty versions directory, found %d entries", name, len(entries))
+		}
+	}
+}
@@ -0,0 +1,375 @@
+// Package layers splits an archive into fixed-size chunks described by a JSON
+// manifest, and reassembles it from the chunks alone, fetching any that are
+// missing or corrupt from a source.
+package layers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"minecraft-server-manager/internal/checksum"
+
+	"github.com/sirupsen/logrus"
+)
+
+// manifestVersion is bumped when the manifest format changes incompatibly
+const manifestVersion = 1
+
+// Manifest describes an archive and the chunks it was split into
+type Manifest struct {
+	Version   int     `json:"version"`
+	File      string  `json:"file"`
+	Size      int64   `json:"size"`
+	SHA256    string  `json:"sha256"`
+	ChunkSize int64   `json:"chunk_size"`
+	Chunks    []Chunk `json:"chunks"`
+}
+
+type Chunk struct {
+	Name   string `json:"name"`
+	Offset int64  `json:"offset"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// ChunkError lists the chunks that could not be found or verified
+type ChunkError struct {
+	Missing []string
+	Corrupt []string
+}
+
+func (e *ChunkError) Error() string {
+	var parts []string
+	if len(e.Missing) > 0 {
+		parts = append(parts, fmt.Sprintf("%d missing (%s)", len(e.Missing), strings.Join(e.Missing, ", ")))
+	}
+	if len(e.Corrupt) > 0 {
+		parts = append(parts, fmt.Sprintf("%d corrupt (%s)", len(e.Corrupt), strings.Join(e.Corrupt, ", ")))
+	}
+	return "layers " + strings.Join(parts, ", ")
+}
+
+// Source provides chunks that are missing locally
+type Source interface {
+	// Open returns a chunk's contents from offset on. A source that cannot start
+	// part-way returns the whole chunk and an offset of 0.
+	Open(ctx context.Context, chunk Chunk, offset int64) (io.ReadCloser, int64, error)
+
+	// String names the source in logs
+	String() string
+}
+
+// ManifestPath returns where the manifest of an archive's layers is kept
+func ManifestPath(dir, file string) string {
+	return filepath.Join(dir, baseName(file)+".layers.json")
+}
+
+func baseName(file string) string {
+	return strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))
+}
+
+func chunkName(file string, index int) string {
+	return fmt.Sprintf("%s.layer.%04d", baseName(file), index)
+}
+
+// Split cuts archivePath into chunkSize chunks in dir and writes their manifest.
+// Chunk files left over from an earlier split are removed.
+func Split(archivePath, dir string, chunkSize int64) (*Manifest, error) {
+	if chunkSize <= 0 {
+		return nil, fmt.Errorf("invalid chunk size %d", chunkSize)
+	}
+	in, err := os.Open(archivePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open archive: %w", err)
+	}
+	defer in.Close()
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create layers directory: %w", err)
+	}
+
+	manifest := &Manifest{
+		Version:   manifestVersion,
+		File:      filepath.Base(archivePath),
+		ChunkSize: chunkSize,
+		Chunks:    []Chunk{},
+	}
+	whole := sha256.New()
+	for index := 0; ; index++ {
+		chunk := Chunk{Name: chunkName(manifest.File, index), Offset: manifest.Size}
+		written, sum, err := writeChunk(filepath.Join(dir, chunk.Name), io.TeeReader(io.LimitReader(in, chunkSize), whole))
+		if err != nil {
+			return nil, fmt.Errorf("failed to write %s: %w", chunk.Name, err)
+		}
+		if written == 0 {
+			os.Remove(filepath.Join(dir, chunk.Name))
+			break
+		}
+		chunk.Size = written
+		chunk.SHA256 = sum
+		manifest.Chunks = append(manifest.Chunks, chunk)
+		manifest.Size += written
+	}
+	manifest.SHA256 = hex.EncodeToString(whole.Sum(nil))
+
+	if err := removeStaleChunks(dir, manifest); err != nil {
+		return nil, err
+	}
+	if err := WriteManifest(dir, manifest); err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
+
+// writeChunk writes r to path through a temporary file and returns its size and SHA-256
+func writeChunk(path string, r io.Reader) (int64, string, error) {
+	tmp := path + ".tmp"
+	out, err := os.Create(tmp)
+	if err != nil {
+		return 0, "", err
+	}
+	defer os.Remove(tmp)
+
+	hash := sha256.New()
+	written, err := io.Copy(io.MultiWriter(out, hash), r)
+	if err != nil {
+		out.Close()
+		return 0, "", err
+	}
+	if err := out.Close(); err != nil {
+		return 0, "", err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return 0, "", err
+	}
+	return written, hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+func removeStaleChunks(dir string, manifest *Manifest) error {
+	keep := make(map[string]bool)
+	for _, chunk := range manifest.Chunks {
+		keep[chunk.Name] = true
+	}
+	stale, err := filepath.Glob(filepath.Join(dir, baseName(manifest.File)+".layer.*"))
+	if err != nil {
+		return err
+	}
+	for _, path := range stale {
+		if !keep[filepath.Base(path)] {
+			if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// WriteManifest stores a manifest next to its chunks
+func WriteManifest(dir string, manifest *Manifest) error {
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	path := ManifestPath(dir, manifest.File)
+	if err := os.WriteFile(path+".tmp", data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
+
+// ReadManifest reads and validates a manifest
+func ReadManifest(path string) (*Manifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseManifest(data)
+}
+
+// ParseManifest validates a manifest, which may come from an untrusted mirror: chunk
+// names must be plain file names and the chunks must cover the file exactly
+func ParseManifest(data []byte) (*Manifest, error) {
+	var manifest Manifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("invalid layers manifest: %w", err)
+	}
+	if manifest.Version != manifestVersion {
+		return nil, fmt.Errorf("unsupported layers manifest version %d", manifest.Version)
+	}
+
+	var offset int64
+	for _, chunk := range manifest.Chunks {
+		if chunk.Name == "" || chunk.Name != filepath.Base(chunk.Name) || strings.HasPrefix(chunk.Name, ".") {
+			return nil, fmt.Errorf("invalid layer name %q", chunk.Name)
+		}
+		if chunk.Offset != offset || chunk.Size <= 0 || len(chunk.SHA256) != sha256.Size*2 {
+			return nil, fmt.Errorf("invalid layer %s", chunk.Name)
+		}
+		offset += chunk.Size
+	}
+	if offset != manifest.Size || len(manifest.SHA256) != sha256.Size*2 {
+		return nil, fmt.Errorf("layers do not add up to %s", manifest.File)
+	}
+	return &manifest, nil
+}
+
+// Check verifies every chunk in dir against the manifest and reports exactly which
+// are missing or corrupt. A nil error means all chunks are intact.
+func Check(dir string, manifest *Manifest) error {
+	problems := &ChunkError{}
+	for _, chunk := range manifest.Chunks {
+		switch err := verifyChunk(filepath.Join(dir, chunk.Name), chunk); {
+		case os.IsNotExist(err):
+			problems.Missing = append(problems.Missing, chunk.Name)
+		case err != nil:
+			problems.Corrupt = append(problems.Corrupt, chunk.Name)
+		}
+	}
+	if len(problems.Missing) > 0 || len(problems.Corrupt) > 0 {
+		return problems
+	}
+	return nil
+}
+
+func verifyChunk(path string, chunk Chunk) error {
+	stat, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if stat.Size() != chunk.Size {
+		return fmt.Errorf("%s is %d bytes, expected %d", chunk.Name, stat.Size(), chunk.Size)
+	}
+	sum, err := checksum.File(path)
+	if err != nil {
+		return err
+	}
+	if sum != chunk.SHA256 {
+		return fmt.Errorf("%s has SHA-256 %s, expected %s", chunk.Name, sum, chunk.SHA256)
+	}
+	return nil
+}
+
+// Assemble rebuilds the archive described by manifest from the chunks in dir,
+// writing it to out. Chunks that are missing or corrupt are fetched from source,
+// when given, resuming any partial download left by an earlier attempt.
+func Assemble(ctx context.Context, dir string, manifest *Manifest, out string, source Source, logger *logrus.Logger) error {
+	problems := &ChunkError{}
+	for _, chunk := range manifest.Chunks {
+		path := filepath.Join(dir, chunk.Name)
+		err := verifyChunk(path, chunk)
+		if err == nil {
+			continue
+		}
+		if source == nil {
+			if os.IsNotExist(err) {
+				problems.Missing = append(problems.Missing, chunk.Name)
+			} else {
+				logger.Warnf("Layer %v", err)
+				problems.Corrupt = append(problems.Corrupt, chunk.Name)
+			}
+			continue
+		}
+
+		if os.IsNotExist(err) {
+			logger.Infof("Layer %s is missing, fetching from %s", chunk.Name, source)
+		} else {
+			logger.Warnf("Layer %v, fetching from %s", err, source)
+			os.Remove(path)
+		}
+		if err := fetchChunk(ctx, source, dir, chunk); err != nil {
+			logger.Errorf("Failed to fetch layer %s: %v", chunk.Name, err)
+			problems.Corrupt = append(problems.Corrupt, chunk.Name)
+		}
+	}
+	if len(problems.Missing) > 0 || len(problems.Corrupt) > 0 {
+		return problems
+	}
+
+	return concatenate(dir, manifest, out)
+}
+
+// fetchChunk downloads a chunk to <name>.part, appending to what an earlier
+// attempt fetched when the source can resume, and moves it into place once its
+// hash matches
+func fetchChunk(ctx context.Context, source Source, dir string, chunk Chunk) error {
+	part := filepath.Join(dir, chunk.Name+".part")
+
+	var offset int64
+	if stat, err := os.Stat(part); err == nil && stat.Size() < chunk.Size {
+		offset = stat.Size()
+	} else if err == nil && verifyChunk(part, chunk) == nil {
+		// Fetched completely last time but never moved into place
+		return os.Rename(part, filepath.Join(dir, chunk.Name))
+	}
+
+	body, start, err := source.Open(ctx, chunk, offset)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	flags := os.O_CREATE | os.O_WRONLY | os.O_APPEND
+	if start == 0 {
+		flags = os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+	}
+	f, err := os.OpenFile(part, flags, 0644)
+	if err != nil {
+		return err
+	}
+	// Never write past the chunk's recorded size
+	if _, err := io.Copy(f, io.LimitReader(body, chunk.Size-start)); err != nil {
+		f.Close()
+		// Keep what arrived so the next attempt can resume
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	if err := verifyChunk(part, chunk); err != nil {
+		os.Remove(part)
+		return err
+	}
+	return os.Rename(part, filepath.Join(dir, chunk.Name))
+}
+
+// concatenate joins verified chunks into out and checks the whole-file hash
+func concatenate(dir string, manifest *Manifest, out string) error {
+	tmp := out + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp)
+
+	hash := sha256.New()
+	w := io.MultiWriter(f, hash)
+	for _, chunk := range manifest.Chunks {
+		in, err := os.Open(filepath.Join(dir, chunk.Name))
+		if err != nil {
+			f.Close()
+			return err
+		}
+		_, err = io.Copy(w, in)
+		in.Close()
+		if err != nil {
+			f.Close()
+			return fmt.Errorf("failed to copy %s: %w", chunk.Name, err)
+		}
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	if sum := hex.EncodeToString(hash.Sum(nil)); sum != manifest.SHA256 {
+		return fmt.Errorf("reassembled %s has SHA-256 %s, expected %s", manifest.File, sum, manifest.SHA256)
+	}
+	return os.Rename(tmp, out)
+}
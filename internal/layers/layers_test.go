@@ -0,0 +1,177 @@
+package layers
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+func writeArchive(t *testing.T, size int) (string, []byte) {
+	t.Helper()
+	data := make([]byte, size)
+	if _, err := rand.Read(data); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "bedrock-server.zip")
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path, data
+}
+
+func quietLogger() *logrus.Logger {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	return logger
+}
+
+func TestSplitAndAssemble(t *testing.T) {
+	archivePath, data := writeArchive(t, 10*1024+7)
+	dir := t.TempDir()
+
+	manifest, err := Split(archivePath, dir, 1024)
+	if err != nil {
+		t.Fatalf("Split failed: %v", err)
+	}
+	if len(manifest.Chunks) != 11 || manifest.Chunks[10].Size != 7 {
+		t.Fatalf("Expected 11 chunks with a 7 byte tail, got %d", len(manifest.Chunks))
+	}
+
+	// Reassembly needs nothing but the layers and the manifest
+	os.Remove(archivePath)
+	read, err := ReadManifest(ManifestPath(dir, "bedrock-server.zip"))
+	if err != nil {
+		t.Fatalf("ReadManifest failed: %v", err)
+	}
+	out := filepath.Join(t.TempDir(), "recombined.zip")
+	if err := Assemble(context.Background(), dir, read, out, nil, quietLogger()); err != nil {
+		t.Fatalf("Assemble failed: %v", err)
+	}
+	if got, _ := os.ReadFile(out); !bytes.Equal(got, data) {
+		t.Error("Reassembled archive differs from the original")
+	}
+
+	// Splitting with a larger chunk size removes the chunks no longer needed
+	if _, err := Split(out, dir, 4096); err != nil {
+		t.Fatalf("Split failed: %v", err)
+	}
+	chunks, _ := filepath.Glob(filepath.Join(dir, "recombined.layer.*"))
+	if len(chunks) != 3 {
+		t.Errorf("Expected 3 chunks after resplitting, got %d", len(chunks))
+	}
+}
+
+func TestCheckReportsExactChunks(t *testing.T) {
+	archivePath, _ := writeArchive(t, 4096)
+	dir := t.TempDir()
+	manifest, err := Split(archivePath, dir, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	os.Remove(filepath.Join(dir, manifest.Chunks[1].Name))
+	corrupt := filepath.Join(dir, manifest.Chunks[3].Name)
+	data, _ := os.ReadFile(corrupt)
+	data[0] ^= 0xff
+	os.WriteFile(corrupt, data, 0644)
+
+	var chunkErr *ChunkError
+	if err := Check(dir, manifest); !errors.As(err, &chunkErr) {
+		t.Fatalf("Expected a ChunkError, got %v", err)
+	}
+	if len(chunkErr.Missing) != 1 || chunkErr.Missing[0] != manifest.Chunks[1].Name {
+		t.Errorf("Expected %s missing, got %v", manifest.Chunks[1].Name, chunkErr.Missing)
+	}
+	if len(chunkErr.Corrupt) != 1 || chunkErr.Corrupt[0] != manifest.Chunks[3].Name {
+		t.Errorf("Expected %s corrupt, got %v", manifest.Chunks[3].Name, chunkErr.Corrupt)
+	}
+
+	out := filepath.Join(t.TempDir(), "out.zip")
+	if err := Assemble(context.Background(), dir, manifest, out, nil, quietLogger()); !errors.As(err, &chunkErr) {
+		t.Errorf("Expected Assemble to fail with a ChunkError, got %v", err)
+	}
+	if _, err := os.Stat(out); !os.IsNotExist(err) {
+		t.Errorf("Expected no output from a failed assembly, got %v", err)
+	}
+}
+
+func TestAssembleResumesFromMirror(t *testing.T) {
+	archivePath, data := writeArchive(t, 4096)
+	mirror := t.TempDir()
+	manifest, err := Split(archivePath, mirror, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var ranges []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ranges = append(ranges, r.Header.Get("Range"))
+		http.ServeFile(w, r, filepath.Join(mirror, filepath.Base(r.URL.Path)))
+	}))
+	defer server.Close()
+
+	source := NewHTTPSource(server.URL)
+	fetched, err := source.Manifest(context.Background(), "bedrock-server.zip")
+	if err != nil {
+		t.Fatalf("Manifest failed: %v", err)
+	}
+	if fetched.SHA256 != manifest.SHA256 {
+		t.Fatalf("Expected the mirror's manifest, got SHA-256 %s", fetched.SHA256)
+	}
+
+	// One chunk is local, one was partly downloaded and the rest are missing
+	dir := t.TempDir()
+	chunk0, _ := os.ReadFile(filepath.Join(mirror, manifest.Chunks[0].Name))
+	os.WriteFile(filepath.Join(dir, manifest.Chunks[0].Name), chunk0, 0644)
+	chunk2, _ := os.ReadFile(filepath.Join(mirror, manifest.Chunks[2].Name))
+	os.WriteFile(filepath.Join(dir, manifest.Chunks[2].Name+".part"), chunk2[:100], 0644)
+
+	ranges = nil
+	out := filepath.Join(dir, "recombined.zip")
+	if err := Assemble(context.Background(), dir, fetched, out, source, quietLogger()); err != nil {
+		t.Fatalf("Assemble failed: %v", err)
+	}
+	if got, _ := os.ReadFile(out); !bytes.Equal(got, data) {
+		t.Error("Reassembled archive differs from the original")
+	}
+
+	if len(ranges) != 3 {
+		t.Errorf("Expected 3 chunk downloads, got %d", len(ranges))
+	}
+	if !contains(ranges, "bytes=100-") {
+		t.Errorf("Expected the partial chunk to resume at byte 100, got ranges %q", ranges)
+	}
+	if parts, _ := filepath.Glob(filepath.Join(dir, "*.part")); len(parts) != 0 {
+		t.Errorf("Expected no partial chunks left, got %v", parts)
+	}
+}
+
+func TestParseManifestRejectsUnsafeNames(t *testing.T) {
+	hash := strings.Repeat("0", 64)
+	for _, name := range []string{"../evil", "a/b", ".hidden", ""} {
+		data := fmt.Sprintf(`{"version":1,"file":"x.zip","size":1,"sha256":%q,"chunk_size":1,"chunks":[{"name":%q,"offset":0,"size":1,"sha256":%q}]}`, hash, name, hash)
+		if _, err := ParseManifest([]byte(data)); err == nil {
+			t.Errorf("Expected %q to be rejected", name)
+		}
+	}
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
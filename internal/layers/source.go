@@ -0,0 +1,100 @@
+package layers
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// FileSource reads chunks from the original archive
+type FileSource struct {
+	path string
+}
+
+func NewFileSource(path string) *FileSource {
+	return &FileSource{path: path}
+}
+
+func (s *FileSource) Open(ctx context.Context, chunk Chunk, offset int64) (io.ReadCloser, int64, error) {
+	f, err := os.Open(s.path)
+	if err != nil {
+		return nil, 0, err
+	}
+	return readCloser{io.NewSectionReader(f, chunk.Offset+offset, chunk.Size-offset), f}, offset, nil
+}
+
+func (s *FileSource) String() string {
+	return s.path
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// HTTPSource downloads chunks from <base>/<name>, such as a plain directory served
+// over HTTP. Partial chunks are resumed with range requests.
+type HTTPSource struct {
+	base   string
+	client *http.Client
+}
+
+func NewHTTPSource(base string) *HTTPSource {
+	return &HTTPSource{
+		base:   strings.TrimSuffix(base, "/"),
+		client: &http.Client{Timeout: 30 * time.Minute},
+	}
+}
+
+func (s *HTTPSource) Open(ctx context.Context, chunk Chunk, offset int64) (io.ReadCloser, int64, error) {
+	return s.get(ctx, chunk.Name, offset)
+}
+
+// Manifest downloads the manifest of an archive's layers
+func (s *HTTPSource) Manifest(ctx context.Context, file string) (*Manifest, error) {
+	body, _, err := s.get(ctx, baseName(file)+".layers.json", 0)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+	return ParseManifest(data)
+}
+
+func (s *HTTPSource) get(ctx context.Context, name string, offset int64) (io.ReadCloser, int64, error) {
+	url := s.base + "/" + name
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to download %s: %w", url, err)
+	}
+	switch {
+	case resp.StatusCode == http.StatusPartialContent && offset > 0:
+		return resp.Body, offset, nil
+	case resp.StatusCode == http.StatusOK:
+		// The server ignored the range, so start over
+		return resp.Body, 0, nil
+	default:
+		resp.Body.Close()
+		return nil, 0, fmt.Errorf("failed to download %s: %s", url, resp.Status)
+	}
+}
+
+func (s *HTTPSource) String() string {
+	return s.base
+}
@@ -0,0 +1,362 @@
+// Package packs installs behavior and resource packs into a Bedrock world and
+// writes the world_*_packs.json files that enable them.
+package packs
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"minecraft-server-manager/internal/archive"
+)
+
+// Kind is the type of a pack, which decides the world folder it goes into
+type Kind string
+
+const (
+	Behavior Kind = "behavior"
+	Resource Kind = "resource"
+)
+
+// recordFile lists the packs the manager installed in a world, so packs dropped
+// from the configuration can be removed without touching any added by hand
+const recordFile = ".managed_packs.json"
+
+// ErrInvalidPack is returned for a pack without a usable manifest.json
+var ErrInvalidPack = errors.New("invalid pack")
+
+// Dir returns the world folder holding packs of a kind, e.g. behavior_packs
+func (k Kind) Dir() string {
+	return string(k) + "_packs"
+}
+
+// listFile returns the world file enabling packs of a kind
+func (k Kind) listFile() string {
+	return "world_" + string(k) + "_packs.json"
+}
+
+// Pack is a pack's identity as read from its manifest.json
+type Pack struct {
+	Name    string `json:"name"` // Folder the pack is installed in
+	UUID    string `json:"pack_id"`
+	Version [3]int `json:"version"`
+	Kind    Kind   `json:"kind"`
+}
+
+// entry is one element of world_behavior_packs.json or world_resource_packs.json
+type entry struct {
+	UUID    string `json:"pack_id"`
+	Version [3]int `json:"version"`
+}
+
+type manifestFile struct {
+	Header struct {
+		Name    string          `json:"name"`
+		UUID    string          `json:"uuid"`
+		Version json.RawMessage `json:"version"`
+	} `json:"header"`
+	Modules []struct {
+		Type string `json:"type"`
+	} `json:"modules"`
+}
+
+// ReadManifest reads the identity and kind of the pack in dir
+func ReadManifest(dir string) (*Pack, error) {
+	data, err := os.ReadFile(filepath.Join(dir, "manifest.json"))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPack, err)
+	}
+	// Manifests saved by Windows editors often start with a byte order mark
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+
+	var manifest manifestFile
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("%w: manifest.json: %v", ErrInvalidPack, err)
+	}
+	if manifest.Header.UUID == "" {
+		return nil, fmt.Errorf("%w: manifest.json has no header.uuid", ErrInvalidPack)
+	}
+	version, err := parseVersion(manifest.Header.Version)
+	if err != nil {
+		return nil, fmt.Errorf("%w: manifest.json: %v", ErrInvalidPack, err)
+	}
+
+	pack := &Pack{Name: filepath.Base(dir), UUID: manifest.Header.UUID, Version: version}
+	for _, module := range manifest.Modules {
+		switch module.Type {
+		case "resources":
+			pack.Kind = Resource
+		case "data", "script", "client_data":
+			pack.Kind = Behavior
+		}
+		if pack.Kind != "" {
+			break
+		}
+	}
+	if pack.Kind == "" {
+		return nil, fmt.Errorf("%w: manifest.json has no data or resources module", ErrInvalidPack)
+	}
+	return pack, nil
+}
+
+// parseVersion accepts both [1, 0, 0] and the newer "1.0.0" form
+func parseVersion(raw json.RawMessage) ([3]int, error) {
+	var version [3]int
+	var list []int
+	if err := json.Unmarshal(raw, &list); err == nil {
+		if len(list) != 3 {
+			return version, fmt.Errorf("version %s does not have three parts", raw)
+		}
+		copy(version[:], list)
+		return version, nil
+	}
+
+	var text string
+	if err := json.Unmarshal(raw, &text); err != nil {
+		return version, fmt.Errorf("invalid version %s", raw)
+	}
+	// Drop any pre-release or build suffix, e.g. 1.2.0-beta
+	text, _, _ = strings.Cut(text, "-")
+	text, _, _ = strings.Cut(text, "+")
+	parts := strings.Split(text, ".")
+	if len(parts) != 3 {
+		return version, fmt.Errorf("version %q does not have three parts", text)
+	}
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			return version, fmt.Errorf("invalid version %q", text)
+		}
+		version[i] = n
+	}
+	return version, nil
+}
+
+// Apply installs the packs of one kind into worldDir and enables them in its
+// list file. refs are pack directories or .mcpack files, relative to sourceDir.
+// Packs the manager installed earlier that are no longer listed are removed;
+// packs added or enabled by hand are left alone. With no refs and no earlier
+// packs the world is untouched.
+func Apply(sourceDir, worldDir string, kind Kind, refs []string) ([]Pack, error) {
+	record, err := readRecord(worldDir)
+	if err != nil {
+		return nil, err
+	}
+	if len(refs) == 0 && len(record[kind]) == 0 {
+		return nil, nil
+	}
+
+	// Only the manager's own entries are replaced in the list file
+	listPath := filepath.Join(worldDir, kind.listFile())
+	entries, err := readList(listPath)
+	if err != nil {
+		return nil, err
+	}
+	managed := make(map[string]bool)
+	drop := make(map[string]bool)
+	for _, recorded := range record[kind] {
+		managed[recorded.Name] = true
+		if uuid := recorded.uuid(worldDir, kind); uuid != "" {
+			drop[uuid] = true
+		}
+	}
+
+	installed := []Pack{}
+	keep := make(map[string]bool)
+	for _, ref := range refs {
+		pack, err := install(sourceDir, worldDir, kind, ref, managed)
+		if err != nil {
+			return nil, fmt.Errorf("failed to install %s: %w", ref, err)
+		}
+		if keep[pack.Name] {
+			return nil, fmt.Errorf("failed to install %s: another pack is already installed as %s", ref, pack.Name)
+		}
+		keep[pack.Name] = true
+		drop[pack.UUID] = true
+		installed = append(installed, *pack)
+	}
+
+	for _, recorded := range record[kind] {
+		if !keep[recorded.Name] {
+			if err := os.RemoveAll(filepath.Join(worldDir, kind.Dir(), recorded.Name)); err != nil {
+				return nil, fmt.Errorf("failed to remove pack %s: %w", recorded.Name, err)
+			}
+		}
+	}
+
+	list := []interface{}{}
+	for _, e := range entries {
+		if id, _ := e["pack_id"].(string); !drop[id] {
+			list = append(list, e)
+		}
+	}
+	for _, pack := range installed {
+		list = append(list, entry{UUID: pack.UUID, Version: pack.Version})
+	}
+	if err := writeJSON(listPath, list); err != nil {
+		return nil, fmt.Errorf("failed to write %s: %w", kind.listFile(), err)
+	}
+
+	packs := make([]recordedPack, 0, len(installed))
+	for _, pack := range installed {
+		packs = append(packs, recordedPack{Name: pack.Name, UUID: pack.UUID})
+	}
+	record[kind] = packs
+	if err := writeJSON(filepath.Join(worldDir, recordFile), record); err != nil {
+		return nil, fmt.Errorf("failed to record installed packs: %w", err)
+	}
+	return installed, nil
+}
+
+// readList reads a world's list file, keeping any fields of its entries
+func readList(path string) ([]map[string]interface{}, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var entries []map[string]interface{}
+	if err := json.Unmarshal(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf")), &entries); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", filepath.Base(path), err)
+	}
+	return entries, nil
+}
+
+// install copies or unpacks one pack into the world and returns it. A folder of
+// the same name is only replaced if the manager installed it.
+func install(sourceDir, worldDir string, kind Kind, ref string, managed map[string]bool) (*Pack, error) {
+	rel := path.Clean(strings.ReplaceAll(ref, "\\", "/"))
+	if ref == "" || path.IsAbs(rel) || rel == ".." || strings.HasPrefix(rel, "../") {
+		return nil, fmt.Errorf("pack path must be relative to %s", sourceDir)
+	}
+	source := filepath.Join(sourceDir, filepath.FromSlash(rel))
+	stat, err := os.Stat(source)
+	if err != nil {
+		return nil, err
+	}
+
+	packsDir := filepath.Join(worldDir, kind.Dir())
+	if err := os.MkdirAll(packsDir, 0755); err != nil {
+		return nil, err
+	}
+	// Prepare the pack next to its final place so it can be renamed in
+	tmpDir, err := os.MkdirTemp(packsDir, ".install-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	name := filepath.Base(source)
+	staged := filepath.Join(tmpDir, "pack")
+	if stat.IsDir() {
+		if err := archive.CopyTree(source, staged); err != nil {
+			return nil, err
+		}
+	} else {
+		if !strings.EqualFold(filepath.Ext(source), ".mcpack") {
+			return nil, fmt.Errorf("%w: %s is neither a directory nor a .mcpack", ErrInvalidPack, ref)
+		}
+		name = strings.TrimSuffix(name, filepath.Ext(name))
+		if err := archive.Extract(source, staged, archive.Options{}); err != nil {
+			return nil, err
+		}
+		// Some tools zip the pack's folder rather than its contents
+		if root, ok := singleSubdir(staged); ok {
+			staged = root
+		}
+	}
+
+	pack, err := ReadManifest(staged)
+	if err != nil {
+		return nil, err
+	}
+	if pack.Kind != kind {
+		return nil, fmt.Errorf("%w: %s is a %s pack", ErrInvalidPack, ref, pack.Kind)
+	}
+	pack.Name = name
+
+	target := filepath.Join(packsDir, name)
+	if _, err := os.Lstat(target); err == nil && !managed[name] {
+		return nil, fmt.Errorf("%s was not installed by the manager, remove it or rename the pack", target)
+	}
+	if err := os.RemoveAll(target); err != nil {
+		return nil, err
+	}
+	if err := os.Rename(staged, target); err != nil {
+		return nil, err
+	}
+	return pack, nil
+}
+
+// singleSubdir returns the only entry of dir when it is a directory without a
+// manifest.json of dir's own
+func singleSubdir(dir string) (string, bool) {
+	if _, err := os.Stat(filepath.Join(dir, "manifest.json")); err == nil {
+		return "", false
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil || len(entries) != 1 || !entries[0].IsDir() {
+		return "", false
+	}
+	return filepath.Join(dir, entries[0].Name()), true
+}
+
+// recordedPack is a pack listed in the record file
+type recordedPack struct {
+	Name string `json:"name"`
+	UUID string `json:"pack_id,omitempty"`
+}
+
+// UnmarshalJSON also accepts the bare folder names of older record files
+func (r *recordedPack) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, &r.Name); err == nil {
+		return nil
+	}
+	type plain recordedPack
+	return json.Unmarshal(data, (*plain)(r))
+}
+
+// uuid returns the pack's UUID, from its manifest if the record predates UUIDs
+func (r recordedPack) uuid(worldDir string, kind Kind) string {
+	if r.UUID != "" {
+		return r.UUID
+	}
+	if pack, err := ReadManifest(filepath.Join(worldDir, kind.Dir(), r.Name)); err == nil {
+		return pack.UUID
+	}
+	return ""
+}
+
+func readRecord(worldDir string) (map[Kind][]recordedPack, error) {
+	record := make(map[Kind][]recordedPack)
+	data, err := os.ReadFile(filepath.Join(worldDir, recordFile))
+	if os.IsNotExist(err) {
+		return record, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &record); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", recordFile, err)
+	}
+	return record, nil
+}
+
+func writeJSON(path string, value interface{}) error {
+	data, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
@@ -0,0 +1,155 @@
+package packs
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"minecraft-server-manager/internal/testutil"
+)
+
+const behaviorManifest = `{"format_version": 2, "header": {"name": "Castle", "uuid": "b1", "version": [1, 2, 3]}, "modules": [{"type": "data", "uuid": "b2", "version": [1, 2, 3]}]}`
+
+const resourceManifest = "\xef\xbb\xbf" + `{"format_version": 3, "header": {"name": "Textures", "uuid": "r1", "version": "2.0.1"}, "modules": [{"type": "resources", "uuid": "r2", "version": "2.0.1"}]}`
+
+// writeMCPack zips a pack whose files sit inside a folder, as some tools produce
+func writeMCPack(t *testing.T, path string) {
+	t.Helper()
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := zip.NewWriter(f)
+	w, _ := zw.Create("Textures/manifest.json")
+	w.Write([]byte(resourceManifest))
+	w, _ = zw.Create("Textures/textures/blocks/stone.png")
+	w.Write([]byte("png"))
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+}
+
+func readEntries(t *testing.T, path string) []entry {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var entries []entry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		t.Fatal(err)
+	}
+	return entries
+}
+
+func TestApply(t *testing.T) {
+	assets := t.TempDir()
+	testutil.WriteFile(t, filepath.Join(assets, "packs", "castle", "manifest.json"), behaviorManifest)
+	testutil.WriteFile(t, filepath.Join(assets, "packs", "castle", "scripts", "main.js"), "")
+	writeMCPack(t, filepath.Join(assets, "packs", "textures.mcpack"))
+
+	world := filepath.Join(t.TempDir(), "world")
+	testutil.WriteFile(t, filepath.Join(world, "behavior_packs", "by-hand", "manifest.json"), behaviorManifest)
+
+	if _, err := Apply(assets, world, Behavior, []string{"packs/castle"}); err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	installed, err := Apply(assets, world, Resource, []string{"packs/textures.mcpack"})
+	if err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	if len(installed) != 1 || installed[0].Name != "textures" || installed[0].Version != [3]int{2, 0, 1} {
+		t.Errorf("Unexpected resource packs: %+v", installed)
+	}
+
+	if _, err := os.Stat(filepath.Join(world, "behavior_packs", "castle", "scripts", "main.js")); err != nil {
+		t.Errorf("Expected the behavior pack's files: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(world, "resource_packs", "textures", "manifest.json")); err != nil {
+		t.Errorf("Expected the .mcpack unpacked without its folder: %v", err)
+	}
+	entries := readEntries(t, filepath.Join(world, "world_behavior_packs.json"))
+	if len(entries) != 1 || entries[0].UUID != "b1" || entries[0].Version != [3]int{1, 2, 3} {
+		t.Errorf("Unexpected world_behavior_packs.json: %+v", entries)
+	}
+	entries = readEntries(t, filepath.Join(world, "world_resource_packs.json"))
+	if len(entries) != 1 || entries[0].UUID != "r1" {
+		t.Errorf("Unexpected world_resource_packs.json: %+v", entries)
+	}
+
+	// Dropping a pack from the configuration removes it, but not packs added by hand
+	if _, err := Apply(assets, world, Behavior, nil); err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(world, "behavior_packs", "castle")); !os.IsNotExist(err) {
+		t.Errorf("Expected the dropped pack to be removed, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(world, "behavior_packs", "by-hand")); err != nil {
+		t.Errorf("Expected the hand-installed pack to be kept: %v", err)
+	}
+	if entries := readEntries(t, filepath.Join(world, "world_behavior_packs.json")); len(entries) != 0 {
+		t.Errorf("Expected no enabled behavior packs, got %+v", entries)
+	}
+}
+
+func TestApplyKeepsHandEnabledPacks(t *testing.T) {
+	assets := t.TempDir()
+	testutil.WriteFile(t, filepath.Join(assets, "castle", "manifest.json"), behaviorManifest)
+	world := t.TempDir()
+	testutil.WriteFile(t, filepath.Join(world, "behavior_packs", "by-hand", "manifest.json"), `{"header": {"uuid": "h1", "version": [1, 0, 0]}, "modules": [{"type": "data"}]}`)
+	testutil.WriteFile(t, filepath.Join(world, "world_behavior_packs.json"), `[{"pack_id": "h1", "version": [1, 0, 0], "subpack": "low"}]`)
+
+	if _, err := Apply(assets, world, Behavior, []string{"castle"}); err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	// Applying again replaces the manager's entry rather than adding a second one
+	if _, err := Apply(assets, world, Behavior, []string{"castle"}); err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	data, _ := os.ReadFile(filepath.Join(world, "world_behavior_packs.json"))
+	var entries []map[string]interface{}
+	json.Unmarshal(data, &entries)
+	if len(entries) != 2 || entries[0]["pack_id"] != "h1" || entries[0]["subpack"] != "low" || entries[1]["pack_id"] != "b1" {
+		t.Errorf("Expected the hand-enabled pack followed by the managed one, got %s", data)
+	}
+
+	if _, err := Apply(assets, world, Behavior, nil); err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	if entries := readEntries(t, filepath.Join(world, "world_behavior_packs.json")); len(entries) != 1 || entries[0].UUID != "h1" {
+		t.Errorf("Expected only the hand-enabled pack left, got %+v", entries)
+	}
+
+	// A folder installed by hand is not replaced by a managed pack of the same name
+	testutil.WriteFile(t, filepath.Join(assets, "by-hand", "manifest.json"), behaviorManifest)
+	if _, err := Apply(assets, world, Behavior, []string{"by-hand"}); err == nil {
+		t.Error("Expected a pack named like a hand-installed folder to be refused")
+	}
+	if pack, err := ReadManifest(filepath.Join(world, "behavior_packs", "by-hand")); err != nil || pack.UUID != "h1" {
+		t.Errorf("Expected the hand-installed pack untouched, got %+v (%v)", pack, err)
+	}
+}
+
+func TestApplyRejects(t *testing.T) {
+	assets := t.TempDir()
+	testutil.WriteFile(t, filepath.Join(assets, "castle", "manifest.json"), behaviorManifest)
+	testutil.WriteFile(t, filepath.Join(assets, "broken", "manifest.json"), `{"header": {}}`)
+	world := t.TempDir()
+
+	if _, err := Apply(assets, world, Resource, []string{"castle"}); !errors.Is(err, ErrInvalidPack) {
+		t.Errorf("Expected a behavior pack to be refused as a resource pack, got %v", err)
+	}
+	if _, err := Apply(assets, world, Behavior, []string{"broken"}); !errors.Is(err, ErrInvalidPack) {
+		t.Errorf("Expected ErrInvalidPack for a manifest without a uuid, got %v", err)
+	}
+	if _, err := Apply(assets, world, Behavior, []string{"../outside"}); err == nil {
+		t.Error("Expected a path outside the assets directory to be refused")
+	}
+	if leftovers, _ := filepath.Glob(filepath.Join(world, "*", ".install-*")); len(leftovers) != 0 {
+		t.Errorf("Expected no staging directories left, got %v", leftovers)
+	}
+}
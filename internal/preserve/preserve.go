@@ -0,0 +1,397 @@
+// Package preserve carries operator-managed files over when a Bedrock release is
+// replaced, merging them with the new release's defaults.
+package preserve
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"minecraft-server-manager/internal/checksum"
+)
+
+// Paths are the files and directories of a Bedrock directory that operators manage.
+// A file inside them that differs from what the release shipped is kept.
+var Paths = []string{
+	"allowlist.json",
+	"permissions.json",
+	"whitelist.json",
+	"config",
+	"behavior_packs",
+	"resource_packs",
+	"development_behavior_packs",
+	"development_resource_packs",
+}
+
+const (
+	// PropertiesFile is merged key by key rather than kept or replaced whole
+	PropertiesFile = "server.properties"
+
+	// stateDir holds what the release shipped, as the base of the next merge
+	stateDir = ".vendor"
+
+	// worldsDir is moved over whole, whether it is a link or a directory
+	worldsDir = "worlds"
+)
+
+// File merge actions
+const (
+	FileKept     = "kept"     // Operator's copy kept over an unchanged vendor file
+	FileAdded    = "added"    // File the operator added
+	FileDeleted  = "deleted"  // File the operator deleted stays deleted
+	FileConflict = "conflict" // Both changed the file; the operator's copy wins
+	FileReplaced = "replaced" // No record of the previous release, so the vendor's copy is used
+)
+
+// manifest records the SHA-256 of every file a release shipped
+type manifest struct {
+	Archive string            `json:"archive"` // SHA-256 of the release archive
+	Files   map[string]string `json:"files"`
+}
+
+// FileChange describes what a merge did with one operator-managed file
+type FileChange struct {
+	Path   string `json:"path"`
+	Action string `json:"action"`
+}
+
+// Report describes an upgrade from one release to the next
+type Report struct {
+	Time        time.Time `json:"time"`
+	FromArchive string    `json:"from_archive,omitempty"`
+	ToArchive   string    `json:"to_archive"`
+
+	// Files the release itself added, removed or changed
+	VendorAdded   []string `json:"vendor_added"`
+	VendorRemoved []string `json:"vendor_removed"`
+	VendorChanged []string `json:"vendor_changed"`
+
+	Files      []FileChange     `json:"files"`
+	Properties []PropertyChange `json:"properties"`
+}
+
+// Upgraded reports whether the release changed, rather than being re-extracted
+func (r *Report) Upgraded() bool {
+	return r.FromArchive != r.ToArchive
+}
+
+// Conflicts counts the files and keys both the operator and the release changed
+func (r *Report) Conflicts() int {
+	count := 0
+	for _, change := range r.Files {
+		if change.Action == FileConflict {
+			count++
+		}
+	}
+	for _, change := range r.Properties {
+		if change.Action == PropertyConflict {
+			count++
+		}
+	}
+	return count
+}
+
+// Snapshot records the files of a freshly extracted release in dir, so the next
+// merge can tell the operator's changes from the vendor's
+func Snapshot(dir, archive string) error {
+	files, err := hashFiles(dir)
+	if err != nil {
+		return fmt.Errorf("failed to hash release files: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Join(dir, stateDir), 0755); err != nil {
+		return err
+	}
+	if err := copyFile(filepath.Join(dir, PropertiesFile), filepath.Join(dir, stateDir, PropertiesFile)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to keep vendor %s: %w", PropertiesFile, err)
+	}
+
+	data, err := json.MarshalIndent(manifest{Archive: archive, Files: files}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, stateDir, "manifest.json"), data, 0644)
+}
+
+// Merge carries the operator-managed files of the previous release directory into
+// dir, which must have been snapshotted. Files are kept when the operator changed
+// them; anything they left alone takes the new release's version.
+func Merge(previous, dir string) (*Report, error) {
+	next, err := readManifest(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read release manifest: %w", err)
+	}
+	// A directory extracted before snapshots existed has no base
+	base, err := readManifest(previous)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read previous release manifest: %w", err)
+	}
+
+	report := &Report{
+		Time:          time.Now(),
+		ToArchive:     next.Archive,
+		VendorAdded:   []string{},
+		VendorRemoved: []string{},
+		VendorChanged: []string{},
+		Files:         []FileChange{},
+		Properties:    []PropertyChange{},
+	}
+	if base != nil {
+		report.FromArchive = base.Archive
+		diffManifests(report, base, next)
+	}
+
+	current, err := hashFiles(previous)
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash previous release files: %w", err)
+	}
+
+	for _, rel := range sortedKeys(current) {
+		if !managed(rel) {
+			continue
+		}
+		action := mergeAction(rel, current[rel], base, next)
+		if action == "" {
+			continue
+		}
+		report.Files = append(report.Files, FileChange{Path: rel, Action: action})
+		if action == FileReplaced {
+			continue
+		}
+
+		target := filepath.Join(dir, filepath.FromSlash(rel))
+		if action == FileConflict {
+			// Keep the vendor's new copy in the release record for comparison
+			if err := copyFile(target, filepath.Join(dir, stateDir, "conflicts", filepath.FromSlash(rel))); err != nil {
+				return nil, err
+			}
+		}
+		if err := copyFile(filepath.Join(previous, filepath.FromSlash(rel)), target); err != nil {
+			return nil, fmt.Errorf("failed to keep %s: %w", rel, err)
+		}
+	}
+
+	// Files the operator deleted, as long as the release did not change them
+	if base != nil {
+		for _, rel := range sortedKeys(base.Files) {
+			if _, ok := current[rel]; ok || !managed(rel) || next.Files[rel] != base.Files[rel] {
+				continue
+			}
+			if err := os.Remove(filepath.Join(dir, filepath.FromSlash(rel))); err != nil && !os.IsNotExist(err) {
+				return nil, err
+			}
+			report.Files = append(report.Files, FileChange{Path: rel, Action: FileDeleted})
+		}
+	}
+
+	if err := mergePropertiesFile(report, previous, dir, base != nil); err != nil {
+		return nil, err
+	}
+
+	// Worlds are never part of a release
+	if _, err := os.Lstat(filepath.Join(previous, worldsDir)); err == nil {
+		if err := os.Rename(filepath.Join(previous, worldsDir), filepath.Join(dir, worldsDir)); err != nil {
+			return nil, fmt.Errorf("failed to move worlds: %w", err)
+		}
+	}
+
+	return report, nil
+}
+
+// mergeAction decides what happens to an operator-managed file, given its hash in
+// the previous directory. An empty action leaves the new release's copy alone.
+func mergeAction(rel, hash string, base, next *manifest) string {
+	vendorHash, inNext := next.Files[rel]
+	if inNext && hash == vendorHash {
+		return ""
+	}
+
+	if base == nil {
+		// Without a base, anything the new release ships in a managed directory is
+		// taken to be the previous release's copy; top-level files are the operator's
+		if !inNext {
+			return FileAdded
+		}
+		if strings.Contains(rel, "/") {
+			return FileReplaced
+		}
+		return FileKept
+	}
+
+	baseHash, inBase := base.Files[rel]
+	switch {
+	case inBase && hash == baseHash:
+		// Untouched, so the new release's copy (or its removal) wins
+		return ""
+	case !inBase && !inNext:
+		return FileAdded
+	case inNext && (!inBase || vendorHash != baseHash):
+		return FileConflict
+	default:
+		return FileKept
+	}
+}
+
+func mergePropertiesFile(report *Report, previous, dir string, haveBase bool) error {
+	ours, err := os.ReadFile(filepath.Join(previous, PropertiesFile))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	theirs, err := os.ReadFile(filepath.Join(dir, PropertiesFile))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	var base []byte
+	if haveBase {
+		base, err = os.ReadFile(filepath.Join(previous, stateDir, PropertiesFile))
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	merged, changes := MergeProperties(base, ours, theirs)
+	report.Properties = append(report.Properties, changes...)
+	return os.WriteFile(filepath.Join(dir, PropertiesFile), merged, 0644)
+}
+
+// diffManifests lists the files the release added, removed and changed
+func diffManifests(report *Report, base, next *manifest) {
+	for _, rel := range sortedKeys(next.Files) {
+		hash, ok := base.Files[rel]
+		if !ok {
+			report.VendorAdded = append(report.VendorAdded, rel)
+		} else if hash != next.Files[rel] {
+			report.VendorChanged = append(report.VendorChanged, rel)
+		}
+	}
+	for _, rel := range sortedKeys(base.Files) {
+		if _, ok := next.Files[rel]; !ok {
+			report.VendorRemoved = append(report.VendorRemoved, rel)
+		}
+	}
+}
+
+// WriteReport stores a report in a release directory
+func WriteReport(dir string, report *Report) error {
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, stateDir, "report.json"), data, 0644)
+}
+
+// ReadReport returns the report of the upgrade that produced a release directory
+func ReadReport(dir string) (*Report, error) {
+	data, err := os.ReadFile(filepath.Join(dir, stateDir, "report.json"))
+	if err != nil {
+		return nil, err
+	}
+	var report Report
+	if err := json.Unmarshal(data, &report); err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
+
+// managed reports whether a file is operator-managed
+func managed(rel string) bool {
+	for _, p := range Paths {
+		if rel == p || strings.HasPrefix(rel, p+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// hashFiles returns the SHA-256 of every regular file in dir by slash-separated
+// relative path, skipping worlds and the vendor record
+func hashFiles(dir string) (map[string]string, error) {
+	files := make(map[string]string)
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if d.IsDir() {
+			if rel == stateDir || rel == worldsDir {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		hash, err := checksum.File(p)
+		if err != nil {
+			return err
+		}
+		files[rel] = hash
+		return nil
+	})
+	return files, err
+}
+
+func readManifest(dir string) (*manifest, error) {
+	data, err := os.ReadFile(filepath.Join(dir, stateDir, "manifest.json"))
+	if err != nil {
+		return nil, err
+	}
+	var m manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, err
+	}
+	if m.Files == nil {
+		return nil, errors.New("manifest has no files")
+	}
+	return &m, nil
+}
+
+// copyFile copies src to dst, creating dst's directory and keeping src's mode
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	stat, err := in.Stat()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, stat.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,123 @@
+package preserve
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"minecraft-server-manager/internal/testutil"
+)
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestMergeProperties(t *testing.T) {
+	base := "# Name shown to players\nserver-name=Dedicated Server\nmax-players=10\nview-distance=32\nold-key=1\n"
+	ours := "server-name=Castle\nmax-players=10\nview-distance=16\nold-key=1\nmotd=hello\n"
+	theirs := "# Name shown to players\nserver-name=Dedicated Server\nmax-players=20\n# Chunks\nview-distance=24\nnew-key=on\n"
+
+	merged, changes := MergeProperties([]byte(base), []byte(ours), []byte(theirs))
+
+	want := "# Name shown to players\nserver-name=Castle\nmax-players=20\n# Chunks\nview-distance=16\nnew-key=on\nmotd=hello\n"
+	if string(merged) != want {
+		t.Errorf("Unexpected merge:\n%s\nwant:\n%s", merged, want)
+	}
+
+	actions := make(map[string]string)
+	for _, change := range changes {
+		actions[change.Key] = change.Action
+	}
+	expected := map[string]string{
+		"server-name":   PropertyKept,
+		"max-players":   PropertyUpdated,
+		"view-distance": PropertyConflict,
+		"new-key":       PropertyNew,
+		"motd":          PropertyAdded,
+		"old-key":       PropertyDropped,
+	}
+	for key, action := range expected {
+		if actions[key] != action {
+			t.Errorf("Expected %s to be %s, got %q", key, action, actions[key])
+		}
+	}
+}
+
+func TestApplyProperties(t *testing.T) {
+	template := "# Port for IPv4\nserver-port=19132\n\nlevel-name=Bedrock level\n"
+	got := string(ApplyProperties([]byte(template), map[string]string{
+		"server-port": "20000",
+		"level-name":  "world",
+		"enable-ipv6": "false",
+	}))
+
+	want := "# Port for IPv4\nserver-port=20000\n\nlevel-name=world\nenable-ipv6=false\n"
+	if got != want {
+		t.Errorf("Unexpected result:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestMerge(t *testing.T) {
+	previous := t.TempDir()
+	testutil.WriteFile(t, filepath.Join(previous, "bedrock_server"), "v1")
+	testutil.WriteFile(t, filepath.Join(previous, "allowlist.json"), "[]")
+	testutil.WriteFile(t, filepath.Join(previous, "permissions.json"), "[]")
+	testutil.WriteFile(t, filepath.Join(previous, "config", "default", "permissions.json"), "{}")
+	testutil.WriteFile(t, filepath.Join(previous, "server.properties"), "max-players=10\n")
+	if err := Snapshot(previous, "v1"); err != nil {
+		t.Fatalf("Snapshot failed: %v", err)
+	}
+
+	// The operator edits the allowlist, adds a pack and deletes the config
+	testutil.WriteFile(t, filepath.Join(previous, "allowlist.json"), `[{"name":"Steve"}]`)
+	testutil.WriteFile(t, filepath.Join(previous, "behavior_packs", "custom", "manifest.json"), "{}")
+	os.RemoveAll(filepath.Join(previous, "config"))
+	testutil.WriteFile(t, filepath.Join(previous, "server.properties"), "max-players=5\n")
+
+	// The new release changes permissions.json and the binary
+	dir := t.TempDir()
+	testutil.WriteFile(t, filepath.Join(dir, "bedrock_server"), "v2")
+	testutil.WriteFile(t, filepath.Join(dir, "allowlist.json"), "[]")
+	testutil.WriteFile(t, filepath.Join(dir, "permissions.json"), "[ ]")
+	testutil.WriteFile(t, filepath.Join(dir, "config", "default", "permissions.json"), "{}")
+	testutil.WriteFile(t, filepath.Join(dir, "server.properties"), "# Players\nmax-players=10\n")
+	if err := Snapshot(dir, "v2"); err != nil {
+		t.Fatalf("Snapshot failed: %v", err)
+	}
+
+	report, err := Merge(previous, dir)
+	if err != nil {
+		t.Fatalf("Merge failed: %v", err)
+	}
+	if !report.Upgraded() {
+		t.Error("Expected an upgrade from v1 to v2")
+	}
+
+	if got := readFile(t, filepath.Join(dir, "allowlist.json")); !strings.Contains(got, "Steve") {
+		t.Errorf("Expected the operator's allowlist, got %s", got)
+	}
+	if got := readFile(t, filepath.Join(dir, "permissions.json")); got != "[ ]" {
+		t.Errorf("Expected the new release's permissions.json, got %s", got)
+	}
+	if got := readFile(t, filepath.Join(dir, "bedrock_server")); got != "v2" {
+		t.Errorf("Expected the new binary, got %s", got)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "behavior_packs", "custom", "manifest.json")); err != nil {
+		t.Errorf("Expected the operator's pack to be kept: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "config", "default", "permissions.json")); !os.IsNotExist(err) {
+		t.Errorf("Expected the deleted config file to stay deleted, got %v", err)
+	}
+	if got := readFile(t, filepath.Join(dir, "server.properties")); got != "# Players\nmax-players=5\n" {
+		t.Errorf("Expected the operator's value with the vendor's comments, got %q", got)
+	}
+	if len(report.VendorChanged) != 3 {
+		t.Errorf("Expected 3 changed vendor files, got %v", report.VendorChanged)
+	}
+}
@@ -0,0 +1,179 @@
+package preserve
+
+import (
+	"sort"
+	"strings"
+)
+
+// PropertyChange describes what a merge did with one server.properties key
+type PropertyChange struct {
+	Key    string `json:"key"`
+	Action string `json:"action"`
+	Value  string `json:"value"`            // Value in the merged file
+	Vendor string `json:"vendor,omitempty"` // New vendor default, when it differs
+}
+
+// Property merge actions
+const (
+	PropertyKept     = "kept"     // Operator's value kept over an unchanged default
+	PropertyAdded    = "added"    // Key the operator added
+	PropertyRemoved  = "removed"  // Key the operator removed stays removed
+	PropertyUpdated  = "updated"  // Vendor changed the default of an untouched key
+	PropertyNew      = "new"      // Key the vendor added
+	PropertyDropped  = "dropped"  // Key the vendor removed that the operator never changed
+	PropertyConflict = "conflict" // Both changed the key; the operator's value wins
+)
+
+// propertyLine is a line of a properties file; key is empty for comments and blanks
+type propertyLine struct {
+	text  string
+	key   string
+	value string
+}
+
+func parseProperties(data []byte) []propertyLine {
+	var lines []propertyLine
+	if len(data) == 0 {
+		return lines
+	}
+	for _, text := range strings.Split(strings.TrimSuffix(string(data), "\n"), "\n") {
+		text = strings.TrimSuffix(text, "\r")
+		line := propertyLine{text: text}
+		trimmed := strings.TrimSpace(text)
+		if trimmed != "" && !strings.HasPrefix(trimmed, "#") {
+			if key, value, ok := strings.Cut(trimmed, "="); ok {
+				line.key = strings.TrimSpace(key)
+				line.value = strings.TrimSpace(value)
+			}
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+func propertyValues(lines []propertyLine) map[string]string {
+	values := make(map[string]string)
+	for _, line := range lines {
+		if line.key != "" {
+			values[line.key] = line.value
+		}
+	}
+	return values
+}
+
+func formatProperties(lines []propertyLine) []byte {
+	var out strings.Builder
+	for _, line := range lines {
+		out.WriteString(line.text)
+		out.WriteString("\n")
+	}
+	return []byte(out.String())
+}
+
+// MergeProperties merges the operator's server.properties (ours) onto a new vendor
+// file (theirs), using the previous vendor file as the common base. The result keeps
+// the new vendor's layout and comments. With a nil base every value that differs
+// from the new vendor's is taken to be the operator's.
+func MergeProperties(base, ours, theirs []byte) ([]byte, []PropertyChange) {
+	theirLines := parseProperties(theirs)
+	ourLines := parseProperties(ours)
+	ourValues := propertyValues(ourLines)
+	theirValues := propertyValues(theirLines)
+	baseValues := theirValues
+	if base != nil {
+		baseValues = propertyValues(parseProperties(base))
+	}
+
+	var changes []PropertyChange
+	var merged []propertyLine
+	for _, line := range theirLines {
+		if line.key == "" {
+			merged = append(merged, line)
+			continue
+		}
+		b, inBase := baseValues[line.key]
+		o, inOurs := ourValues[line.key]
+		vendorChanged := inBase && line.value != b
+
+		switch {
+		case inOurs && (!inBase || o != b):
+			// The operator set the key, so their value stays
+			if o == line.value {
+				merged = append(merged, line)
+				continue
+			}
+			action := PropertyKept
+			if vendorChanged {
+				action = PropertyConflict
+			}
+			changes = append(changes, PropertyChange{Key: line.key, Action: action, Value: o, Vendor: line.value})
+			merged = append(merged, propertyLine{text: line.key + "=" + o, key: line.key, value: o})
+		case !inOurs && inBase && base != nil:
+			changes = append(changes, PropertyChange{Key: line.key, Action: PropertyRemoved, Vendor: line.value})
+		case !inBase:
+			changes = append(changes, PropertyChange{Key: line.key, Action: PropertyNew, Value: line.value})
+			merged = append(merged, line)
+		case vendorChanged:
+			changes = append(changes, PropertyChange{Key: line.key, Action: PropertyUpdated, Value: line.value})
+			merged = append(merged, line)
+		default:
+			merged = append(merged, line)
+		}
+	}
+
+	// Keys the new vendor file no longer has
+	for _, line := range ourLines {
+		if line.key == "" {
+			continue
+		}
+		if _, ok := theirValues[line.key]; ok {
+			continue
+		}
+		b, inBase := baseValues[line.key]
+		switch {
+		case !inBase:
+			changes = append(changes, PropertyChange{Key: line.key, Action: PropertyAdded, Value: line.value})
+			merged = append(merged, line)
+		case line.value != b:
+			changes = append(changes, PropertyChange{Key: line.key, Action: PropertyConflict, Value: line.value})
+			merged = append(merged, line)
+		default:
+			changes = append(changes, PropertyChange{Key: line.key, Action: PropertyDropped})
+		}
+	}
+
+	return formatProperties(merged), changes
+}
+
+// ApplyProperties sets values in a server.properties file, replacing existing
+// keys in place so the file's comments and order are kept. Keys the file does not
+// have are appended in sorted order.
+func ApplyProperties(data []byte, values map[string]string) []byte {
+	lines := parseProperties(data)
+	applied := make(map[string]bool)
+	for i, line := range lines {
+		value, ok := values[line.key]
+		if line.key == "" || !ok {
+			continue
+		}
+		lines[i] = propertyLine{text: line.key + "=" + value, key: line.key, value: value}
+		applied[line.key] = true
+	}
+
+	var missing []string
+	for key := range values {
+		if !applied[key] {
+			missing = append(missing, key)
+		}
+	}
+	sort.Strings(missing)
+	for _, key := range missing {
+		lines = append(lines, propertyLine{text: key + "=" + values[key], key: key, value: values[key]})
+	}
+	return formatProperties(lines)
+}
+
+// ParseProperties returns the values of a server.properties file
+func ParseProperties(data []byte) map[string]string {
+	return propertyValues(parseProperties(data))
+}
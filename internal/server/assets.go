@@ -0,0 +1,65 @@
+package server
+
+import (
+	"context"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"minecraft-server-manager/internal/archive"
+	"minecraft-server-manager/internal/config"
+	"minecraft-server-manager/internal/github"
+)
+
+// worldTemplateDir is the folder of a server's assets copied into a new world
+const worldTemplateDir = "world"
+
+// syncAssets mirrors each configured server's asset folder from the configuration
+// repository into the assets directory. A failed sync keeps the previous copy.
+func (m *Manager) syncAssets(githubClient *github.Client, repoConfig *config.RepoConfig) {
+	cfg := m.configSnapshot()
+
+	for _, serverConfig := range repoConfig.Servers {
+		// The name comes from the repository and becomes a local path
+		if !validServerName(serverConfig.Name) {
+			m.logger.Warnf("Not syncing assets for server %q: names must not contain path separators or ..", serverConfig.Name)
+			continue
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+		dir := path.Join(cfg.GitHub.AssetsPath, serverConfig.Name)
+		result, err := githubClient.SyncDir(ctx, dir, cfg.GetAssetsDir(serverConfig.Name))
+		cancel()
+		if err != nil {
+			m.logger.Warnf("Failed to sync assets for %s, using the previous copy: %v", serverConfig.Name, err)
+			continue
+		}
+		if result.Changed() {
+			m.logger.Infof("Synced assets for %s from %s: %d downloaded, %d removed, %d unchanged",
+				serverConfig.Name, dir, len(result.Downloaded), len(result.Removed), result.Unchanged)
+		}
+	}
+}
+
+// applyWorldTemplate copies the server's world template into its world when the
+// world has not been created yet. An existing world is never overwritten.
+func (m *Manager) applyWorldTemplate(serverConfig *config.MinecraftServerConfig) error {
+	template := filepath.Join(m.config.GetAssetsDir(serverConfig.Name), worldTemplateDir)
+	if _, err := os.Stat(filepath.Join(template, "level.dat")); err != nil {
+		return nil
+	}
+
+	worldDir := filepath.Join(m.config.GetWorldsDir(serverConfig.Name), serverConfig.WorldName)
+	if _, err := os.Stat(filepath.Join(worldDir, "level.dat")); err == nil {
+		return nil
+	}
+
+	m.logger.Infof("Creating world %s for %s from its template", serverConfig.WorldName, serverConfig.Name)
+	return archive.CopyTree(template, worldDir)
+}
+
+// validServerName reports whether a server name is safe to use as a directory name
+func validServerName(name string) bool {
+	return name != "" && name != "." && !strings.Contains(name, "..") && !strings.ContainsAny(name, `/\`)
+}
@@ -0,0 +1,130 @@
+package server
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// auditFile is where each server's console commands are recorded, under its logs
+const auditFile = "console-audit.jsonl"
+
+// ConsoleSession is one viewer attached to a server's console. Any number of
+// sessions share the process's output and take turns writing to its stdin.
+type ConsoleSession struct {
+	Recent []string      // Output from before the session started
+	Lines  <-chan string // Output from then on, closed when the server exits
+
+	manager     *Manager
+	server      *MinecraftServer
+	name, by    string
+	unsubscribe func()
+	once        sync.Once
+}
+
+// AttachConsole attaches a viewer to a running server's console
+func (m *Manager) AttachConsole(name, by string) (*ConsoleSession, error) {
+	server, err := m.runningServer(name)
+	if err != nil {
+		return nil, err
+	}
+	recent, lines, unsubscribe := server.output.follow()
+	session := &ConsoleSession{
+		Recent:      recent,
+		Lines:       lines,
+		manager:     m,
+		server:      server,
+		name:        name,
+		by:          by,
+		unsubscribe: unsubscribe,
+	}
+
+	m.mu.Lock()
+	if server.viewers == nil {
+		server.viewers = make(map[*ConsoleSession]struct{})
+	}
+	server.viewers[session] = struct{}{}
+	count := len(server.viewers)
+	m.mu.Unlock()
+
+	m.logger.Infof("Console of %s attached by %s (%d watching)", name, by, count)
+	return session, nil
+}
+
+// Send writes a command to the server's console on behalf of the viewer
+func (s *ConsoleSession) Send(command string) error {
+	return s.manager.RunCommand(s.name, command, s.by)
+}
+
+// Close detaches the viewer
+func (s *ConsoleSession) Close() {
+	s.once.Do(func() {
+		s.unsubscribe()
+
+		s.manager.mu.Lock()
+		delete(s.server.viewers, s)
+		count := len(s.server.viewers)
+		s.manager.mu.Unlock()
+
+		s.manager.logger.Infof("Console of %s detached by %s (%d watching)", s.name, s.by, count)
+	})
+}
+
+// consoleViewers returns who is attached to a server's console. Must be called
+// with m.mu held.
+func (server *MinecraftServer) consoleViewers() []string {
+	seen := make(map[string]bool)
+	var viewers []string
+	for session := range server.viewers {
+		if !seen[session.by] {
+			seen[session.by] = true
+			viewers = append(viewers, session.by)
+		}
+	}
+	sort.Strings(viewers)
+	return viewers
+}
+
+// auditEntry is a line of a server's console audit trail
+type auditEntry struct {
+	Time    time.Time `json:"time"`
+	By      string    `json:"by"`
+	Command string    `json:"command"`
+	Error   string    `json:"error,omitempty"`
+}
+
+// auditCommand appends a console command to the server's audit trail. name must
+// be a configured server, since it becomes part of the path.
+func (m *Manager) auditCommand(name, command, by string, sendErr error) {
+	entry := auditEntry{Time: time.Now(), By: by, Command: command}
+	if sendErr != nil {
+		entry.Error = sendErr.Error()
+	}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+
+	cfg := m.configSnapshot()
+	path := filepath.Join(cfg.GetServerDir(name), "logs", auditFile)
+
+	m.auditMu.Lock()
+	defer m.auditMu.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		m.logger.Warnf("Failed to record console command by %s on %s: %v", by, name, err)
+		return
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0640)
+	if err != nil {
+		m.logger.Warnf("Failed to record console command by %s on %s: %v", by, name, err)
+		return
+	}
+	defer file.Close()
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		m.logger.Warnf("Failed to record console command by %s on %s: %v", by, name, err)
+	}
+}
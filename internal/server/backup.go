@@ -0,0 +1,374 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"minecraft-server-manager/internal/backup"
+	"minecraft-server-manager/internal/config"
+)
+
+var (
+	// ErrUnknownServer is returned for a server that is neither running nor configured
+	ErrUnknownServer = errors.New("unknown server")
+
+	// ErrNoRemote is returned when a remote backup is requested but none is configured
+	ErrNoRemote = errors.New("no backup remote configured")
+)
+
+const (
+	// saveQueryTimeout bounds how long a hot backup waits for the world files
+	saveQueryTimeout = 2 * time.Minute
+
+	saveReadyMessage = "Data saved. Files are now ready to be copied."
+)
+
+// Backup backs up a server's world: a hot copy using save hold/query/resume when
+// the server is running, a cold copy of the world directory otherwise
+func (m *Manager) Backup(name string) (*backup.Info, error) {
+	m.mu.RLock()
+	cfg := m.config
+	server := m.servers[name]
+	if server != nil && !server.isRunning() {
+		server = nil
+	}
+	serverConfig := m.serverConfigLocked(name)
+	m.mu.RUnlock()
+
+	if serverConfig == nil {
+		return nil, ErrUnknownServer
+	}
+	return m.backupServer(cfg, serverConfig, server)
+}
+
+// serverConfigLocked returns a server's configuration, preferring the running
+// one. Must be called with m.mu held.
+func (m *Manager) serverConfigLocked(name string) *config.MinecraftServerConfig {
+	if server, exists := m.servers[name]; exists {
+		return server.Config
+	}
+	if m.lastConfig != nil {
+		for i := range m.lastConfig.Servers {
+			if m.lastConfig.Servers[i].Name == name {
+				serverConfig := m.lastConfig.Servers[i]
+				return &serverConfig
+			}
+		}
+	}
+	return nil
+}
+
+// backupServer makes a hot backup when server is given and a cold one otherwise.
+// It does not take m.mu, but it can take minutes, so call it without holding m.mu.
+func (m *Manager) backupServer(cfg *config.Config, serverConfig *config.MinecraftServerConfig, server *MinecraftServer) (*backup.Info, error) {
+	// One backup at a time keeps save hold sessions and disk load simple
+	m.backupMu.Lock()
+	defer m.backupMu.Unlock()
+
+	store := backup.NewStore(cfg.Server.BackupDir)
+	worldsDir := cfg.GetWorldsDir(serverConfig.Name)
+
+	var info *backup.Info
+	var err error
+	if server != nil {
+		err = m.withSaveHold(server, func(files []backup.File) error {
+			info, err = store.Create(serverConfig.Name, serverConfig.WorldName, worldsDir, "hot", files)
+			return err
+		})
+	} else {
+		var files []backup.File
+		files, err = backup.WalkFiles(worldsDir, serverConfig.WorldName)
+		if err == nil {
+			info, err = store.Create(serverConfig.Name, serverConfig.WorldName, worldsDir, "cold", files)
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("backup of %s failed: %w", serverConfig.Name, err)
+	}
+
+	m.logger.Infof("Backed up %s (%s, %d files, %d bytes, %d new): %s", serverConfig.Name, info.Method, info.Files, info.Size, info.Stored, info.ID)
+
+	expired, err := store.Prune(serverConfig.Name, resolveRetention(cfg, serverConfig))
+	if err != nil {
+		m.logger.Warnf("Failed to apply backup retention for %s: %v", serverConfig.Name, err)
+	}
+	for _, old := range expired {
+		m.logger.Infof("Deleted expired backup %s of %s", old.ID, serverConfig.Name)
+	}
+
+	// Chunks are shared between backups, so only those no backup uses any more are freed
+	if len(expired) > 0 {
+		freed, err := store.GC()
+		if err != nil {
+			m.logger.Warnf("Failed to collect unused backup chunks: %v", err)
+		} else if freed.Chunks > 0 {
+			m.logger.Infof("Freed %d unused backup chunks (%d bytes)", freed.Chunks, freed.Bytes)
+		}
+	}
+
+	m.replicateBackup(cfg, info)
+	return info, nil
+}
+
+// newRemote returns the configured backup remote, or nil when replication is off
+func newRemote(cfg *config.Config) (backup.Remote, error) {
+	if cfg.Server.BackupRemote.Endpoint == "" {
+		return nil, nil
+	}
+	return backup.NewS3Remote(cfg.Server.BackupRemote)
+}
+
+// replicateBackup copies a backup to the remote in the background, so a slow
+// upload never holds up a restart
+func (m *Manager) replicateBackup(cfg *config.Config, info *backup.Info) {
+	remote, err := newRemote(cfg)
+	if err != nil {
+		m.logger.Errorf("Failed to replicate backup %s of %s: %v", info.ID, info.Server, err)
+		return
+	}
+	if remote == nil {
+		return
+	}
+
+	go func() {
+		m.replicateMu.Lock()
+		defer m.replicateMu.Unlock()
+
+		if err := backup.NewStore(cfg.Server.BackupDir).Replicate(context.Background(), remote, info); err != nil {
+			m.logger.Errorf("Failed to replicate backup %s of %s: %v", info.ID, info.Server, err)
+			return
+		}
+		m.logger.Infof("Replicated backup %s of %s to %s", info.ID, info.Server, cfg.Server.BackupRemote.Endpoint)
+	}()
+}
+
+// resolveRetention returns the server's own retention policy, or the global one
+func resolveRetention(cfg *config.Config, serverConfig *config.MinecraftServerConfig) config.RetentionConfig {
+	if !serverConfig.BackupRetention.IsZero() {
+		return serverConfig.BackupRetention
+	}
+	return cfg.Server.BackupRetention
+}
+
+// ListBackups returns a server's backups, newest first, from local disk or the remote
+func (m *Manager) ListBackups(name string, fromRemote bool) ([]backup.Info, error) {
+	m.mu.RLock()
+	cfg := m.config
+	serverConfig := m.serverConfigLocked(name)
+	m.mu.RUnlock()
+
+	if serverConfig == nil {
+		return nil, ErrUnknownServer
+	}
+
+	store := backup.NewStore(cfg.Server.BackupDir)
+	if !fromRemote {
+		return store.List(name)
+	}
+
+	remote, err := newRemote(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if remote == nil {
+		return nil, ErrNoRemote
+	}
+	return store.ListRemote(context.Background(), remote, name)
+}
+
+// fetchBackup downloads a replicated backup into the local store
+func (m *Manager) fetchBackup(name, id string) error {
+	m.mu.RLock()
+	cfg := m.config
+	serverConfig := m.serverConfigLocked(name)
+	m.mu.RUnlock()
+
+	if serverConfig == nil {
+		return ErrUnknownServer
+	}
+	remote, err := newRemote(cfg)
+	if err != nil {
+		return err
+	}
+	if remote == nil {
+		return ErrNoRemote
+	}
+
+	// Downloaded chunks are unreferenced until the manifest lands, so keep GC out
+	m.backupMu.Lock()
+	defer m.backupMu.Unlock()
+
+	if _, err := backup.NewStore(cfg.Server.BackupDir).Fetch(context.Background(), remote, name, id); err != nil {
+		return err
+	}
+	m.logger.Infof("Fetched backup %s of %s from %s", id, name, cfg.Server.BackupRemote.Endpoint)
+	return nil
+}
+
+// RestoreResult describes a completed restore
+type RestoreResult struct {
+	Backup        *backup.Info `json:"backup"`
+	PreviousWorld string       `json:"previous_world,omitempty"` // Where the replaced world was moved
+	Restarted     bool         `json:"restarted"`
+}
+
+// RestoreBackup replaces a server's world with a backup, downloading it from the
+// remote first if asked to. A running server is stopped first and started again
+// afterwards; the replaced world is kept.
+func (m *Manager) RestoreBackup(name, id string, fromRemote bool) (*RestoreResult, error) {
+	// Download before stopping anything, so the server stays up while it transfers
+	if fromRemote {
+		if err := m.fetchBackup(name, id); err != nil {
+			return nil, err
+		}
+	}
+
+	m.mu.Lock()
+	serverConfig := m.serverConfigLocked(name)
+	if serverConfig == nil {
+		m.mu.Unlock()
+		return nil, ErrUnknownServer
+	}
+	cfg := m.config
+	store := backup.NewStore(cfg.Server.BackupDir)
+	info, err := store.Get(name, id)
+	if err != nil {
+		m.mu.Unlock()
+		return nil, err
+	}
+
+	// Keep backups from reading the world while it is replaced
+	m.backupMu.Lock()
+
+	server, exists := m.servers[name]
+	running := exists && server.isRunning()
+	if running {
+		m.logger.Infof("Stopping %s to restore backup %s", name, id)
+	}
+	// A crashed or stopped server's process is gone, but its entry is cleared too
+	m.stopServer(name)
+	m.restoring[name] = true
+	m.mu.Unlock()
+
+	// Extract without the manager lock so status stays available meanwhile
+	previous, err := store.Restore(info, cfg.GetWorldsDir(name), serverConfig.WorldName)
+	m.backupMu.Unlock()
+	if err != nil {
+		err = fmt.Errorf("restore of %s failed: %w", name, err)
+	} else if previous != "" {
+		m.logger.Infof("Restored %s from backup %s, previous world kept at %s", name, id, previous)
+	} else {
+		m.logger.Infof("Restored %s from backup %s", name, id)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.restoring, name)
+
+	// Bring the server back either way; a failed restore leaves the original world in place
+	if running {
+		m.startServer(serverConfig)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &RestoreResult{
+		Backup:        info,
+		PreviousWorld: previous,
+		Restarted:     running,
+	}, nil
+}
+
+// withSaveHold pauses saving on a running server, waits until the world files are
+// ready to copy and passes them to fn before resuming
+func (m *Manager) withSaveHold(server *MinecraftServer, fn func(files []backup.File) error) error {
+	lines, unsubscribe := server.output.subscribe()
+	defer unsubscribe()
+
+	if err := server.sendCommand("save hold"); err != nil {
+		return fmt.Errorf("failed to hold saves: %w", err)
+	}
+	defer func() {
+		if err := server.sendCommand("save resume"); err != nil {
+			m.logger.Warnf("Failed to resume saves on %s: %v", server.Config.Name, err)
+		}
+	}()
+
+	files, err := waitForSaveQuery(server, lines, saveQueryTimeout)
+	if err != nil {
+		return err
+	}
+	return fn(files)
+}
+
+// waitForSaveQuery polls "save query" until the server lists the files to copy.
+// Other output, such as players joining, may come between the ready message and
+// the list, so lines are skipped until one parses as a list.
+func waitForSaveQuery(server *MinecraftServer, lines <-chan string, timeout time.Duration) ([]backup.File, error) {
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+	poll := time.NewTicker(time.Second)
+	defer poll.Stop()
+
+	if err := server.sendCommand("save query"); err != nil {
+		return nil, err
+	}
+
+	ready := false
+	for {
+		select {
+		case line, ok := <-lines:
+			if !ok {
+				return nil, fmt.Errorf("server exited during backup")
+			}
+			if ready {
+				if files, err := parseSaveQuery(line); err == nil {
+					return files, nil
+				}
+				continue
+			}
+			if i := strings.Index(line, saveReadyMessage); i >= 0 {
+				// Some versions print the file list on the same line
+				if rest := strings.TrimSpace(line[i+len(saveReadyMessage):]); rest != "" {
+					return parseSaveQuery(rest)
+				}
+				ready = true
+			}
+		case <-poll.C:
+			if !ready {
+				if err := server.sendCommand("save query"); err != nil {
+					return nil, err
+				}
+			}
+		case <-deadline.C:
+			return nil, fmt.Errorf("timed out waiting for save query")
+		}
+	}
+}
+
+// parseSaveQuery parses a file list such as "world/db/000005.ldb:1234, world/level.dat:2048"
+func parseSaveQuery(line string) ([]backup.File, error) {
+	var files []backup.File
+	for _, entry := range strings.Split(strings.TrimSpace(line), ", ") {
+		i := strings.LastIndex(entry, ":")
+		if i <= 0 {
+			return nil, fmt.Errorf("unexpected save query entry %q", entry)
+		}
+		size, err := strconv.ParseInt(strings.TrimSpace(entry[i+1:]), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("unexpected save query entry %q", entry)
+		}
+		files = append(files, backup.File{Path: strings.TrimSpace(entry[:i]), Size: size})
+	}
+	return files, nil
+}
+
+// isRunning reports whether the process is up and able to take console commands
+func (server *MinecraftServer) isRunning() bool {
+	return server.Status == "starting" || server.Status == "running"
+}
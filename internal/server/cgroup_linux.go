@@ -0,0 +1,248 @@
+//go:build linux
+
+package server
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"golang.org/x/sys/unix"
+)
+
+const cgroupRoot = "/sys/fs/cgroup"
+
+// cgroupController creates one cgroup v2 child group per server below a
+// delegated parent group
+type cgroupController struct {
+	parent string // Absolute path of the parent group
+	logger *logrus.Logger
+}
+
+// serverCgroup is the child group of a single server
+type serverCgroup struct {
+	path string
+}
+
+// newCgroupController prepares parent (a path below /sys/fs/cgroup, defaulting to the
+// manager's own group) for per-server child groups
+func newCgroupController(parent string, logger *logrus.Logger) (*cgroupController, error) {
+	var fs unix.Statfs_t
+	if err := unix.Statfs(cgroupRoot, &fs); err != nil || fs.Type != unix.CGROUP2_SUPER_MAGIC {
+		return nil, fmt.Errorf("cgroup v2 is not mounted at %s", cgroupRoot)
+	}
+
+	if parent == "" {
+		own, err := ownCgroup()
+		if err != nil {
+			return nil, err
+		}
+		parent = own
+	}
+	parentPath := filepath.Join(cgroupRoot, parent)
+
+	// The parent must offer the controllers we need
+	available, err := os.ReadFile(filepath.Join(parentPath, "cgroup.controllers"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read controllers of %s: %w", parentPath, err)
+	}
+	for _, controller := range []string{"memory", "cpu", "pids"} {
+		if !containsField(string(available), controller) {
+			return nil, fmt.Errorf("controller %s is not delegated to %s", controller, parentPath)
+		}
+	}
+
+	c := &cgroupController{parent: parentPath, logger: logger}
+	if err := c.enableControllers(); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// enableControllers turns on the controllers for child groups. A group that contains
+// processes cannot do that, so the manager first moves itself into a leaf group.
+func (c *cgroupController) enableControllers() error {
+	control := filepath.Join(c.parent, "cgroup.subtree_control")
+	err := os.WriteFile(control, []byte("+memory +cpu +pids"), 0644)
+	if err == nil {
+		return nil
+	}
+	if !errors.Is(err, syscall.EBUSY) {
+		return fmt.Errorf("failed to enable controllers in %s: %w", c.parent, err)
+	}
+
+	leaf := filepath.Join(c.parent, "manager")
+	if err := os.MkdirAll(leaf, 0755); err != nil {
+		return fmt.Errorf("failed to create manager cgroup: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(leaf, "cgroup.procs"), []byte(strconv.Itoa(os.Getpid())), 0644); err != nil {
+		return fmt.Errorf("failed to move manager into %s: %w", leaf, err)
+	}
+
+	if err := os.WriteFile(control, []byte("+memory +cpu +pids"), 0644); err != nil {
+		return fmt.Errorf("failed to enable controllers in %s: %w", c.parent, err)
+	}
+	return nil
+}
+
+// create makes a fresh group for a server and applies its limits
+func (c *cgroupController) create(serverName string, limits resourceLimits) (*serverCgroup, error) {
+	cg := &serverCgroup{path: filepath.Join(c.parent, cgroupName(serverName))}
+
+	// A group left behind by a previous run may still hold an orphaned server
+	if _, err := os.Stat(cg.path); err == nil {
+		c.logger.Warnf("Removing stale cgroup %s", cg.path)
+		if err := cg.remove(); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := os.Mkdir(cg.path, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create cgroup %s: %w", cg.path, err)
+	}
+
+	settings := map[string]string{
+		"memory.max": limitString(limits.MemoryBytes),
+		"cpu.max":    fmt.Sprintf("%s %d", limitString(limits.CPUQuota), cpuPeriod),
+		"pids.max":   limitString(limits.Pids),
+	}
+	for file, value := range settings {
+		if err := os.WriteFile(filepath.Join(cg.path, file), []byte(value), 0644); err != nil {
+			cg.remove()
+			return nil, fmt.Errorf("failed to set %s: %w", file, err)
+		}
+	}
+
+	return cg, nil
+}
+
+// attach places a process that is about to start into the group. The returned
+// function must be called once the process has started.
+func (cg *serverCgroup) attach(attr *syscall.SysProcAttr) (func(), error) {
+	fd, err := unix.Open(cg.path, unix.O_PATH|unix.O_DIRECTORY|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open cgroup %s: %w", cg.path, err)
+	}
+
+	attr.UseCgroupFD = true
+	attr.CgroupFD = fd
+	return func() { unix.Close(fd) }, nil
+}
+
+// usage reads the group's current resource consumption
+func (cg *serverCgroup) usage(limits resourceLimits) *ResourceUsage {
+	usage := &ResourceUsage{
+		Enforcement:      "cgroup",
+		MemoryLimitBytes: limits.MemoryBytes,
+		PidsLimit:        limits.Pids,
+	}
+	if limits.CPUQuota > 0 {
+		usage.CPULimit = float64(limits.CPUQuota) / cpuPeriod
+	}
+
+	usage.MemoryBytes = readCgroupInt(cg.path, "memory.current")
+	usage.MemoryPeakBytes = readCgroupInt(cg.path, "memory.peak")
+	usage.Pids = readCgroupInt(cg.path, "pids.current")
+	usage.OOMKills = readCgroupKey(cg.path, "memory.events", "oom_kill")
+	usage.CPUSeconds = float64(readCgroupKey(cg.path, "cpu.stat", "usage_usec")) / 1e6
+
+	return usage
+}
+
+// remove kills anything left in the group and deletes it
+func (cg *serverCgroup) remove() error {
+	if err := os.Remove(cg.path); err == nil || os.IsNotExist(err) {
+		return nil
+	}
+
+	// cgroup.kill needs Linux 5.14; older kernels leave the processes to the caller
+	os.WriteFile(filepath.Join(cg.path, "cgroup.kill"), []byte("1"), 0644)
+
+	var err error
+	for i := 0; i < 20; i++ {
+		if err = os.Remove(cg.path); err == nil || os.IsNotExist(err) {
+			return nil
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	return fmt.Errorf("failed to remove cgroup %s: %w", cg.path, err)
+}
+
+// processUsage reads the resident memory of a process without cgroups
+func processUsage(pid int, limits resourceLimits, enforcement string) *ResourceUsage {
+	usage := &ResourceUsage{Enforcement: enforcement, MemoryLimitBytes: limits.MemoryBytes}
+
+	file, err := os.Open(fmt.Sprintf("/proc/%d/status", pid))
+	if err != nil {
+		return usage
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) >= 2 && fields[0] == "VmRSS:" {
+			kb, _ := strconv.ParseInt(fields[1], 10, 64)
+			usage.MemoryBytes = kb * 1024
+		}
+	}
+	return usage
+}
+
+// ownCgroup returns the manager's cgroup v2 path from /proc/self/cgroup
+func ownCgroup() (string, error) {
+	data, err := os.ReadFile("/proc/self/cgroup")
+	if err != nil {
+		return "", fmt.Errorf("failed to read /proc/self/cgroup: %w", err)
+	}
+
+	for _, line := range strings.Split(string(data), "\n") {
+		if path, ok := strings.CutPrefix(line, "0::"); ok {
+			// Processes in the manager leaf belong to its parent
+			return strings.TrimSuffix(path, "/manager"), nil
+		}
+	}
+	return "", fmt.Errorf("no cgroup v2 entry in /proc/self/cgroup")
+}
+
+func readCgroupInt(dir, file string) int64 {
+	data, err := os.ReadFile(filepath.Join(dir, file))
+	if err != nil {
+		return 0
+	}
+	n, _ := strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+	return n
+}
+
+// readCgroupKey reads a "key value" line from a flat-keyed file such as memory.events
+func readCgroupKey(dir, file, key string) int64 {
+	data, err := os.ReadFile(filepath.Join(dir, file))
+	if err != nil {
+		return 0
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 2 && fields[0] == key {
+			n, _ := strconv.ParseInt(fields[1], 10, 64)
+			return n
+		}
+	}
+	return 0
+}
+
+func containsField(s, field string) bool {
+	for _, f := range strings.Fields(s) {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
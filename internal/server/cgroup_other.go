@@ -0,0 +1,40 @@
+//go:build !linux
+
+package server
+
+import (
+	"fmt"
+	"syscall"
+
+	"github.com/sirupsen/logrus"
+)
+
+type cgroupController struct {
+	parent string
+}
+
+type serverCgroup struct{}
+
+func newCgroupController(parent string, logger *logrus.Logger) (*cgroupController, error) {
+	return nil, fmt.Errorf("cgroups are only supported on Linux")
+}
+
+func (c *cgroupController) create(serverName string, limits resourceLimits) (*serverCgroup, error) {
+	return nil, fmt.Errorf("cgroups are only supported on Linux")
+}
+
+func (cg *serverCgroup) attach(attr *syscall.SysProcAttr) (func(), error) {
+	return nil, fmt.Errorf("cgroups are only supported on Linux")
+}
+
+func (cg *serverCgroup) usage(limits resourceLimits) *ResourceUsage {
+	return &ResourceUsage{Enforcement: "none"}
+}
+
+func (cg *serverCgroup) remove() error {
+	return nil
+}
+
+func processUsage(pid int, limits resourceLimits, enforcement string) *ResourceUsage {
+	return &ResourceUsage{Enforcement: enforcement, MemoryLimitBytes: limits.MemoryBytes}
+}
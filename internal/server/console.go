@@ -0,0 +1,220 @@
+package server
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+)
+
+// ErrNotRunning is returned for console access to a server that is not running
+var ErrNotRunning = errors.New("server is not running")
+
+// SendCommand writes a command line to a running server's console
+func (m *Manager) SendCommand(name, command string) error {
+	server, err := m.runningServer(name)
+	if err != nil {
+		return err
+	}
+	return server.sendCommand(command)
+}
+
+// RunCommand sends a command typed by a person. It is recorded in the audit trail
+// and shown to everyone watching the console, tagged with who sent it.
+func (m *Manager) RunCommand(name, command, by string) error {
+	// The audit trail lives in the server's directory, so only known names may reach it
+	m.mu.RLock()
+	known := m.serverConfigLocked(name) != nil
+	m.mu.RUnlock()
+	if !known {
+		return ErrUnknownServer
+	}
+
+	command = strings.TrimSpace(command)
+	server, err := m.runningServer(name)
+	if err == nil {
+		err = server.sendCommand(command)
+	}
+	m.auditCommand(name, command, by, err)
+	if err != nil {
+		return err
+	}
+	server.output.annotate(fmt.Sprintf("[%s] > %s", by, command))
+	m.logger.Infof("Console command on %s by %s: %s", name, by, command)
+	return nil
+}
+
+// Logs returns the most recent console output of a server
+func (m *Manager) Logs(name string) ([]string, error) {
+	server, err := m.runningServer(name)
+	if err != nil {
+		return nil, err
+	}
+	return server.output.recent(), nil
+}
+
+// FollowLogs returns the most recent console output of a server and a channel of
+// the lines that follow it, closed when the server exits. stop ends the stream.
+func (m *Manager) FollowLogs(name string) (recent []string, lines <-chan string, stop func(), err error) {
+	server, err := m.runningServer(name)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	recent, lines, stop = server.output.follow()
+	return recent, lines, stop, nil
+}
+
+// runningServer returns the process of a server, running or crashed
+func (m *Manager) runningServer(name string) (*MinecraftServer, error) {
+	m.mu.RLock()
+	server, exists := m.servers[name]
+	m.mu.RUnlock()
+
+	if !exists {
+		return nil, fmt.Errorf("%w: %s", ErrNotRunning, name)
+	}
+	return server, nil
+}
+
+func (server *MinecraftServer) sendCommand(command string) error {
+	command = strings.TrimSpace(command)
+	if command == "" {
+		return fmt.Errorf("empty command")
+	}
+	if strings.ContainsAny(command, "\r\n") {
+		return fmt.Errorf("command must be a single line")
+	}
+
+	server.stdinMu.Lock()
+	defer server.stdinMu.Unlock()
+
+	if server.stdin == nil {
+		return fmt.Errorf("server %s has no console", server.Config.Name)
+	}
+	if _, err := server.stdin.Write([]byte(command + "\n")); err != nil {
+		return fmt.Errorf("failed to write to console: %w", err)
+	}
+	return nil
+}
+
+// consoleOutput collects a server's output line by line. It keeps the most recent
+// lines and fans new ones out to subscribers without ever blocking the process.
+type consoleOutput struct {
+	mu          sync.Mutex
+	lines       []string
+	maxLines    int
+	partial     []byte
+	subscribers map[chan string]struct{}
+	passthrough io.Writer
+	closed      bool
+}
+
+func newConsoleOutput(maxLines int, passthrough io.Writer) *consoleOutput {
+	return &consoleOutput{
+		maxLines:    maxLines,
+		subscribers: make(map[chan string]struct{}),
+		passthrough: passthrough,
+	}
+}
+
+func (c *consoleOutput) Write(p []byte) (int, error) {
+	if c.passthrough != nil {
+		c.passthrough.Write(p)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.partial = append(c.partial, p...)
+	for {
+		i := bytes.IndexByte(c.partial, '\n')
+		if i < 0 {
+			break
+		}
+		line := strings.TrimRight(string(c.partial[:i]), "\r")
+		c.partial = c.partial[i+1:]
+		c.addLocked(line)
+	}
+
+	return len(p), nil
+}
+
+// addLocked keeps a line and sends it to every subscriber. Must be called with c.mu held.
+func (c *consoleOutput) addLocked(line string) {
+	c.lines = append(c.lines, line)
+	if len(c.lines) > c.maxLines {
+		c.lines = c.lines[len(c.lines)-c.maxLines:]
+	}
+
+	for ch := range c.subscribers {
+		select {
+		case ch <- line:
+		default:
+			// Slow subscribers miss lines rather than stalling the server
+		}
+	}
+}
+
+// subscribe returns a channel of new output lines and a function to stop receiving them
+func (c *consoleOutput) subscribe() (<-chan string, func()) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.subscribeLocked()
+}
+
+// follow returns the recent lines and a subscription to new ones, taken together
+// so no line is missed or repeated between them
+func (c *consoleOutput) follow() ([]string, <-chan string, func()) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	recent := append([]string(nil), c.lines...)
+	ch, unsubscribe := c.subscribeLocked()
+	return recent, ch, unsubscribe
+}
+
+func (c *consoleOutput) subscribeLocked() (<-chan string, func()) {
+	ch := make(chan string, 256)
+	if c.closed {
+		close(ch)
+	} else {
+		c.subscribers[ch] = struct{}{}
+	}
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			c.mu.Lock()
+			delete(c.subscribers, ch)
+			c.mu.Unlock()
+		})
+	}
+}
+
+// annotate adds a line of the manager's own to the output seen by subscribers,
+// such as who sent a command
+func (c *consoleOutput) annotate(line string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.addLocked(line)
+}
+
+// recent returns a copy of the most recent output lines
+func (c *consoleOutput) recent() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]string(nil), c.lines...)
+}
+
+// close ends every subscription once the process has exited
+func (c *consoleOutput) close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.closed = true
+	for ch := range c.subscribers {
+		close(ch)
+		delete(c.subscribers, ch)
+	}
+}
@@ -0,0 +1,127 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+
+	"minecraft-server-manager/internal/checksum"
+	"minecraft-server-manager/internal/github"
+	"minecraft-server-manager/internal/layers"
+)
+
+// recombinedArchive is the archive reassembled from the layers on each start
+const recombinedArchive = "bedrock-server-recombined.zip"
+
+// repoSource fetches layers from a directory of the configuration repository
+type repoSource struct {
+	client *github.Client
+	dir    string
+}
+
+func (s *repoSource) Open(ctx context.Context, chunk layers.Chunk, offset int64) (io.ReadCloser, int64, error) {
+	// The repository cannot serve ranges, so a partial chunk starts over
+	body, err := s.client.DownloadFile(ctx, path.Join(s.dir, chunk.Name))
+	return body, 0, err
+}
+
+// Manifest downloads the manifest of an archive's layers
+func (s *repoSource) Manifest(ctx context.Context, file string) (*layers.Manifest, error) {
+	body, err := s.client.DownloadFile(ctx, path.Join(s.dir, filepath.Base(layers.ManifestPath("", file))))
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+	return layers.ParseManifest(data)
+}
+
+func (s *repoSource) String() string {
+	return "configuration repository " + s.dir
+}
+
+// remoteSource is where layers come from when the original archive is absent
+type remoteSource interface {
+	layers.Source
+	Manifest(ctx context.Context, file string) (*layers.Manifest, error)
+}
+
+// prepareLayers returns the manifest describing the Bedrock archive's layers and
+// the source missing layers are fetched from. The archive is split again whenever
+// it or the configured chunk size changed. Without the archive, the manifest is
+// read from disk or fetched from the mirror or configuration repository. A nil
+// manifest means there is neither an archive nor layers.
+func (m *Manager) prepareLayers(ctx context.Context, githubClient *github.Client, dir, archivePath string) (*layers.Manifest, layers.Source, error) {
+	settings := m.configSnapshot().Server.Layers
+	chunkSize := int64(settings.ChunkSizeMB) << 20
+	manifestPath := layers.ManifestPath(dir, archivePath)
+
+	if _, err := os.Stat(archivePath); err == nil {
+		archiveHash, err := checksum.File(archivePath)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to hash Bedrock archive: %w", err)
+		}
+		manifest, err := layers.ReadManifest(manifestPath)
+		if err == nil && manifest.SHA256 == archiveHash && manifest.ChunkSize == chunkSize {
+			return manifest, layers.NewFileSource(archivePath), nil
+		}
+
+		m.logger.Infof("Splitting Bedrock server archive into %d MB layers...", settings.ChunkSizeMB)
+		manifest, err = layers.Split(archivePath, dir, chunkSize)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to split archive: %w", err)
+		}
+		m.logger.Infof("Split %s into %d layers", manifest.File, len(manifest.Chunks))
+		return manifest, layers.NewFileSource(archivePath), nil
+	}
+
+	var source remoteSource
+	switch {
+	case settings.Mirror != "":
+		source = layers.NewHTTPSource(settings.Mirror)
+	case settings.RepoPath != "" && githubClient != nil:
+		source = &repoSource{client: githubClient, dir: settings.RepoPath}
+	}
+
+	manifest, err := layers.ReadManifest(manifestPath)
+	if err == nil {
+		return manifest, source, nil
+	}
+	if !os.IsNotExist(err) {
+		m.logger.Warnf("Ignoring layers manifest %s: %v", manifestPath, err)
+	}
+	if source == nil {
+		return nil, nil, nil
+	}
+
+	m.logger.Infof("Fetching layers manifest from %s", source)
+	manifest, err = source.Manifest(ctx, filepath.Base(archivePath))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to fetch layers manifest: %w", err)
+	}
+	if err := layers.WriteManifest(dir, manifest); err != nil {
+		return nil, nil, fmt.Errorf("failed to save layers manifest: %w", err)
+	}
+	return manifest, source, nil
+}
+
+// recombineLayers reassembles the archive from its layers, fetching any that are
+// missing or corrupt from source
+func (m *Manager) recombineLayers(ctx context.Context, dir string, manifest *layers.Manifest, source layers.Source) error {
+	m.logger.Infof("Recombining %d layers...", len(manifest.Chunks))
+
+	out := filepath.Join(dir, recombinedArchive)
+	if err := layers.Assemble(ctx, dir, manifest, out, source, m.logger); err != nil {
+		return err
+	}
+
+	m.logger.Infof("Layers recombined into %s (SHA-256 %s)", out, manifest.SHA256)
+	return nil
+}
@@ -0,0 +1,264 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"minecraft-server-manager/internal/config"
+)
+
+// Override states
+const (
+	OverrideStarted = "started"
+	OverrideStopped = "stopped"
+)
+
+// Override is a manual start or stop. The configuration poll, open hours and
+// single-server selection respect it until it is cleared.
+type Override struct {
+	State string    `json:"state"`
+	By    string    `json:"by,omitempty"`
+	Time  time.Time `json:"time"`
+}
+
+// errStartFailed is reported when startServer gave up; the reason is in the log
+var errStartFailed = errors.New("server failed to start, see the manager log")
+
+// StartServer starts a configured server in the background and returns the
+// operation tracking it. In single-server mode any other running server is
+// stopped first.
+func (m *Manager) StartServer(name, by string) (*Operation, error) {
+	return m.runLifecycle(name, "start", by, func(serverConfig *config.MinecraftServerConfig) error {
+		m.setOverrideLocked(name, OverrideStarted, by)
+		if server, exists := m.servers[name]; exists && server.isRunning() {
+			return nil
+		}
+		return m.startOnlyLocked(serverConfig)
+	})
+}
+
+// StopServer stops a server in the background and keeps it stopped until the
+// override is cleared
+func (m *Manager) StopServer(name, by string) (*Operation, error) {
+	return m.runLifecycle(name, "stop", by, func(serverConfig *config.MinecraftServerConfig) error {
+		m.setOverrideLocked(name, OverrideStopped, by)
+		m.stopServer(name)
+		return nil
+	})
+}
+
+// RestartServer stops and starts a server in the background
+func (m *Manager) RestartServer(name, by string) (*Operation, error) {
+	return m.runLifecycle(name, "restart", by, func(serverConfig *config.MinecraftServerConfig) error {
+		m.setOverrideLocked(name, OverrideStarted, by)
+		m.stopServer(name)
+		return m.startOnlyLocked(serverConfig)
+	})
+}
+
+// ClearOverride hands a server back to the configuration. It takes effect on the
+// next poll.
+func (m *Manager) ClearOverride(name, by string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.serverConfigLocked(name) == nil {
+		return ErrUnknownServer
+	}
+	if _, ok := m.overrides[name]; !ok {
+		return nil
+	}
+	delete(m.overrides, name)
+	m.logger.Infof("Override on %s cleared by %s", name, by)
+	return m.saveOverridesLocked()
+}
+
+// Operation returns a lifecycle operation by ID
+func (m *Manager) Operation(id string) (*Operation, error) {
+	return m.operations.get(id)
+}
+
+// GetServer returns the status of one configured or running server
+func (m *Manager) GetServer(name string) (*ServerStatus, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if server, exists := m.servers[name]; exists {
+		status := m.serverStatusLocked(name, server)
+		return &status, nil
+	}
+	serverConfig := m.serverConfigLocked(name)
+	if serverConfig == nil {
+		return nil, ErrUnknownServer
+	}
+	status := m.stoppedStatusLocked(serverConfig)
+	return &status, nil
+}
+
+// ListServers returns the status of every configured server in configuration
+// order, followed by any running server no longer configured
+func (m *Manager) ListServers() []ServerStatus {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	statuses := []ServerStatus{}
+	listed := make(map[string]bool)
+	if m.lastConfig != nil {
+		for i := range m.lastConfig.Servers {
+			serverConfig := &m.lastConfig.Servers[i]
+			listed[serverConfig.Name] = true
+			if server, exists := m.servers[serverConfig.Name]; exists {
+				statuses = append(statuses, m.serverStatusLocked(serverConfig.Name, server))
+			} else {
+				statuses = append(statuses, m.stoppedStatusLocked(serverConfig))
+			}
+		}
+	}
+	var unlisted []string
+	for name := range m.servers {
+		if !listed[name] {
+			unlisted = append(unlisted, name)
+		}
+	}
+	sort.Strings(unlisted)
+	for _, name := range unlisted {
+		statuses = append(statuses, m.serverStatusLocked(name, m.servers[name]))
+	}
+	return statuses
+}
+
+// stoppedStatusLocked describes a configured server that is not running. Must be
+// called with m.mu held.
+func (m *Manager) stoppedStatusLocked(serverConfig *config.MinecraftServerConfig) ServerStatus {
+	return ServerStatus{
+		Name:     serverConfig.Name,
+		Status:   "stopped",
+		Port:     serverConfig.Port,
+		Version:  serverConfig.Version,
+		Override: m.overrideLocked(serverConfig.Name),
+	}
+}
+
+// runLifecycle validates the server, records an operation and runs action with
+// m.mu held in the background
+func (m *Manager) runLifecycle(name, action, by string, fn func(*config.MinecraftServerConfig) error) (*Operation, error) {
+	m.mu.RLock()
+	known := m.serverConfigLocked(name) != nil
+	m.mu.RUnlock()
+	if !known {
+		return nil, ErrUnknownServer
+	}
+
+	op, err := m.operations.begin(name, action, by)
+	if err != nil {
+		return nil, err
+	}
+	m.logger.Infof("Requested %s of %s by %s (operation %s)", action, name, by, op.ID)
+
+	go func() {
+		m.mu.Lock()
+		serverConfig := m.serverConfigLocked(name)
+		var err error
+		if serverConfig == nil {
+			err = ErrUnknownServer
+		} else {
+			err = fn(serverConfig)
+		}
+		if saveErr := m.saveOverridesLocked(); saveErr != nil {
+			m.logger.Warnf("Failed to save overrides: %v", saveErr)
+		}
+		m.mu.Unlock()
+
+		if err != nil {
+			m.logger.Errorf("Operation %s (%s of %s) failed: %v", op.ID, action, name, err)
+		}
+		m.operations.finish(op.ID, err)
+	}()
+	return op, nil
+}
+
+// startOnlyLocked starts a server after stopping every other one, as only one
+// Bedrock server can run at a time. Must be called with m.mu held.
+func (m *Manager) startOnlyLocked(serverConfig *config.MinecraftServerConfig) error {
+	for other := range m.servers {
+		if other != serverConfig.Name {
+			m.logger.Infof("Stopping %s to start %s (single-server mode)", other, serverConfig.Name)
+			m.stopServer(other)
+		}
+	}
+
+	m.startServer(serverConfig)
+	if _, exists := m.servers[serverConfig.Name]; !exists {
+		return errStartFailed
+	}
+	return nil
+}
+
+func (m *Manager) setOverrideLocked(name, state, by string) {
+	m.overrides[name] = Override{State: state, By: by, Time: time.Now()}
+}
+
+func (m *Manager) overrideLocked(name string) *Override {
+	if override, ok := m.overrides[name]; ok {
+		return &override
+	}
+	return nil
+}
+
+// selectServerLocked returns the index of the server to run in single-server
+// mode: one started by an admin, or else the first configured server
+func (m *Manager) selectServerLocked(repoConfig *config.RepoConfig) int {
+	for i, serverConfig := range repoConfig.Servers {
+		if override, ok := m.overrides[serverConfig.Name]; ok && override.State == OverrideStarted {
+			return i
+		}
+	}
+	return 0
+}
+
+func (m *Manager) overridesPath() string {
+	return filepath.Join(m.config.Server.BaseDir, "overrides.json")
+}
+
+// loadOverrides restores the overrides saved by a previous run
+func (m *Manager) loadOverrides() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	data, err := os.ReadFile(m.overridesPath())
+	if os.IsNotExist(err) {
+		return
+	}
+	if err == nil {
+		err = json.Unmarshal(data, &m.overrides)
+	}
+	if err != nil {
+		m.logger.Warnf("Failed to load overrides, starting without them: %v", err)
+		m.overrides = make(map[string]Override)
+		return
+	}
+	for name, override := range m.overrides {
+		m.logger.Infof("Server %s is %s by %s since %s", name, override.State, override.By, override.Time.Format(time.RFC3339))
+	}
+}
+
+// saveOverridesLocked persists overrides so they survive a restart of the manager
+func (m *Manager) saveOverridesLocked() error {
+	data, err := json.MarshalIndent(m.overrides, "", "  ")
+	if err != nil {
+		return err
+	}
+	path := m.overridesPath()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", filepath.Dir(path), err)
+	}
+	if err := os.WriteFile(path+".tmp", data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
@@ -0,0 +1,197 @@
+package server
+
+import (
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"minecraft-server-manager/internal/config"
+)
+
+// cpuPeriod is the cpu.max period in microseconds
+const cpuPeriod = 100000
+
+// resourceLimits are the limits applied to one bedrock_server process. Zero means unlimited.
+type resourceLimits struct {
+	MemoryBytes int64
+	CPUQuota    int64 // Microseconds per cpuPeriod
+	Pids        int64
+}
+
+// ResourceUsage reports a server's resource consumption
+type ResourceUsage struct {
+	Enforcement      string  `json:"enforcement"` // cgroup or none
+	MemoryBytes      int64   `json:"memory_bytes"`
+	MemoryLimitBytes int64   `json:"memory_limit_bytes,omitempty"`
+	MemoryPeakBytes  int64   `json:"memory_peak_bytes,omitempty"`
+	CPUSeconds       float64 `json:"cpu_seconds,omitempty"`
+	CPULimit         float64 `json:"cpu_limit,omitempty"` // In cores
+	Pids             int64   `json:"pids,omitempty"`
+	PidsLimit        int64   `json:"pids_limit,omitempty"`
+	OOMKills         int64   `json:"oom_kills"`
+}
+
+// resolveLimits combines the global defaults with a server's own overrides
+func resolveLimits(global config.ServerConfig, serverConfig *config.MinecraftServerConfig) (resourceLimits, error) {
+	var limits resourceLimits
+
+	memory := global.MemoryLimit
+	if serverConfig.MemoryLimit != "" {
+		memory = serverConfig.MemoryLimit
+	}
+	bytes, err := parseMemoryLimit(memory)
+	if err != nil {
+		return limits, err
+	}
+	limits.MemoryBytes = bytes
+
+	cpu := global.CPULimit
+	if serverConfig.CPULimit != "" {
+		cpu = serverConfig.CPULimit
+	}
+	quota, err := parseCPULimit(cpu)
+	if err != nil {
+		return limits, err
+	}
+	limits.CPUQuota = quota
+
+	limits.Pids = int64(global.PidsLimit)
+	if serverConfig.PidsLimit != 0 {
+		limits.Pids = int64(serverConfig.PidsLimit)
+	}
+	if limits.Pids < 0 {
+		return limits, fmt.Errorf("invalid pids limit %d", limits.Pids)
+	}
+
+	return limits, nil
+}
+
+// parseMemoryLimit parses sizes such as "512M", "1G" or "1536Mi". "" and "max" mean unlimited.
+func parseMemoryLimit(value string) (int64, error) {
+	value = strings.TrimSpace(value)
+	if value == "" || value == "max" {
+		return 0, nil
+	}
+
+	upper := strings.TrimSuffix(strings.TrimSuffix(strings.ToUpper(value), "B"), "I")
+	multiplier := int64(1)
+	switch {
+	case strings.HasSuffix(upper, "K"):
+		multiplier = 1 << 10
+	case strings.HasSuffix(upper, "M"):
+		multiplier = 1 << 20
+	case strings.HasSuffix(upper, "G"):
+		multiplier = 1 << 30
+	case strings.HasSuffix(upper, "T"):
+		multiplier = 1 << 40
+	}
+	if multiplier > 1 {
+		upper = upper[:len(upper)-1]
+	}
+
+	n, err := strconv.ParseFloat(upper, 64)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid memory limit %q", value)
+	}
+	return int64(n * float64(multiplier)), nil
+}
+
+// parseCPULimit parses a number of cores such as "2" or "0.5" into a cpu.max quota.
+// "" and "max" mean unlimited.
+func parseCPULimit(value string) (int64, error) {
+	value = strings.TrimSpace(value)
+	if value == "" || value == "max" {
+		return 0, nil
+	}
+
+	cores, err := strconv.ParseFloat(value, 64)
+	if err != nil || cores <= 0 {
+		return 0, fmt.Errorf("invalid cpu limit %q", value)
+	}
+
+	// The kernel rejects quotas below 1ms
+	quota := int64(cores * cpuPeriod)
+	if quota < 1000 {
+		quota = 1000
+	}
+	return quota, nil
+}
+
+// limitString formats a limit for a cgroup control file
+func limitString(value int64) string {
+	if value <= 0 {
+		return "max"
+	}
+	return strconv.FormatInt(value, 10)
+}
+
+// cgroupName turns a server name into a safe directory name
+func cgroupName(serverName string) string {
+	var b strings.Builder
+	b.WriteString("server-")
+	for _, c := range serverName {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '-', c == '_', c == '.':
+			b.WriteRune(c)
+		default:
+			b.WriteRune('_')
+		}
+	}
+	return b.String()
+}
+
+// initResourceLimits sets up cgroup v2 enforcement, leaving m.cgroups nil when the
+// manager has no delegated cgroup to work with
+func (m *Manager) initResourceLimits() {
+	controller, err := newCgroupController(m.config.Server.CgroupParent, m.logger)
+	if err != nil {
+		m.logger.Warnf("cgroup v2 delegation unavailable, resource limits not enforced, usage only: %v", err)
+		return
+	}
+	m.cgroups = controller
+	m.logger.Infof("Enforcing server resource limits with cgroups below %s", controller.parent)
+}
+
+// startLimited starts cmd inside its own cgroup. Without cgroups the limits are
+// not enforced and only usage is reported.
+func (m *Manager) startLimited(name string, cmd *exec.Cmd, limits resourceLimits) (*serverCgroup, string, error) {
+	if m.cgroups != nil {
+		cg, err := m.cgroups.create(name, limits)
+		if err == nil {
+			if cmd.SysProcAttr == nil {
+				cmd.SysProcAttr = &syscall.SysProcAttr{}
+			}
+			var release func()
+			release, err = cg.attach(cmd.SysProcAttr)
+			if err == nil {
+				err = cmd.Start()
+				release()
+				if err != nil {
+					cg.remove()
+					return nil, "", err
+				}
+				return cg, "cgroup", nil
+			}
+			cg.remove()
+		}
+		m.logger.Warnf("Failed to create cgroup for %s, limits not enforced, usage only: %v", name, err)
+	}
+
+	if err := cmd.Start(); err != nil {
+		return nil, "", err
+	}
+	return nil, "none", nil
+}
+
+// resourceUsage reports usage for a running server
+func (server *MinecraftServer) resourceUsage() *ResourceUsage {
+	if server.cgroup != nil {
+		return server.cgroup.usage(server.limits)
+	}
+	if server.Process == nil || server.Process.Process == nil || server.Status == "crashed" || server.Status == "stopped" {
+		return nil
+	}
+	return processUsage(server.Process.Process.Pid, server.limits, server.enforcement)
+}
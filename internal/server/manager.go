@@ -2,8 +2,6 @@ package server
 
 import (
 	"context"
-	"crypto/sha256"
-	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -15,9 +13,13 @@ import (
 	"sync"
 	"time"
 
+	"minecraft-server-manager/internal/archive"
+	"minecraft-server-manager/internal/backup"
 	"minecraft-server-manager/internal/config"
 	"minecraft-server-manager/internal/github"
+	"minecraft-server-manager/internal/preserve"
 
+	"github.com/robfig/cron/v3"
 	"github.com/sirupsen/logrus"
 )
 
@@ -29,25 +31,54 @@ type Manager struct {
 	lastConfig    *config.RepoConfig
 	lastCommitSHA string
 	bedrockPath   string
+	reloadCh      chan struct{}
+	cgroups       *cgroupController
+	scheduler     *cron.Cron
+	backupMu      sync.Mutex // Serializes backups, restores and chunk GC
+	replicateMu   sync.Mutex // Serializes uploads to the backup remote
+	auditMu       sync.Mutex // Serializes writes to console audit trails
+	overrides     map[string]Override
+	operations    *operationLog
+	restoring     map[string]bool // Servers whose world is being restored, which must not start
 }
 
 type MinecraftServer struct {
-	Config    *config.MinecraftServerConfig
-	Process   *exec.Cmd
-	Status    string
-	StartTime time.Time
-	Port      int
-	Logs      []string
-	MaxLogs   int
+	Config      *config.MinecraftServerConfig
+	Process     *exec.Cmd
+	Status      string
+	StartTime   time.Time
+	Port        int
+	BedrockPath string
+	MaxLogs     int
+	output      *consoleOutput
+	cgroup      *serverCgroup
+	limits      resourceLimits
+	enforcement string
+	stdin       io.WriteCloser
+	stdinMu     sync.Mutex
+
+	DetectedVersion string // From the server's startup output
+	RolledBackFrom  string // Version that failed to start and was rolled back
+
+	players map[string]Player            // Online players by name, from the console
+	viewers map[*ConsoleSession]struct{} // Attached consoles, guarded by the manager's lock
 }
 
 type ServerStatus struct {
-	Name        string    `json:"name"`
-	Status      string    `json:"status"`
-	Port        int       `json:"port"`
-	StartTime   time.Time `json:"start_time"`
-	Uptime      string    `json:"uptime"`
-	PlayerCount int       `json:"player_count"`
+	Name            string         `json:"name"`
+	Status          string         `json:"status"`
+	Port            int            `json:"port"`
+	StartTime       time.Time      `json:"start_time"`
+	Uptime          string         `json:"uptime"`
+	PlayerCount     int            `json:"player_count"`
+	Players         []Player       `json:"players,omitempty"`
+	ConsoleViewers  []string       `json:"console_viewers,omitempty"` // Who has the console open
+	Resources       *ResourceUsage `json:"resources,omitempty"`
+	Version         string         `json:"version,omitempty"`          // Configured
+	DetectedVersion string         `json:"detected_version,omitempty"` // Reported by the running binary
+	VersionMismatch bool           `json:"version_mismatch,omitempty"`
+	RolledBackFrom  string         `json:"rolled_back_from,omitempty"`
+	Override        *Override      `json:"override,omitempty"`
 }
 
 type ManagerStatus struct {
@@ -56,7 +87,8 @@ type ManagerStatus struct {
 	Stopped      int            `json:"stopped"`
 	Servers      []ServerStatus `json:"servers"`
 	LastUpdate   time.Time      `json:"last_update"`
-	BedrockPath  string         `json:"bedrock_path"`
+	BedrockPath  string         `json:"bedrock_path,omitempty"`  // Only shown to admins
+	ConfigCommit string         `json:"config_commit,omitempty"` // Commit of servers.yaml last applied
 }
 
 type WhitelistEntry struct {
@@ -72,9 +104,14 @@ type PermissionsEntry struct {
 
 func NewManager(cfg *config.Config, logger *logrus.Logger) *Manager {
 	return &Manager{
-		config:  cfg,
-		logger:  logger,
-		servers: make(map[string]*MinecraftServer),
+		config:     cfg,
+		logger:     logger,
+		servers:    make(map[string]*MinecraftServer),
+		reloadCh:   make(chan struct{}, 1),
+		scheduler:  cron.New(),
+		overrides:  make(map[string]Override),
+		operations: newOperationLog(),
+		restoring:  make(map[string]bool),
 	}
 }
 
@@ -84,17 +121,30 @@ func (m *Manager) Start(ctx context.Context, githubClient *github.Client) {
 	// Clean up any existing processes on server ports
 	m.cleanupPortsOnStartup()
 
-	// Initialize Bedrock server
-	if err := m.initializeBedrockServer(); err != nil {
+	// Manual starts and stops outlive the manager
+	m.loadOverrides()
+
+	// Set GitHub client configuration
+	current := m.configSnapshot()
+	applyGitHubSettings(githubClient, current, current)
+	if overlayPath := githubClient.OverlayPath(); overlayPath != "" {
+		m.logger.Infof("Using environment '%s' (overlay %s)", current.Environment, overlayPath)
+	}
+
+	// Initialize Bedrock server, which may fetch layers from the configuration repository
+	if err := m.initializeBedrockServer(ctx, githubClient); err != nil {
 		m.logger.Errorf("Failed to initialize Bedrock server: %v", err)
 		return
 	}
 
-	// Set GitHub client configuration
-	githubClient.SetBranch(m.config.GitHub.Branch)
-	githubClient.SetConfigPath(m.config.GitHub.ConfigPath)
+	// Set up per-server resource limits
+	m.initResourceLimits()
+
+	// Run scheduled restarts, commands and open hours
+	m.scheduler.Start()
+	defer m.scheduler.Stop()
 
-	ticker := time.NewTicker(time.Duration(m.config.GitHub.PollInterval) * time.Second)
+	ticker := time.NewTicker(time.Duration(current.GitHub.PollInterval) * time.Second)
 	defer ticker.Stop()
 
 	// Initial configuration load
@@ -108,47 +158,60 @@ func (m *Manager) Start(ctx context.Context, githubClient *github.Client) {
 			return
 		case <-ticker.C:
 			m.pollConfiguration(githubClient)
+		case <-m.reloadCh:
+			previous := current
+			current = m.configSnapshot()
+			ticker.Reset(time.Duration(current.GitHub.PollInterval) * time.Second)
+
+			// A different source means the last commit SHA no longer applies
+			if applyGitHubSettings(githubClient, previous, current) {
+				m.logger.Infof("Configuration source changed to %s@%s (environment '%s'), re-polling", current.GitHub.ConfigPath, current.GitHub.Branch, current.Environment)
+				m.mu.Lock()
+				m.lastCommitSHA = ""
+				m.mu.Unlock()
+				m.pollConfiguration(githubClient)
+			}
 		}
 	}
 }
 
-func (m *Manager) initializeBedrockServer() error {
+func (m *Manager) initializeBedrockServer(ctx context.Context, githubClient *github.Client) error {
 	versionsDir := "versions"
 	bedrockArchive := filepath.Join(versionsDir, "bedrock-server.zip")
 
-	// Check if versions/bedrock-server.zip exists
-	if _, err := os.Stat(bedrockArchive); err != nil {
-		if os.IsNotExist(err) {
-			m.logger.Info("No Bedrock server archive found in versions/bedrock-server.zip, using configured path")
-			// Convert relative path to absolute path
-			if !filepath.IsAbs(m.config.Server.BedrockPath) {
-				absPath, err := filepath.Abs(m.config.Server.BedrockPath)
-				if err != nil {
-					return fmt.Errorf("failed to get absolute path for %s: %w", m.config.Server.BedrockPath, err)
-				}
-				m.bedrockPath = absPath
-			} else {
-				m.bedrockPath = m.config.Server.BedrockPath
+	// Split versions/bedrock-server.zip into layers, or find the layers without it
+	manifest, source, err := m.prepareLayers(ctx, githubClient, versionsDir, bedrockArchive)
+	if err != nil {
+		return fmt.Errorf("failed to prepare layers: %w", err)
+	}
+	if manifest == nil {
+		m.logger.Info("No Bedrock server archive or layers found in versions/, using configured path")
+		// Convert relative path to absolute path
+		if !filepath.IsAbs(m.config.Server.BedrockPath) {
+			absPath, err := filepath.Abs(m.config.Server.BedrockPath)
+			if err != nil {
+				return fmt.Errorf("failed to get absolute path for %s: %w", m.config.Server.BedrockPath, err)
 			}
-			return nil
+			m.bedrockPath = absPath
+		} else {
+			m.bedrockPath = m.config.Server.BedrockPath
 		}
-		return fmt.Errorf("failed to check Bedrock server archive: %w", err)
+		return nil
 	}
 
-	m.logger.Info("Found Bedrock server archive (bedrock-server.zip), processing...")
-
-	// Remove existing layer files and extracted directory
+	// Remove the archive recombined on the last start
 	if err := m.cleanupLayers(); err != nil {
 		return fmt.Errorf("failed to cleanup existing files: %w", err)
 	}
 
-	// Split the archive into 10 layers
-	if err := m.splitArchive(bedrockArchive); err != nil {
-		return fmt.Errorf("failed to split archive: %w", err)
+	// Keep the previous extraction until operator files have been carried over
+	previousDir, err := m.setAsidePreviousRelease()
+	if err != nil {
+		return err
 	}
 
 	// Recombine the layers
-	if err := m.recombineLayers(); err != nil {
+	if err := m.recombineLayers(ctx, versionsDir, manifest, source); err != nil {
 		return fmt.Errorf("failed to recombine layers: %w", err)
 	}
 
@@ -157,6 +220,11 @@ func (m *Manager) initializeBedrockServer() error {
 		return fmt.Errorf("failed to extract archive: %w", err)
 	}
 
+	// Merge operator-managed files from the previous extraction
+	if err := m.preserveOperatorFiles(manifest.SHA256, previousDir); err != nil {
+		return err
+	}
+
 	// Set the Bedrock path to the extracted executable (absolute path)
 	absPath, err := filepath.Abs("./bedrock-server-extracted/bedrock_server")
 	if err != nil {
@@ -169,197 +237,29 @@ func (m *Manager) initializeBedrockServer() error {
 }
 
 func (m *Manager) cleanupLayers() error {
-	// Remove existing layer files
-	for i := 0; i < 10; i++ {
-		layerFile := fmt.Sprintf("versions/bedrock-server.layer.%d", i)
-		if err := os.Remove(layerFile); err != nil && !os.IsNotExist(err) {
-			return fmt.Errorf("failed to remove layer file %s: %w", layerFile, err)
-		}
-	}
-
-	// Remove extracted directory
-	if err := os.RemoveAll("bedrock-server-extracted"); err != nil {
-		return fmt.Errorf("failed to remove extracted directory: %w", err)
-	}
-
-	// Remove recombined archive
-	if err := os.Remove("versions/bedrock-server-recombined.zip"); err != nil && !os.IsNotExist(err) {
+	// Layers are kept between starts so a partly fetched set can be resumed
+	if err := os.Remove(filepath.Join("versions", recombinedArchive)); err != nil && !os.IsNotExist(err) {
 		return fmt.Errorf("failed to remove recombined archive: %w", err)
 	}
 
 	return nil
 }
 
-func (m *Manager) splitArchive(archivePath string) error {
-	// Open the archive file
-	file, err := os.Open(archivePath)
-	if err != nil {
-		return fmt.Errorf("failed to open archive: %w", err)
-	}
-	defer file.Close()
-
-	// Get file size
-	stat, err := file.Stat()
-	if err != nil {
-		return fmt.Errorf("failed to get file stats: %w", err)
-	}
-	fileSize := stat.Size()
-
-	// Calculate layer size
-	layerSize := fileSize / 10
-	remainder := fileSize % 10
-
-	m.logger.Infof("Splitting archive into 10 layers (file size: %d bytes, layer size: %d bytes)", fileSize, layerSize)
-
-	// Create layers directory if it doesn't exist
-	if err := os.MkdirAll("versions", 0755); err != nil {
-		return fmt.Errorf("failed to create versions directory: %w", err)
-	}
-
-	// Split the file into 10 layers
-	for i := 0; i < 10; i++ {
-		layerFile := fmt.Sprintf("versions/bedrock-server.layer.%d", i)
-
-		// Calculate actual layer size (last layer gets the remainder)
-		actualLayerSize := layerSize
-		if i == 9 {
-			actualLayerSize += remainder
-		}
-
-		// Create layer file
-		layer, err := os.Create(layerFile)
-		if err != nil {
-			return fmt.Errorf("failed to create layer file %s: %w", layerFile, err)
-		}
-
-		// Copy data to layer
-		written, err := io.CopyN(layer, file, actualLayerSize)
-		if err != nil && err != io.EOF {
-			layer.Close()
-			return fmt.Errorf("failed to write layer %d: %w", i, err)
-		}
-
-		layer.Close()
-		m.logger.Infof("Created layer %d: %s (%d bytes)", i, layerFile, written)
-	}
-
-	return nil
-}
-
-func (m *Manager) recombineLayers() error {
-	m.logger.Info("Recombining layers...")
-
-	// Create recombined file
-	recombinedFile := "versions/bedrock-server-recombined.zip"
-	output, err := os.Create(recombinedFile)
-	if err != nil {
-		return fmt.Errorf("failed to create recombined file: %w", err)
-	}
-	defer output.Close()
-
-	// Combine all layers
-	for i := 0; i < 10; i++ {
-		layerFile := fmt.Sprintf("versions/bedrock-server.layer.%d", i)
-
-		// Check if layer file exists
-		if _, err := os.Stat(layerFile); err != nil {
-			return fmt.Errorf("layer file %s not found: %w", layerFile, err)
-		}
-
-		// Open layer file
-		layer, err := os.Open(layerFile)
-		if err != nil {
-			return fmt.Errorf("failed to open layer file %s: %w", layerFile, err)
-		}
-
-		// Copy layer data to recombined file
-		written, err := io.Copy(output, layer)
-		if err != nil {
-			layer.Close()
-			return fmt.Errorf("failed to copy layer %d: %w", i, err)
-		}
-
-		layer.Close()
-		m.logger.Infof("Added layer %d to recombined file (%d bytes)", i, written)
-	}
-
-	// Verify file integrity
-	if err := m.verifyIntegrity(); err != nil {
-		return fmt.Errorf("integrity check failed: %w", err)
-	}
-
-	m.logger.Info("Layers recombined successfully")
-	return nil
-}
-
-func (m *Manager) verifyIntegrity() error {
-	originalFile := "versions/bedrock-server.zip"
-	recombinedFile := "versions/bedrock-server-recombined.zip"
-
-	// Calculate SHA256 of original file
-	originalHash, err := m.calculateFileHash(originalFile)
-	if err != nil {
-		return fmt.Errorf("failed to calculate original file hash: %w", err)
-	}
-
-	// Calculate SHA256 of recombined file
-	recombinedHash, err := m.calculateFileHash(recombinedFile)
-	if err != nil {
-		return fmt.Errorf("failed to calculate recombined file hash: %w", err)
-	}
-
-	// Compare hashes
-	if originalHash != recombinedHash {
-		return fmt.Errorf("integrity check failed: hashes don't match (original: %s, recombined: %s)", originalHash, recombinedHash)
-	}
-
-	m.logger.Infof("Integrity check passed: %s", originalHash)
-	return nil
-}
-
-func (m *Manager) calculateFileHash(filePath string) (string, error) {
-	file, err := os.Open(filePath)
-	if err != nil {
-		return "", err
-	}
-	defer file.Close()
-
-	hash := sha256.New()
-	if _, err := io.Copy(hash, file); err != nil {
-		return "", err
-	}
-
-	return hex.EncodeToString(hash.Sum(nil)), nil
-}
-
 func (m *Manager) extractArchive() error {
 	m.logger.Info("Extracting Bedrock server archive...")
 
-	// Create extraction directory
 	extractDir := "bedrock-server-extracted"
-	if err := os.MkdirAll(extractDir, 0755); err != nil {
-		return fmt.Errorf("failed to create extraction directory: %w", err)
-	}
-
-	// Determine archive type and extract
-	archivePath := "versions/bedrock-server-recombined.zip"
-
-	// Since we know it's a zip file, try unzip first
-	m.logger.Info("Extracting zip archive...")
-	cmd := exec.Command("unzip", "-o", archivePath, "-d", extractDir)
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-
-	if err := cmd.Run(); err != nil {
-		// If unzip fails, try tar.gz as fallback
-		m.logger.Info("zip extraction failed, trying tar.gz...")
-		cmd = exec.Command("tar", "-xzf", archivePath, "-C", extractDir)
-		cmd.Stdout = os.Stdout
-		cmd.Stderr = os.Stderr
-
-		if err := cmd.Run(); err != nil {
-			return fmt.Errorf("failed to extract archive (tried zip and tar.gz): %w", err)
-		}
+	archivePath := filepath.Join("versions", recombinedArchive)
+
+	// Zip, tar, tar.gz and tar.zst are recognised from the archive itself
+	err := archive.Extract(archivePath, extractDir, archive.Options{
+		Progress: archive.Steps(25, func(done, total int64) {
+			m.logger.Infof("Extracting Bedrock server archive: %d%%", done*100/total)
+		}),
+		Replace: true,
+	})
+	if err != nil {
+		return err
 	}
 
 	// Look for the bedrock_server executable
@@ -414,10 +314,18 @@ func (m *Manager) pollConfiguration(githubClient *github.Client) {
 		return
 	}
 
+	// The status API reads the commit while this runs
+	m.mu.RLock()
+	firstRun := m.config.Server.FirstRun
+	lastCommitSHA := m.lastCommitSHA
+	m.mu.RUnlock()
+
 	// Handle first run scenario
-	if m.config.Server.FirstRun && m.lastCommitSHA == "" {
+	if firstRun && lastCommitSHA == "" {
 		m.logger.Info("First run detected, setting initial commit SHA")
+		m.mu.Lock()
 		m.lastCommitSHA = commitSHA
+		m.mu.Unlock()
 
 		// Get initial configuration
 		repoConfig, err := githubClient.GetConfig()
@@ -426,17 +334,22 @@ func (m *Manager) pollConfiguration(githubClient *github.Client) {
 			return
 		}
 
+		// Download new versions before any server is stopped
+		m.installVersions(repoConfig)
+		m.syncAssets(githubClient, repoConfig)
+		saved := m.backupBeforeUpdate(repoConfig)
+
 		m.mu.Lock()
 		defer m.mu.Unlock()
 
 		// Update servers based on initial configuration
-		m.updateServers(repoConfig)
+		m.updateServers(repoConfig, saved)
 		m.lastConfig = repoConfig
 		return
 	}
 
 	// If no changes, skip
-	if commitSHA == m.lastCommitSHA {
+	if commitSHA == lastCommitSHA {
 		return
 	}
 
@@ -449,37 +362,131 @@ func (m *Manager) pollConfiguration(githubClient *github.Client) {
 		return
 	}
 
+	// Download new versions before any server is stopped
+	m.installVersions(repoConfig)
+	m.syncAssets(githubClient, repoConfig)
+	saved := m.backupBeforeUpdate(repoConfig)
+
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
 	// Update servers based on new configuration
-	m.updateServers(repoConfig)
+	m.updateServers(repoConfig, saved)
 	m.lastConfig = repoConfig
 	m.lastCommitSHA = commitSHA
 }
 
-func (m *Manager) updateServers(repoConfig *config.RepoConfig) {
+// backupBeforeUpdate backs up the worlds an update is about to touch: every
+// running server hot, and the server to be started cold when its version
+// changes while it is down. The backups run without m.mu, so status and the
+// console stay available while worlds are copied.
+func (m *Manager) backupBeforeUpdate(repoConfig *config.RepoConfig) map[string]*backup.Info {
+	type pending struct {
+		config *config.MinecraftServerConfig
+		server *MinecraftServer
+	}
+	var backups []pending
+
+	m.mu.RLock()
+	cfg := m.config
+	previous := m.previousConfigsLocked()
+	running := make(map[string]bool)
+	for name, server := range m.servers {
+		if server.isRunning() {
+			running[name] = true
+			backups = append(backups, pending{server.Config, server})
+		}
+	}
+	if len(repoConfig.Servers) > 0 {
+		serverConfig := repoConfig.Servers[m.selectServerLocked(repoConfig)]
+		last, known := previous[serverConfig.Name]
+		if known && last.Version != serverConfig.Version && !running[serverConfig.Name] {
+			// The server is not running, so back up its world cold before the new version touches it
+			backups = append(backups, pending{&serverConfig, nil})
+		}
+	}
+	m.mu.RUnlock()
+
+	saved := make(map[string]*backup.Info)
+	for _, b := range backups {
+		info, err := m.backupServer(cfg, b.config, b.server)
+		if err != nil {
+			m.logger.Warnf("Backup before updating %s failed: %v", b.config.Name, err)
+		}
+		saved[b.config.Name] = info
+	}
+	return saved
+}
+
+// previousConfigsLocked returns what each server ran before, from the last
+// configuration and the running servers. Must be called with m.mu held.
+func (m *Manager) previousConfigsLocked() map[string]config.MinecraftServerConfig {
+	previous := make(map[string]config.MinecraftServerConfig)
+	if m.lastConfig != nil {
+		for _, serverConfig := range m.lastConfig.Servers {
+			previous[serverConfig.Name] = serverConfig
+		}
+	}
+	for name, server := range m.servers {
+		previous[name] = *server.Config
+	}
+	return previous
+}
+
+// updateServers stops the running servers and starts the selected one. saved
+// holds the backups taken by backupBeforeUpdate, which an upgrade rolls back to.
+func (m *Manager) updateServers(repoConfig *config.RepoConfig, saved map[string]*backup.Info) {
+	// Remember what each server ran before, so a failed upgrade can be rolled back
+	previous := m.previousConfigsLocked()
+
 	// Stop all existing servers first
 	for name := range m.servers {
 		m.logger.Infof("Stopping server %s", name)
 		m.stopServer(name)
 	}
 
-	// Only start the first server in the configuration to avoid IPv6 port conflicts
+	// Only start one server to avoid IPv6 port conflicts: the first in the
+	// configuration, unless an admin started another one
 	// Bedrock server always binds to IPv6 port 19133, which prevents multiple servers
 	if len(repoConfig.Servers) > 0 {
-		serverConfig := repoConfig.Servers[0]
-		m.logger.Infof("Starting server %s (single-server mode due to IPv6 port limitations)", serverConfig.Name)
-		m.startServer(&serverConfig)
+		selected := m.selectServerLocked(repoConfig)
+		serverConfig := repoConfig.Servers[selected]
+		// A server started by an admin runs regardless of its open hours
+		override := m.overrideLocked(serverConfig.Name)
+		open, err := withinOpenHours(&serverConfig, time.Now())
+		switch {
+		case override != nil && override.State == OverrideStopped:
+			m.logger.Infof("Server %s was stopped by %s, not starting", serverConfig.Name, override.By)
+		case override == nil && err != nil:
+			m.logger.Errorf("Invalid open hours for %s: %v", serverConfig.Name, err)
+		case override == nil && !open:
+			m.logger.Infof("Server %s is outside its open hours, not starting", serverConfig.Name)
+		default:
+			last, known := previous[serverConfig.Name]
+			upgrade := known && last.Version != serverConfig.Version
+
+			m.logger.Infof("Starting server %s (single-server mode due to IPv6 port limitations)", serverConfig.Name)
+			m.startServer(&serverConfig)
+
+			if server, exists := m.servers[serverConfig.Name]; exists && upgrade {
+				m.logger.Infof("Upgrading %s from Bedrock %s to %s", serverConfig.Name, versionName(last.Version), versionName(serverConfig.Version))
+				timeout := time.Duration(m.config.Server.ReadyTimeout) * time.Second
+				go m.watchUpgrade(serverConfig.Name, server.Process, last, saved[serverConfig.Name], timeout)
+			}
+		}
 
 		// Log that other servers are skipped
 		if len(repoConfig.Servers) > 1 {
 			m.logger.Infof("Skipping %d additional servers due to Bedrock server IPv6 port limitations", len(repoConfig.Servers)-1)
-			for i := 1; i < len(repoConfig.Servers); i++ {
-				m.logger.Infof("  - Skipped: %s", repoConfig.Servers[i].Name)
+			for i := range repoConfig.Servers {
+				if i != selected {
+					m.logger.Infof("  - Skipped: %s", repoConfig.Servers[i].Name)
+				}
 			}
 		}
 	}
+
+	m.rescheduleLocked(repoConfig)
 }
 
 func (m *Manager) serverConfigChanged(old, new *config.MinecraftServerConfig) bool {
@@ -488,6 +495,11 @@ func (m *Manager) serverConfigChanged(old, new *config.MinecraftServerConfig) bo
 }
 
 func (m *Manager) startServer(serverConfig *config.MinecraftServerConfig) {
+	if m.restoring[serverConfig.Name] {
+		m.logger.Warnf("Not starting %s while its world is being restored", serverConfig.Name)
+		return
+	}
+
 	serverDir := m.config.GetServerDir(serverConfig.Name)
 
 	// Create server directory
@@ -496,6 +508,13 @@ func (m *Manager) startServer(serverConfig *config.MinecraftServerConfig) {
 		return
 	}
 
+	// Resolve resource limits before touching any files
+	limits, err := resolveLimits(m.config.Server, serverConfig)
+	if err != nil {
+		m.logger.Errorf("Invalid resource limits for %s: %v", serverConfig.Name, err)
+		return
+	}
+
 	// Since we're only running one server at a time, we can safely kill any existing Bedrock processes
 	// This ensures a clean start without port conflicts
 	m.killAllBedrockServers()
@@ -503,8 +522,9 @@ func (m *Manager) startServer(serverConfig *config.MinecraftServerConfig) {
 	// Wait a bit to ensure ports are fully released
 	time.Sleep(3 * time.Second)
 
-	// Check if Bedrock server executable exists
-	if err := m.checkBedrockServer(serverConfig.Version); err != nil {
+	// Find the Bedrock binary for the server's version
+	bedrockPath, err := m.resolveBedrockPath(serverConfig.Version)
+	if err != nil {
 		m.logger.Errorf("Failed to check Bedrock server for %s: %v", serverConfig.Name, err)
 		return
 	}
@@ -517,7 +537,7 @@ func (m *Manager) startServer(serverConfig *config.MinecraftServerConfig) {
 	}
 
 	// Copy server.properties to bedrock-server-extracted directory to override defaults
-	bedrockPropertiesPath := filepath.Join(filepath.Dir(m.bedrockPath), "server.properties")
+	bedrockPropertiesPath := filepath.Join(filepath.Dir(bedrockPath), "server.properties")
 	if err := m.copyServerProperties(propertiesPath, bedrockPropertiesPath); err != nil {
 		m.logger.Errorf("Failed to copy server.properties to bedrock directory for %s: %v", serverConfig.Name, err)
 		return
@@ -538,35 +558,71 @@ func (m *Manager) startServer(serverConfig *config.MinecraftServerConfig) {
 	}
 
 	// Start the server process in the bedrock-server-extracted directory
-	bedrockDir := filepath.Dir(m.bedrockPath)
-	cmd := exec.Command(m.bedrockPath,
+	bedrockDir := filepath.Dir(bedrockPath)
+
+	// Bedrock keeps worlds next to its executable; point that at the server directory
+	if err := m.linkWorldsDir(bedrockDir, m.config.GetWorldsDir(serverConfig.Name)); err != nil {
+		m.logger.Errorf("Failed to link worlds directory for %s: %v", serverConfig.Name, err)
+		return
+	}
+
+	// Seed a new world from the server's template before packs go into it
+	if err := m.applyWorldTemplate(serverConfig); err != nil {
+		m.logger.Errorf("Failed to apply world template for %s: %v", serverConfig.Name, err)
+		return
+	}
+
+	// Install configured packs into the world before Bedrock loads it
+	if err := m.applyPacks(serverConfig); err != nil {
+		m.logger.Errorf("Failed to apply packs for %s: %v", serverConfig.Name, err)
+		return
+	}
+
+	cmd := exec.Command(bedrockPath,
 		"-port", strconv.Itoa(20000+serverConfig.Port-19132), // Use port range 20000+ to avoid conflicts
 		"-worldsdir", serverDir,
 		"-world", serverConfig.WorldName,
 		"-logpath", filepath.Join(serverDir, "logs"))
 
+	// Capture output for backups and status while still echoing it to our own stdout
+	output := newConsoleOutput(100, os.Stdout)
 	cmd.Dir = bedrockDir
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
+	cmd.Stdout = output
+	cmd.Stderr = output
+
+	// Keep the console open for scheduled and manual commands
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		m.logger.Errorf("Failed to open console for %s: %v", serverConfig.Name, err)
+		return
+	}
 
-	if err := cmd.Start(); err != nil {
+	cg, enforcement, err := m.startLimited(serverConfig.Name, cmd, limits)
+	if err != nil {
 		m.logger.Errorf("Failed to start server %s: %v", serverConfig.Name, err)
 		return
 	}
 
 	server := &MinecraftServer{
-		Config:    serverConfig,
-		Process:   cmd,
-		Status:    "starting",
-		StartTime: time.Now(),
-		Port:      serverConfig.Port,
-		MaxLogs:   100,
+		Config:      serverConfig,
+		Process:     cmd,
+		Status:      "starting",
+		StartTime:   time.Now(),
+		Port:        serverConfig.Port,
+		BedrockPath: bedrockPath,
+		MaxLogs:     100,
+		output:      output,
+		cgroup:      cg,
+		limits:      limits,
+		enforcement: enforcement,
+		stdin:       stdin,
 	}
 
 	m.servers[serverConfig.Name] = server
 
 	// Monitor the process
-	go m.monitorServer(serverConfig.Name, cmd)
+	go m.monitorServer(serverConfig.Name, cmd, output)
+	go m.watchOutput(server)
 
 	m.logger.Infof("Server %s started on port %d", serverConfig.Name, serverConfig.Port)
 }
@@ -582,6 +638,12 @@ func (m *Manager) stopServer(name string) {
 		server.Process.Wait()
 	}
 
+	if server.cgroup != nil {
+		if err := server.cgroup.remove(); err != nil {
+			m.logger.Warnf("Failed to remove cgroup for %s: %v", name, err)
+		}
+	}
+
 	delete(m.servers, name)
 	m.logger.Infof("Server %s stopped", name)
 }
@@ -595,29 +657,63 @@ func (m *Manager) stopAllServers() {
 	}
 }
 
-func (m *Manager) monitorServer(name string, cmd *exec.Cmd) {
+func (m *Manager) monitorServer(name string, cmd *exec.Cmd, output *consoleOutput) {
 	err := cmd.Wait()
+	output.close()
 
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	if server, exists := m.servers[name]; exists {
-		if err != nil {
-			server.Status = "crashed"
-			m.logger.Errorf("Server %s crashed: %v", name, err)
-		} else {
-			server.Status = "stopped"
-			m.logger.Infof("Server %s stopped", name)
-		}
+	// Ignore processes that have already been replaced by a restart
+	if server, exists := m.servers[name]; exists && server.Process == cmd {
+		m.serverExitedLocked(name, server, err)
 	}
 }
 
-func (m *Manager) checkBedrockServer(version string) error {
-	// Check if Bedrock server executable exists
-	if _, err := os.Stat(m.bedrockPath); err != nil {
-		return fmt.Errorf("Bedrock server executable not found at %s", m.bedrockPath)
+// serverExitedLocked marks a server whose process has exited as crashed or
+// stopped. Must be called with m.mu held.
+func (m *Manager) serverExitedLocked(name string, server *MinecraftServer, err error) {
+	if err != nil {
+		server.Status = "crashed"
+		m.logger.Errorf("Server %s crashed: %v", name, err)
+	} else {
+		server.Status = "stopped"
+		m.logger.Infof("Server %s stopped", name)
+	}
+	// Nobody is left on a server that is not running
+	server.players = nil
+}
+
+// watchOutput tracks state changes that the server reports on its console
+func (m *Manager) watchOutput(server *MinecraftServer) {
+	lines, unsubscribe := server.output.subscribe()
+	defer unsubscribe()
+
+	for line := range lines {
+		if strings.Contains(line, "Server started.") {
+			m.mu.Lock()
+			if server.Status == "starting" {
+				server.Status = "running"
+			}
+			m.mu.Unlock()
+		}
+
+		if strings.Contains(line, "Player ") {
+			m.mu.Lock()
+			m.trackPlayerLocked(server, line)
+			m.mu.Unlock()
+		}
+
+		if version, ok := parseVersionLine(line); ok {
+			m.mu.Lock()
+			server.DetectedVersion = version
+			m.mu.Unlock()
+
+			if !versionMatches(server.Config.Version, version) {
+				m.logger.Warnf("Server %s is running Bedrock %s but is configured for %s", server.Config.Name, version, server.Config.Version)
+			}
+		}
 	}
-	return nil
 }
 
 func (m *Manager) createServerProperties(serverConfig *config.MinecraftServerConfig, propertiesPath string) error {
@@ -654,11 +750,25 @@ func (m *Manager) createServerProperties(serverConfig *config.MinecraftServerCon
 		"enable-lan-visibility": "false",
 	}
 
+	// Clients must accept the world's resource packs to join
+	if serverConfig.TexturepackRequired {
+		properties["texturepack-required"] = "true"
+	}
+
 	// Add custom properties
 	for key, value := range serverConfig.Properties {
 		properties[key] = value
 	}
 
+	// The server's server.properties asset wins over everything above
+	overlay, err := os.ReadFile(filepath.Join(m.config.GetAssetsDir(serverConfig.Name), "server.properties"))
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read server.properties asset: %w", err)
+	}
+	for key, value := range preserve.ParseProperties(overlay) {
+		properties[key] = value
+	}
+
 	// Write properties file
 	var content strings.Builder
 	for key, value := range properties {
@@ -723,17 +833,11 @@ func (m *Manager) GetStatus() ManagerStatus {
 		TotalServers: len(m.servers),
 		LastUpdate:   time.Now(),
 		BedrockPath:  m.bedrockPath,
+		ConfigCommit: m.lastCommitSHA,
 	}
 
 	for name, server := range m.servers {
-		uptime := time.Since(server.StartTime)
-		serverStatus := ServerStatus{
-			Name:      name,
-			Status:    server.Status,
-			Port:      server.Port,
-			StartTime: server.StartTime,
-			Uptime:    uptime.String(),
-		}
+		serverStatus := m.serverStatusLocked(name, server)
 
 		if server.Status == "running" {
 			status.Running++
@@ -747,6 +851,28 @@ func (m *Manager) GetStatus() ManagerStatus {
 	return status
 }
 
+// serverStatusLocked describes a running server. Must be called with m.mu held.
+func (m *Manager) serverStatusLocked(name string, server *MinecraftServer) ServerStatus {
+	players := server.onlinePlayers()
+	return ServerStatus{
+		Name:        name,
+		Status:      server.Status,
+		Port:        server.Port,
+		StartTime:   server.StartTime,
+		Uptime:      time.Since(server.StartTime).String(),
+		PlayerCount: len(players),
+		Players:     players,
+		Resources:   server.resourceUsage(),
+
+		Version:         server.Config.Version,
+		DetectedVersion: server.DetectedVersion,
+		VersionMismatch: !versionMatches(server.Config.Version, server.DetectedVersion),
+		RolledBackFrom:  server.RolledBackFrom,
+		ConsoleViewers:  server.consoleViewers(),
+		Override:        m.overrideLocked(name),
+	}
+}
+
 func (m *Manager) killProcessesOnPort(port int) error {
 	// Use lsof to find processes using the port (both IPv4 and IPv6)
 	cmd := exec.Command("lsof", "-ti", fmt.Sprintf(":%d", port))
@@ -848,6 +974,14 @@ func (m *Manager) copyServerProperties(sourcePath, destPath string) error {
 		return fmt.Errorf("failed to read source file: %w", err)
 	}
 
+	// Apply our values to the vendor file so its comments and other settings stay
+	existing, err := os.ReadFile(destPath)
+	if err == nil {
+		sourceContent = preserve.ApplyProperties(existing, preserve.ParseProperties(sourceContent))
+	} else if !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read destination file: %w", err)
+	}
+
 	// Write the destination file
 	if err := os.WriteFile(destPath, sourceContent, 0644); err != nil {
 		return fmt.Errorf("failed to write destination file: %w", err)
@@ -856,6 +990,51 @@ func (m *Manager) copyServerProperties(sourcePath, destPath string) error {
 	return nil
 }
 
+// linkWorldsDir replaces the worlds directory next to the Bedrock executable with a
+// symlink to worldsDir. Worlds already in a real directory there are moved over.
+func (m *Manager) linkWorldsDir(bedrockDir, worldsDir string) error {
+	absWorlds, err := filepath.Abs(worldsDir)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(absWorlds, 0755); err != nil {
+		return fmt.Errorf("failed to create worlds directory: %w", err)
+	}
+
+	link := filepath.Join(bedrockDir, "worlds")
+	info, err := os.Lstat(link)
+	switch {
+	case os.IsNotExist(err):
+	case err != nil:
+		return err
+	case info.Mode()&os.ModeSymlink != 0:
+		if err := os.Remove(link); err != nil {
+			return err
+		}
+	case info.IsDir():
+		entries, err := os.ReadDir(link)
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			// Never overwrite a world the server directory already has
+			target := filepath.Join(absWorlds, entry.Name())
+			if _, err := os.Stat(target); err == nil {
+				target += ".moved-" + time.Now().Format("20060102-150405")
+			}
+			m.logger.Infof("Moving world %s into %s", entry.Name(), absWorlds)
+			if err := os.Rename(filepath.Join(link, entry.Name()), target); err != nil {
+				return fmt.Errorf("failed to move world %s: %w", entry.Name(), err)
+			}
+		}
+		if err := os.RemoveAll(link); err != nil {
+			return err
+		}
+	}
+
+	return os.Symlink(absWorlds, link)
+}
+
 func (m *Manager) killAllBedrockServers() {
 	m.logger.Info("Killing all existing Bedrock server processes...")
 
@@ -1,7 +1,14 @@
 package server
 
 import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"minecraft-server-manager/internal/config"
 
@@ -57,3 +64,424 @@ func TestNormalMode(t *testing.T) {
 		t.Error("lastCommitSHA should start as empty string")
 	}
 }
+
+func TestReloadAppliesLiveSettings(t *testing.T) {
+	cfg := &config.Config{
+		GitHub: config.GitHubConfig{Branch: "main", PollInterval: 60},
+		HTTP:   config.HTTPConfig{Port: 8080},
+		Log:    config.LogConfig{Level: "info", Format: "text"},
+	}
+	manager := NewManager(cfg, logrus.New())
+
+	newCfg := *cfg
+	newCfg.GitHub.Branch = "staging"
+	newCfg.GitHub.PollInterval = 120
+	newCfg.HTTP.Port = 9090
+	newCfg.Log.Level = "debug"
+
+	report, err := manager.Reload(&newCfg)
+	if err != nil {
+		t.Fatalf("Reload failed: %v", err)
+	}
+
+	if manager.config.GitHub.Branch != "staging" || manager.config.GitHub.PollInterval != 120 {
+		t.Error("Branch and poll interval should be applied live")
+	}
+	if manager.config.HTTP.Port != 8080 {
+		t.Error("HTTP port should keep its running value until restart")
+	}
+	if manager.logger.GetLevel() != logrus.DebugLevel {
+		t.Error("Log level should be applied live")
+	}
+	if len(report.RestartRequired) != 1 || report.RestartRequired[0] != "http.port" {
+		t.Errorf("Expected http.port to require a restart, got %v", report.RestartRequired)
+	}
+}
+
+func TestReloadRejectsInvalidLogLevel(t *testing.T) {
+	cfg := &config.Config{Log: config.LogConfig{Level: "info"}}
+	manager := NewManager(cfg, logrus.New())
+
+	newCfg := *cfg
+	newCfg.Log.Level = "loud"
+	newCfg.GitHub.Branch = "staging"
+
+	if _, err := manager.Reload(&newCfg); err == nil {
+		t.Error("Expected an error for an invalid log level")
+	}
+	if manager.config.GitHub.Branch != "" {
+		t.Error("A rejected reload should not change the running configuration")
+	}
+}
+
+func TestResolveLimits(t *testing.T) {
+	global := config.ServerConfig{MemoryLimit: "1G", CPULimit: "2", PidsLimit: 256}
+
+	limits, err := resolveLimits(global, &config.MinecraftServerConfig{})
+	if err != nil {
+		t.Fatalf("resolveLimits failed: %v", err)
+	}
+	if limits.MemoryBytes != 1<<30 || limits.CPUQuota != 200000 || limits.Pids != 256 {
+		t.Errorf("Unexpected global limits: %+v", limits)
+	}
+
+	// Per-server values override the global defaults
+	limits, err = resolveLimits(global, &config.MinecraftServerConfig{MemoryLimit: "1536Mi", CPULimit: "0.5", PidsLimit: 64})
+	if err != nil {
+		t.Fatalf("resolveLimits failed: %v", err)
+	}
+	if limits.MemoryBytes != 1536<<20 || limits.CPUQuota != 50000 || limits.Pids != 64 {
+		t.Errorf("Unexpected per-server limits: %+v", limits)
+	}
+
+	if _, err := resolveLimits(config.ServerConfig{MemoryLimit: "lots"}, &config.MinecraftServerConfig{}); err == nil {
+		t.Error("Expected an error for an invalid memory limit")
+	}
+}
+
+func TestWithinOpenHours(t *testing.T) {
+	serverConfig := &config.MinecraftServerConfig{
+		Schedule: config.ScheduleConfig{
+			Timezone: "Europe/London",
+			OpenHours: []config.OpenHoursWindow{
+				{Days: []string{"mon", "tue", "wed", "thu", "fri"}, From: "15:30", To: "19:00"},
+				{Days: []string{"sat"}, From: "22:00", To: "01:00"},
+			},
+		},
+	}
+
+	london, err := time.LoadLocation("Europe/London")
+	if err != nil {
+		t.Skipf("Timezone data unavailable: %v", err)
+	}
+
+	cases := []struct {
+		time time.Time
+		open bool
+	}{
+		{time.Date(2026, 10, 19, 16, 0, 0, 0, london), true},   // Monday after school
+		{time.Date(2026, 10, 19, 12, 0, 0, 0, london), false},  // Monday during school
+		{time.Date(2026, 10, 19, 19, 0, 0, 0, london), false},  // Window end is exclusive
+		{time.Date(2026, 10, 24, 23, 0, 0, 0, london), true},   // Saturday night
+		{time.Date(2026, 10, 25, 0, 30, 0, 0, london), true},   // Saturday window continuing into Sunday
+		{time.Date(2026, 10, 25, 16, 0, 0, 0, london), false},  // Sunday afternoon
+		{time.Date(2026, 10, 19, 15, 0, 0, 0, time.UTC), true}, // 16:00 in London
+	}
+
+	for _, c := range cases {
+		open, err := withinOpenHours(serverConfig, c.time)
+		if err != nil {
+			t.Fatalf("withinOpenHours failed: %v", err)
+		}
+		if open != c.open {
+			t.Errorf("withinOpenHours(%s) = %v, want %v", c.time, open, c.open)
+		}
+	}
+}
+
+func TestScheduleIsAllOrNothing(t *testing.T) {
+	manager := NewManager(&config.Config{}, logrus.New())
+	valid := config.MinecraftServerConfig{Name: "survival", Schedule: config.ScheduleConfig{Restarts: []string{"0 4 * * *"}}}
+	invalid := config.MinecraftServerConfig{Name: "creative", Schedule: config.ScheduleConfig{
+		Restarts: []string{"0 4 * * *"},
+		Commands: []config.ScheduledCommand{{Cron: "not a cron", Command: "say hi"}},
+	}}
+	manager.rescheduleLocked(&config.RepoConfig{Servers: []config.MinecraftServerConfig{valid, invalid}})
+
+	if entries := manager.scheduler.Entries(); len(entries) != 1 {
+		t.Errorf("Expected only the valid server's restart to be scheduled, got %d jobs", len(entries))
+	}
+}
+
+func TestParseSaveQuery(t *testing.T) {
+	files, err := parseSaveQuery("Bedrock level/db/000005.ldb:1234, Bedrock level/level.dat:2048")
+	if err != nil {
+		t.Fatalf("parseSaveQuery failed: %v", err)
+	}
+	if len(files) != 2 {
+		t.Fatalf("Expected 2 files, got %d", len(files))
+	}
+	if files[0].Path != "Bedrock level/db/000005.ldb" || files[0].Size != 1234 {
+		t.Errorf("Unexpected first file: %+v", files[0])
+	}
+	if files[1].Path != "Bedrock level/level.dat" || files[1].Size != 2048 {
+		t.Errorf("Unexpected second file: %+v", files[1])
+	}
+
+	if _, err := parseSaveQuery("level.dat"); err == nil {
+		t.Error("Expected an error for an entry without a length")
+	}
+}
+
+func TestWaitForSaveQuerySkipsOtherOutput(t *testing.T) {
+	server := &MinecraftServer{Config: &config.MinecraftServerConfig{Name: "survival"}, stdin: nopWriteCloser{io.Discard}}
+	lines := make(chan string, 4)
+	lines <- "[2024-01-01 12:00:00:000 INFO] " + saveReadyMessage
+	lines <- "[2024-01-01 12:00:00:100 INFO] Player connected: Steve, xuid: 2535412345678901"
+	lines <- "Bedrock level/db/000005.ldb:1234, Bedrock level/level.dat:2048"
+
+	files, err := waitForSaveQuery(server, lines, time.Second)
+	if err != nil || len(files) != 2 || files[1].Path != "Bedrock level/level.dat" {
+		t.Errorf("Expected the file list after the join, got %+v (%v)", files, err)
+	}
+}
+
+func TestVersionDetection(t *testing.T) {
+	version, ok := parseVersionLine("[2024-01-01 12:00:00:000 INFO] Version: 1.20.51.01")
+	if !ok || version != "1.20.51.01" {
+		t.Errorf("Expected version 1.20.51.01, got %q (%v)", version, ok)
+	}
+	if _, ok := parseVersionLine("[2024-01-01 12:00:00:000 INFO] Server started."); ok {
+		t.Error("Expected no version in an unrelated line")
+	}
+
+	cases := []struct {
+		configured, detected string
+		match                bool
+	}{
+		{"1.20.50", "1.20.50.03", true},
+		{"1.20.50", "1.20.50", true},
+		{"1.20.50", "1.20.51.01", false},
+		{"1.20.5", "1.20.50.03", false},
+		{"", "1.20.50.03", true},
+	}
+	for _, c := range cases {
+		if got := versionMatches(c.configured, c.detected); got != c.match {
+			t.Errorf("versionMatches(%q, %q) = %v, want %v", c.configured, c.detected, got, c.match)
+		}
+	}
+
+	if compareVersions("1.20.100", "1.20.99") <= 0 {
+		t.Error("Expected versions to compare numerically")
+	}
+}
+
+func TestPlayerTracking(t *testing.T) {
+	manager := NewManager(&config.Config{}, logrus.New())
+	server := &MinecraftServer{Config: &config.MinecraftServerConfig{Name: "survival"}, Status: "starting"}
+
+	for _, line := range []string{
+		"[2024-01-01 12:00:00:000 INFO] Player connected: Steve, xuid: 2535412345678901",
+		"[2024-01-01 12:00:01:000 INFO] Player connected: Alex Smith, xuid: 2535498765432109",
+		"[2024-01-01 12:00:02:000 INFO] Server started.",
+		"[2024-01-01 12:05:00:000 INFO] Player disconnected: Steve, xuid: 2535412345678901, pfid: abc",
+	} {
+		manager.trackPlayerLocked(server, line)
+	}
+
+	players := server.onlinePlayers()
+	if len(players) != 1 || players[0].Name != "Alex Smith" || players[0].XUID != "2535498765432109" {
+		t.Errorf("Expected only Alex Smith online, got %+v", players)
+	}
+
+	// Nobody stays online on a crashed server, even from lines read after the exit
+	manager.serverExitedLocked("survival", server, errors.New("exit status 1"))
+	manager.trackPlayerLocked(server, "[2024-01-01 12:06:00:000 INFO] Player connected: Steve, xuid: 2535412345678901")
+	if players := server.onlinePlayers(); server.Status != "crashed" || len(players) != 0 {
+		t.Errorf("Expected a crashed server with nobody online, got %s with %+v", server.Status, players)
+	}
+}
+
+type nopWriteCloser struct{ io.Writer }
+
+func (nopWriteCloser) Close() error { return nil }
+
+func TestConsoleSession(t *testing.T) {
+	baseDir := t.TempDir()
+	manager := NewManager(&config.Config{Server: config.ServerConfig{BaseDir: baseDir}}, logrus.New())
+	var stdin bytes.Buffer
+	server := &MinecraftServer{
+		Config: &config.MinecraftServerConfig{Name: "survival"},
+		Status: "running",
+		output: newConsoleOutput(100, io.Discard),
+		stdin:  nopWriteCloser{&stdin},
+	}
+	manager.servers["survival"] = server
+
+	alice, err := manager.AttachConsole("survival", "alice")
+	if err != nil {
+		t.Fatalf("AttachConsole failed: %v", err)
+	}
+	bob, _ := manager.AttachConsole("survival", "bob")
+	defer bob.Close()
+
+	// Every viewer sees a command, tagged with who sent it
+	if err := alice.Send("say hi"); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+	if stdin.String() != "say hi\n" {
+		t.Errorf("Expected the command on stdin, got %q", stdin.String())
+	}
+	for _, session := range []*ConsoleSession{alice, bob} {
+		if line := <-session.Lines; line != "[alice] > say hi" {
+			t.Errorf("Expected the tagged command, got %q", line)
+		}
+	}
+	if viewers := manager.serverStatusLocked("survival", server).ConsoleViewers; strings.Join(viewers, ",") != "alice,bob" {
+		t.Errorf("Expected alice and bob watching, got %v", viewers)
+	}
+	alice.Close()
+	alice.Close()
+	if viewers := manager.serverStatusLocked("survival", server).ConsoleViewers; strings.Join(viewers, ",") != "bob" {
+		t.Errorf("Expected only bob watching, got %v", viewers)
+	}
+
+	// Failed commands are audited too
+	bob.Send("two\nlines")
+	audit, err := os.ReadFile(filepath.Join(baseDir, "survival", "logs", auditFile))
+	if err != nil {
+		t.Fatalf("Failed to read the audit trail: %v", err)
+	}
+	entries := strings.Split(strings.TrimSpace(string(audit)), "\n")
+	if len(entries) != 2 || !strings.Contains(entries[0], `"by":"alice","command":"say hi"`) || !strings.Contains(entries[1], `"by":"bob"`) || !strings.Contains(entries[1], `"error"`) {
+		t.Errorf("Unexpected audit trail:\n%s", audit)
+	}
+
+	// Unknown names are refused before the audit trail's path is built from them
+	if err := manager.RunCommand("..", "say hi", "mallory"); !errors.Is(err, ErrUnknownServer) {
+		t.Errorf("Expected ErrUnknownServer, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(filepath.Dir(baseDir), "logs", auditFile)); !os.IsNotExist(err) {
+		t.Errorf("Expected no audit trail outside the servers directory, got %v", err)
+	}
+}
+
+func TestRestoreLeavesCrashedServerStopped(t *testing.T) {
+	baseDir := t.TempDir()
+	cfg := &config.Config{Server: config.ServerConfig{BaseDir: baseDir, BackupDir: filepath.Join(baseDir, "backups")}}
+	manager := NewManager(cfg, logrus.New())
+	serverConfig := config.MinecraftServerConfig{Name: "survival", WorldName: "world"}
+	manager.lastConfig = &config.RepoConfig{Servers: []config.MinecraftServerConfig{serverConfig}}
+
+	levelPath := filepath.Join(cfg.GetWorldsDir("survival"), "world", "level.dat")
+	os.MkdirAll(filepath.Dir(levelPath), 0755)
+	os.WriteFile(levelPath, []byte("before"), 0644)
+	info, err := manager.Backup("survival")
+	if err != nil {
+		t.Fatalf("Backup failed: %v", err)
+	}
+	os.WriteFile(levelPath, []byte("after"), 0644)
+
+	manager.servers["survival"] = &MinecraftServer{Config: &serverConfig, Status: "crashed"}
+	result, err := manager.RestoreBackup("survival", info.ID, false)
+	if err != nil {
+		t.Fatalf("RestoreBackup failed: %v", err)
+	}
+	if result.Restarted || len(manager.servers) != 0 {
+		t.Errorf("Expected the crashed server to stay down, got restarted=%v servers=%v", result.Restarted, manager.servers)
+	}
+	if data, _ := os.ReadFile(levelPath); string(data) != "before" {
+		t.Errorf("Expected the backed up world, got %q", data)
+	}
+}
+
+func TestImportLeavesCrashedServerStopped(t *testing.T) {
+	baseDir := t.TempDir()
+	cfg := &config.Config{Server: config.ServerConfig{BaseDir: baseDir}}
+	manager := NewManager(cfg, logrus.New())
+	serverConfig := config.MinecraftServerConfig{Name: "survival", WorldName: "world"}
+	manager.lastConfig = &config.RepoConfig{Servers: []config.MinecraftServerConfig{serverConfig}}
+
+	// A minimal Bedrock level.dat: header plus an empty compound tag
+	worldDir := filepath.Join(cfg.GetWorldsDir("survival"), "world")
+	os.MkdirAll(worldDir, 0755)
+	os.WriteFile(filepath.Join(worldDir, "level.dat"), []byte{10, 0, 0, 0, 4, 0, 0, 0, 0x0a, 0, 0, 0}, 0644)
+	namePath := filepath.Join(worldDir, "levelname.txt")
+	os.WriteFile(namePath, []byte("Imported"), 0644)
+	var upload bytes.Buffer
+	if err := manager.ExportWorld("survival", &upload); err != nil {
+		t.Fatalf("ExportWorld failed: %v", err)
+	}
+	os.WriteFile(namePath, []byte("Original"), 0644)
+
+	manager.servers["survival"] = &MinecraftServer{Config: &serverConfig, Status: "crashed"}
+	result, err := manager.ImportWorld("survival", &upload)
+	if err != nil {
+		t.Fatalf("ImportWorld failed: %v", err)
+	}
+	if result.Restarted || len(manager.servers) != 0 {
+		t.Errorf("Expected the crashed server to stay down, got restarted=%v servers=%v", result.Restarted, manager.servers)
+	}
+	if data, _ := os.ReadFile(namePath); string(data) != "Imported" {
+		t.Errorf("Expected the imported world, got %q", data)
+	}
+}
+
+func TestValidServerName(t *testing.T) {
+	for name, want := range map[string]bool{"survival": true, "creative-2": true, "": false, "..": false, "../etc": false, "a/b": false, `a\b`: false} {
+		if got := validServerName(name); got != want {
+			t.Errorf("validServerName(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestOverrides(t *testing.T) {
+	logger := logrus.New()
+	manager := NewManager(&config.Config{Server: config.ServerConfig{BaseDir: t.TempDir()}}, logger)
+	repoConfig := &config.RepoConfig{Servers: []config.MinecraftServerConfig{{Name: "survival"}, {Name: "creative"}}}
+	manager.lastConfig = repoConfig
+
+	if selected := manager.selectServerLocked(repoConfig); selected != 0 {
+		t.Errorf("Expected the first server without overrides, got %d", selected)
+	}
+
+	// An admin stop survives the next poll, and so does an admin start elsewhere
+	op, err := manager.StopServer("survival", "admin")
+	if err != nil {
+		t.Fatalf("StopServer failed: %v", err)
+	}
+	waitForOperation(t, manager, op.ID)
+	manager.setOverrideLocked("creative", OverrideStarted, "admin")
+	if selected := manager.selectServerLocked(repoConfig); selected != 1 {
+		t.Errorf("Expected the server started by an admin, got %d", selected)
+	}
+
+	// Overrides are persisted across manager restarts
+	manager.saveOverridesLocked()
+	restarted := NewManager(manager.config, logger)
+	restarted.loadOverrides()
+	if override := restarted.overrideLocked("survival"); override == nil || override.State != OverrideStopped || override.By != "admin" {
+		t.Errorf("Expected the saved stop override, got %+v", override)
+	}
+
+	if _, err := manager.StartServer("unknown", "admin"); err != ErrUnknownServer {
+		t.Errorf("Expected ErrUnknownServer, got %v", err)
+	}
+}
+
+func TestOperationLog(t *testing.T) {
+	log := newOperationLog()
+	op, err := log.begin("survival", "restart", "admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := log.begin("survival", "stop", "admin"); err != ErrBusy {
+		t.Errorf("Expected ErrBusy while an operation runs, got %v", err)
+	}
+
+	log.finish(op.ID, errStartFailed)
+	got, err := log.get(op.ID)
+	if err != nil || got.Status != OperationFailed || got.Error == "" || got.Finished == nil {
+		t.Errorf("Expected a failed operation, got %+v (%v)", got, err)
+	}
+	if _, err := log.begin("survival", "stop", "admin"); err != nil {
+		t.Errorf("Expected a new operation once the last finished, got %v", err)
+	}
+}
+
+func waitForOperation(t *testing.T, manager *Manager, id string) *Operation {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		op, err := manager.Operation(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if op.Status != OperationRunning {
+			return op
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("Operation %s did not finish", id)
+	return nil
+}
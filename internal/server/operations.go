@@ -0,0 +1,121 @@
+package server
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"sync"
+	"time"
+)
+
+// maxOperations is how many finished operations are kept for lookup
+const maxOperations = 100
+
+// Operation states
+const (
+	OperationRunning   = "running"
+	OperationSucceeded = "succeeded"
+	OperationFailed    = "failed"
+)
+
+var (
+	// ErrUnknownOperation is returned for an operation ID that was never issued or has expired
+	ErrUnknownOperation = errors.New("unknown operation")
+
+	// ErrBusy is returned when another lifecycle operation on the server is still running
+	ErrBusy = errors.New("another operation on this server is in progress")
+)
+
+// Operation tracks a lifecycle action that runs after the request returns
+type Operation struct {
+	ID       string     `json:"id"`
+	Server   string     `json:"server"`
+	Action   string     `json:"action"`
+	By       string     `json:"by,omitempty"`
+	Status   string     `json:"status"`
+	Error    string     `json:"error,omitempty"`
+	Started  time.Time  `json:"started"`
+	Finished *time.Time `json:"finished,omitempty"`
+}
+
+// operationLog keeps recent operations. It has its own lock so operations can be
+// looked up while a lifecycle action holds m.mu.
+type operationLog struct {
+	mu    sync.Mutex
+	byID  map[string]*Operation
+	order []string
+}
+
+func newOperationLog() *operationLog {
+	return &operationLog{byID: make(map[string]*Operation)}
+}
+
+// begin records a running operation, refusing a second one on the same server
+func (l *operationLog) begin(server, action, by string) (*Operation, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, op := range l.byID {
+		if op.Server == server && op.Status == OperationRunning {
+			return nil, ErrBusy
+		}
+	}
+
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+	op := &Operation{
+		ID:      hex.EncodeToString(id),
+		Server:  server,
+		Action:  action,
+		By:      by,
+		Status:  OperationRunning,
+		Started: time.Now(),
+	}
+	l.byID[op.ID] = op
+	l.order = append(l.order, op.ID)
+
+	// Forget the oldest finished operations
+	for len(l.order) > maxOperations {
+		oldest := l.byID[l.order[0]]
+		if oldest != nil && oldest.Status == OperationRunning {
+			break
+		}
+		delete(l.byID, l.order[0])
+		l.order = l.order[1:]
+	}
+
+	copied := *op
+	return &copied, nil
+}
+
+// finish marks an operation done, failed when err is not nil
+func (l *operationLog) finish(id string, err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	op, ok := l.byID[id]
+	if !ok {
+		return
+	}
+	now := time.Now()
+	op.Finished = &now
+	op.Status = OperationSucceeded
+	if err != nil {
+		op.Status = OperationFailed
+		op.Error = err.Error()
+	}
+}
+
+func (l *operationLog) get(id string) (*Operation, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	op, ok := l.byID[id]
+	if !ok {
+		return nil, ErrUnknownOperation
+	}
+	copied := *op
+	return &copied, nil
+}
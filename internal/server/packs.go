@@ -0,0 +1,31 @@
+package server
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"minecraft-server-manager/internal/config"
+	"minecraft-server-manager/internal/packs"
+)
+
+// applyPacks installs the server's configured behavior and resource packs into
+// its world and enables them
+func (m *Manager) applyPacks(serverConfig *config.MinecraftServerConfig) error {
+	worldDir := filepath.Join(m.config.GetWorldsDir(serverConfig.Name), serverConfig.WorldName)
+
+	for _, kind := range []packs.Kind{packs.Behavior, packs.Resource} {
+		refs := serverConfig.BehaviorPacks
+		if kind == packs.Resource {
+			refs = serverConfig.ResourcePacks
+		}
+		installed, err := packs.Apply(m.config.Server.AssetsDir, worldDir, kind, refs)
+		if err != nil {
+			return fmt.Errorf("failed to apply %s packs: %w", kind, err)
+		}
+		for _, pack := range installed {
+			m.logger.Infof("Enabled %s pack %s (%s %d.%d.%d) for %s", kind, pack.Name, pack.UUID,
+				pack.Version[0], pack.Version[1], pack.Version[2], serverConfig.Name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package server
+
+import (
+	"regexp"
+	"sort"
+	"time"
+)
+
+// playerLine matches Bedrock's "Player connected: Steve, xuid: 2535..." and the
+// matching "Player disconnected" line
+var playerLine = regexp.MustCompile(`Player (connected|disconnected): (.+?), xuid: ?(\d*)`)
+
+// Player is someone connected to a server
+type Player struct {
+	Name   string    `json:"name"`
+	XUID   string    `json:"xuid,omitempty"`
+	Joined time.Time `json:"joined"`
+}
+
+// parsePlayerLine returns the player a connect or disconnect line is about
+func parsePlayerLine(line string) (player Player, connected bool, ok bool) {
+	match := playerLine.FindStringSubmatch(line)
+	if match == nil {
+		return Player{}, false, false
+	}
+	return Player{Name: match[2], XUID: match[3]}, match[1] == "connected", true
+}
+
+// trackPlayerLocked updates who is online from a console line. Must be called
+// with m.mu held.
+func (m *Manager) trackPlayerLocked(server *MinecraftServer, line string) {
+	player, connected, ok := parsePlayerLine(line)
+	// Lines still buffered when the process exits must not bring players back
+	if !ok || !server.isRunning() {
+		return
+	}
+	if server.players == nil {
+		server.players = make(map[string]Player)
+	}
+	if connected {
+		player.Joined = time.Now()
+		server.players[player.Name] = player
+		m.logger.Infof("%s joined %s (%d online)", player.Name, server.Config.Name, len(server.players))
+	} else {
+		delete(server.players, player.Name)
+		m.logger.Infof("%s left %s (%d online)", player.Name, server.Config.Name, len(server.players))
+	}
+}
+
+// onlinePlayers returns a server's players in the order they joined
+func (server *MinecraftServer) onlinePlayers() []Player {
+	players := make([]Player, 0, len(server.players))
+	for _, player := range server.players {
+		players = append(players, player)
+	}
+	sort.Slice(players, func(i, j int) bool {
+		return players[i].Joined.Before(players[j].Joined)
+	})
+	return players
+}
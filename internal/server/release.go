@@ -0,0 +1,104 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"minecraft-server-manager/internal/preserve"
+)
+
+const (
+	extractedDir = "bedrock-server-extracted"
+
+	// previousExtractedDir holds the last extraction while a new one is prepared
+	previousExtractedDir = extractedDir + ".previous"
+)
+
+// ErrNoUpgradeReport is returned when the Bedrock directory was never upgraded
+var ErrNoUpgradeReport = errors.New("no upgrade report")
+
+// setAsidePreviousRelease moves the last extraction out of the way and returns
+// where it went, or "" on a first extraction. A copy left by an interrupted start
+// is still the operator's, so it wins over a half-prepared extraction.
+func (m *Manager) setAsidePreviousRelease() (string, error) {
+	if _, err := os.Stat(previousExtractedDir); err == nil {
+		m.logger.Warnf("Found %s from an interrupted start, merging from it", previousExtractedDir)
+		if err := os.RemoveAll(extractedDir); err != nil {
+			return "", fmt.Errorf("failed to remove extracted directory: %w", err)
+		}
+		return previousExtractedDir, nil
+	}
+
+	if _, err := os.Stat(extractedDir); os.IsNotExist(err) {
+		return "", nil
+	}
+	if err := os.Rename(extractedDir, previousExtractedDir); err != nil {
+		return "", fmt.Errorf("failed to move previous extracted directory aside: %w", err)
+	}
+	return previousExtractedDir, nil
+}
+
+// preserveOperatorFiles records the freshly extracted release and carries over the
+// files operators manage from the previous extraction, logging what changed
+func (m *Manager) preserveOperatorFiles(archiveHash, previousDir string) error {
+	if err := preserve.Snapshot(extractedDir, archiveHash); err != nil {
+		return fmt.Errorf("failed to record Bedrock release files: %w", err)
+	}
+	if previousDir == "" {
+		return nil
+	}
+
+	report, err := preserve.Merge(previousDir, extractedDir)
+	if err != nil {
+		return fmt.Errorf("failed to carry over operator files (previous files kept in %s): %w", previousDir, err)
+	}
+
+	if report.Upgraded() {
+		m.logReport(report)
+		if err := preserve.WriteReport(extractedDir, report); err != nil {
+			m.logger.Warnf("Failed to save upgrade report: %v", err)
+		}
+	} else if last, err := preserve.ReadReport(previousDir); err == nil {
+		// Same release re-extracted; the last upgrade is still the one to report
+		if err := preserve.WriteReport(extractedDir, last); err != nil {
+			m.logger.Warnf("Failed to save upgrade report: %v", err)
+		}
+	}
+
+	if err := os.RemoveAll(previousDir); err != nil {
+		m.logger.Warnf("Failed to remove %s: %v", previousDir, err)
+	}
+	return nil
+}
+
+func (m *Manager) logReport(report *preserve.Report) {
+	m.logger.Infof("Bedrock release changed: %d files added, %d removed, %d changed",
+		len(report.VendorAdded), len(report.VendorRemoved), len(report.VendorChanged))
+	for _, change := range report.Files {
+		if change.Action == preserve.FileConflict {
+			m.logger.Warnf("Kept %s, which the new release also changed", change.Path)
+		} else {
+			m.logger.Infof("Operator file %s: %s", change.Path, change.Action)
+		}
+	}
+	for _, change := range report.Properties {
+		if change.Action == preserve.PropertyConflict {
+			m.logger.Warnf("Kept %s=%s in %s, the new release's default is %q", change.Key, change.Value, preserve.PropertiesFile, change.Vendor)
+		} else {
+			m.logger.Infof("Property %s: %s", change.Key, change.Action)
+		}
+	}
+}
+
+// UpgradeReport returns what changed in the last upgrade of the extracted release
+func (m *Manager) UpgradeReport() (*preserve.Report, error) {
+	report, err := preserve.ReadReport(extractedDir)
+	if os.IsNotExist(err) {
+		return nil, ErrNoUpgradeReport
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read upgrade report: %w", err)
+	}
+	return report, nil
+}
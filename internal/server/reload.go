@@ -0,0 +1,123 @@
+package server
+
+import (
+	"fmt"
+	"strings"
+
+	"minecraft-server-manager/internal/config"
+	"minecraft-server-manager/internal/github"
+
+	"github.com/sirupsen/logrus"
+)
+
+// liveSettings can be changed without restarting the manager. The http keys are
+// re-read by the API's authenticator rather than the manager.
+var liveSettings = map[string]bool{
+	"environment":          true,
+	"github.branch":        true,
+	"github.overlay_dir":   true,
+	"github.config_path":   true,
+	"github.poll_interval": true,
+	"github.token":         true,
+	"github.allowed_env":   true,
+	"github.allowed_files": true,
+	"log.level":            true,
+	"log.format":           true,
+	"http.tokens_file":     true,
+	"http.allow_anonymous": true,
+	"http.tls.client_role": true,
+}
+
+// ReloadReport describes the outcome of a configuration reload
+type ReloadReport struct {
+	Applied         []string `json:"applied"`
+	RestartRequired []string `json:"restart_required"`
+}
+
+// Reload applies the live settings from newCfg to the running manager. Settings
+// that need a restart keep their current value and are listed in the report.
+func (m *Manager) Reload(newCfg *config.Config) (ReloadReport, error) {
+	report := ReloadReport{Applied: []string{}, RestartRequired: []string{}}
+
+	// Validate before touching anything so a bad file leaves the manager unchanged
+	if err := ConfigureLogger(logrus.New(), newCfg.Log); err != nil {
+		return report, err
+	}
+
+	m.mu.Lock()
+	for _, key := range config.Diff(m.config, newCfg) {
+		if liveSettings[key] {
+			report.Applied = append(report.Applied, key)
+		} else {
+			report.RestartRequired = append(report.RestartRequired, key)
+		}
+	}
+
+	updated := *m.config
+	config.CopyFields(&updated, newCfg, report.Applied)
+	m.config = &updated
+	m.mu.Unlock()
+
+	if err := ConfigureLogger(m.logger, updated.Log); err != nil {
+		return report, err
+	}
+
+	if len(report.Applied) > 0 {
+		m.logger.Infof("Configuration reloaded, applied: %s", strings.Join(report.Applied, ", "))
+
+		// Let the polling loop pick up the new GitHub settings
+		select {
+		case m.reloadCh <- struct{}{}:
+		default:
+		}
+	}
+	if len(report.RestartRequired) > 0 {
+		m.logger.Warnf("Configuration changes require a restart: %s", strings.Join(report.RestartRequired, ", "))
+	}
+
+	return report, nil
+}
+
+// configSnapshot returns a copy of the running configuration
+func (m *Manager) configSnapshot() config.Config {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return *m.config
+}
+
+// applyGitHubSettings pushes GitHub settings to the client. It reports whether a
+// different configuration source was selected compared to previous.
+func applyGitHubSettings(githubClient *github.Client, previous, current config.Config) bool {
+	githubClient.SetBranch(current.GitHub.Branch)
+	githubClient.SetConfigPath(current.GitHub.ConfigPath)
+	githubClient.SetEnvironment(current.Environment, current.GitHub.OverlayDir)
+	githubClient.SetAllowedReferences(current.GitHub.AllowedEnv, current.GitHub.AllowedFiles)
+	if previous.GitHub.Token != current.GitHub.Token {
+		githubClient.SetToken(current.GitHub.Token)
+	}
+
+	return previous.GitHub.Branch != current.GitHub.Branch ||
+		previous.GitHub.ConfigPath != current.GitHub.ConfigPath ||
+		previous.GitHub.OverlayDir != current.GitHub.OverlayDir ||
+		previous.Environment != current.Environment
+}
+
+// ConfigureLogger applies the log level and format to logger
+func ConfigureLogger(logger *logrus.Logger, cfg config.LogConfig) error {
+	level, err := logrus.ParseLevel(cfg.Level)
+	if err != nil {
+		return fmt.Errorf("invalid log level %q", cfg.Level)
+	}
+
+	switch cfg.Format {
+	case "", "text":
+		logger.SetFormatter(&logrus.TextFormatter{FullTimestamp: true})
+	case "json":
+		logger.SetFormatter(&logrus.JSONFormatter{})
+	default:
+		return fmt.Errorf("invalid log format %q", cfg.Format)
+	}
+
+	logger.SetLevel(level)
+	return nil
+}
@@ -0,0 +1,272 @@
+package server
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"minecraft-server-manager/internal/config"
+
+	"github.com/robfig/cron/v3"
+)
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// rescheduleLocked replaces all scheduled jobs with those of repoConfig. Must be called with m.mu held.
+func (m *Manager) rescheduleLocked(repoConfig *config.RepoConfig) {
+	for _, entry := range m.scheduler.Entries() {
+		m.scheduler.Remove(entry.ID)
+	}
+
+	hasOpenHours := false
+	for i := range repoConfig.Servers {
+		serverConfig := repoConfig.Servers[i]
+		if err := m.scheduleServer(&serverConfig); err != nil {
+			m.logger.Errorf("Invalid schedule for %s, nothing scheduled: %v", serverConfig.Name, err)
+			continue
+		}
+		if len(serverConfig.Schedule.OpenHours) > 0 {
+			hasOpenHours = true
+		}
+	}
+
+	// Open hours are checked every minute rather than at fixed times, so a
+	// window that is already open when the config arrives is honoured too
+	if hasOpenHours {
+		m.scheduler.Schedule(cron.Every(time.Minute), cron.FuncJob(m.enforceOpenHours))
+	}
+}
+
+// scheduleServer registers a server's scheduled jobs. Every entry is validated
+// first, so an invalid one leaves the server with no jobs rather than some.
+func (m *Manager) scheduleServer(serverConfig *config.MinecraftServerConfig) error {
+	schedule := serverConfig.Schedule
+	name := serverConfig.Name
+
+	if _, err := scheduleLocation(schedule); err != nil {
+		return err
+	}
+	for _, window := range schedule.OpenHours {
+		if _, _, err := parseWindow(window); err != nil {
+			return err
+		}
+	}
+
+	type job struct {
+		schedule cron.Schedule
+		run      func()
+	}
+	var jobs []job
+
+	for _, spec := range schedule.Restarts {
+		parsed, err := parseCron(schedule, spec)
+		if err != nil {
+			return err
+		}
+		jobs = append(jobs, job{parsed, func() {
+			m.logger.Infof("Scheduled restart of %s", name)
+			if err := m.restartServer(name); err != nil {
+				m.logger.Warnf("Scheduled restart of %s skipped: %v", name, err)
+			}
+		}})
+	}
+
+	for _, command := range schedule.Commands {
+		parsed, err := parseCron(schedule, command.Cron)
+		if err != nil {
+			return err
+		}
+		line := command.Command
+		jobs = append(jobs, job{parsed, func() {
+			if err := m.SendCommand(name, line); err != nil {
+				m.logger.Debugf("Scheduled command for %s skipped: %v", name, err)
+				return
+			}
+			m.logger.Infof("Sent scheduled command to %s: %s", name, line)
+		}})
+	}
+
+	for _, j := range jobs {
+		m.scheduler.Schedule(j.schedule, cron.FuncJob(j.run))
+	}
+	return nil
+}
+
+// restartServer stops and starts a running server with its current configuration
+func (m *Manager) restartServer(name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// A crashed or stopped server stays down until someone looks at it
+	server, exists := m.servers[name]
+	if !exists || !server.isRunning() {
+		return fmt.Errorf("server %s is not running", name)
+	}
+
+	serverConfig := server.Config
+	m.stopServer(name)
+	m.startServer(serverConfig)
+	return nil
+}
+
+// enforceOpenHours starts or stops the active server as its open hours begin and end
+func (m *Manager) enforceOpenHours() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.lastConfig == nil || len(m.lastConfig.Servers) == 0 {
+		return
+	}
+
+	serverConfig := m.lastConfig.Servers[m.selectServerLocked(m.lastConfig)]
+	if len(serverConfig.Schedule.OpenHours) == 0 {
+		return
+	}
+	// Servers started or stopped by an admin stay that way
+	if m.overrideLocked(serverConfig.Name) != nil {
+		return
+	}
+
+	open, err := withinOpenHours(&serverConfig, time.Now())
+	if err != nil {
+		m.logger.Errorf("Invalid open hours for %s: %v", serverConfig.Name, err)
+		return
+	}
+
+	server, running := m.servers[serverConfig.Name]
+	if running && !server.isRunning() {
+		// A crashed or stopped server stays down until someone looks at it
+		return
+	}
+	switch {
+	case open && !running:
+		m.logger.Infof("Open hours started for %s, starting server", serverConfig.Name)
+		m.startServer(&serverConfig)
+	case !open && running:
+		m.logger.Infof("Open hours ended for %s, stopping server", serverConfig.Name)
+		m.stopServer(serverConfig.Name)
+	}
+}
+
+// withinOpenHours reports whether a server may run at the given time. A server
+// without open hours may always run.
+func withinOpenHours(serverConfig *config.MinecraftServerConfig, now time.Time) (bool, error) {
+	schedule := serverConfig.Schedule
+	if len(schedule.OpenHours) == 0 {
+		return true, nil
+	}
+
+	loc, err := scheduleLocation(schedule)
+	if err != nil {
+		return false, err
+	}
+	now = now.In(loc)
+	minute := now.Hour()*60 + now.Minute()
+	yesterday := now.AddDate(0, 0, -1).Weekday()
+
+	for _, window := range schedule.OpenHours {
+		from, to, err := parseWindow(window)
+		if err != nil {
+			return false, err
+		}
+
+		if from < to {
+			if onDay(window, now.Weekday()) && minute >= from && minute < to {
+				return true, nil
+			}
+			continue
+		}
+
+		// The window crosses midnight and belongs to the day it starts on
+		if onDay(window, now.Weekday()) && minute >= from {
+			return true, nil
+		}
+		if onDay(window, yesterday) && minute < to {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func onDay(window config.OpenHoursWindow, day time.Weekday) bool {
+	if len(window.Days) == 0 {
+		return true
+	}
+	for _, name := range window.Days {
+		if weekdays[strings.ToLower(name)] == day {
+			return true
+		}
+	}
+	return false
+}
+
+// parseWindow returns the window's start and end as minutes since midnight
+func parseWindow(window config.OpenHoursWindow) (int, int, error) {
+	for _, day := range window.Days {
+		if _, ok := weekdays[strings.ToLower(day)]; !ok {
+			return 0, 0, fmt.Errorf("unknown day %q", day)
+		}
+	}
+
+	from, err := parseClock(window.From)
+	if err != nil {
+		return 0, 0, err
+	}
+	to, err := parseClock(window.To)
+	if err != nil {
+		return 0, 0, err
+	}
+	if from == to {
+		return 0, 0, fmt.Errorf("open hours window %s-%s is empty", window.From, window.To)
+	}
+	return from, to, nil
+}
+
+func parseClock(value string) (int, error) {
+	parts := strings.Split(value, ":")
+	if len(parts) != 2 {
+		return 0, fmt.Errorf("invalid time %q, expected HH:MM", value)
+	}
+	hour, err := strconv.Atoi(parts[0])
+	if err != nil || hour < 0 || hour > 24 {
+		return 0, fmt.Errorf("invalid time %q, expected HH:MM", value)
+	}
+	minute, err := strconv.Atoi(parts[1])
+	if err != nil || minute < 0 || minute > 59 || (hour == 24 && minute != 0) {
+		return 0, fmt.Errorf("invalid time %q, expected HH:MM", value)
+	}
+	return hour*60 + minute, nil
+}
+
+func scheduleLocation(schedule config.ScheduleConfig) (*time.Location, error) {
+	if schedule.Timezone == "" {
+		return time.Local, nil
+	}
+	loc, err := time.LoadLocation(schedule.Timezone)
+	if err != nil {
+		return nil, fmt.Errorf("unknown timezone %q", schedule.Timezone)
+	}
+	return loc, nil
+}
+
+// parseCron parses a standard five-field expression in the schedule's timezone
+func parseCron(schedule config.ScheduleConfig, spec string) (cron.Schedule, error) {
+	if schedule.Timezone != "" {
+		spec = "CRON_TZ=" + schedule.Timezone + " " + spec
+	}
+	parsed, err := cron.ParseStandard(spec)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cron expression %q: %w", spec, err)
+	}
+	return parsed, nil
+}
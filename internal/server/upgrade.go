@@ -0,0 +1,122 @@
+package server
+
+import (
+	"context"
+	"os/exec"
+	"time"
+
+	"minecraft-server-manager/internal/backup"
+	"minecraft-server-manager/internal/config"
+	"minecraft-server-manager/internal/installer"
+)
+
+// installVersions downloads the Bedrock versions used by a configuration that are
+// not installed yet. It runs before servers are switched, so a failed download
+// leaves the affected servers on the bedrock_path fallback rather than half-way.
+func (m *Manager) installVersions(repoConfig *config.RepoConfig) {
+	cfg := m.configSnapshot()
+	if cfg.Server.BedrockMirror == "" {
+		return
+	}
+
+	inst := installer.New(cfg.Server.BedrockMirror, cfg.Server.VersionsDir, m.logger)
+	seen := make(map[string]bool)
+	for _, serverConfig := range repoConfig.Servers {
+		version := serverConfig.Version
+		if version == "" || seen[version] || inst.Installed(version) {
+			continue
+		}
+		seen[version] = true
+
+		if err := inst.Install(context.Background(), version, repoConfig.BedrockVersions[version]); err != nil {
+			m.logger.Errorf("Failed to install Bedrock %s for %s: %v", version, serverConfig.Name, err)
+		}
+	}
+}
+
+// watchUpgrade waits for a server that was just switched to a new version to
+// report it has started. If it crashes or times out, the world is restored from
+// the pre-upgrade backup and the server is started on its previous version.
+func (m *Manager) watchUpgrade(name string, cmd *exec.Cmd, previous config.MinecraftServerConfig, saved *backup.Info, timeout time.Duration) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	deadline := time.After(timeout)
+
+	reason := ""
+	for reason == "" {
+		select {
+		case <-ticker.C:
+		case <-deadline:
+			reason = "did not become ready within " + timeout.String()
+			continue
+		}
+
+		m.mu.RLock()
+		server, exists := m.servers[name]
+		replaced := !exists || server.Process != cmd
+		status := ""
+		if !replaced {
+			status = server.Status
+		}
+		m.mu.RUnlock()
+
+		switch {
+		case replaced:
+			// Stopped or restarted by someone else, which settles the upgrade
+			return
+		case status == "running":
+			m.logger.Infof("Server %s is running on Bedrock %s", name, server.Config.Version)
+			return
+		case status == "crashed" || status == "stopped":
+			reason = status
+		}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	server, exists := m.servers[name]
+	if !exists || server.Process != cmd {
+		return
+	}
+	failed := server.Config.Version
+	m.logger.Errorf("Server %s %s on Bedrock %s, rolling back to %s", name, reason, failed, versionName(previous.Version))
+	m.stopServer(name)
+
+	if saved != nil {
+		cfg := m.config
+		store := backup.NewStore(cfg.Server.BackupDir)
+		m.backupMu.Lock()
+		m.restoring[name] = true
+		m.mu.Unlock()
+
+		// Extract without the manager lock so status stays available meanwhile
+		aside, err := store.Restore(saved, cfg.GetWorldsDir(name), previous.WorldName)
+		m.backupMu.Unlock()
+
+		m.mu.Lock()
+		delete(m.restoring, name)
+		if err != nil {
+			// Starting the old version on a world the new one may have upgraded is worse than staying down
+			m.logger.Errorf("Failed to restore %s from backup %s, not restarting: %v", name, saved.ID, err)
+			return
+		}
+		m.logger.Infof("Restored %s from backup %s", name, saved.ID)
+		if aside != "" {
+			m.logger.Infof("The world from Bedrock %s was kept at %s", failed, aside)
+		}
+	}
+
+	m.startServer(&previous)
+	if server, exists := m.servers[name]; exists {
+		server.RolledBackFrom = failed
+	}
+}
+
+// versionName describes a configured version, where empty means bedrock_path
+func versionName(version string) string {
+	if version == "" {
+		return "bedrock_path"
+	}
+	return version
+}
@@ -0,0 +1,169 @@
+package server
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"minecraft-server-manager/internal/installer"
+)
+
+// versionLine matches the version Bedrock logs at startup, e.g.
+// "[2024-01-01 12:00:00:000 INFO] Version: 1.20.51.01"
+var versionLine = regexp.MustCompile(`\bVersion:?\s+([0-9]+(?:\.[0-9]+)+)`)
+
+// VersionInfo describes a Bedrock version that is installed or configured
+type VersionInfo struct {
+	Version   string   `json:"version"`
+	Path      string   `json:"path,omitempty"`
+	Installed bool     `json:"installed"`
+	Servers   []string `json:"servers"` // Configured to use this version
+	Running   []string `json:"running"` // Currently running this version's binary
+}
+
+// resolveBedrockPath returns the absolute path of the binary for a version. Servers
+// without a version, or whose version is not installed, fall back to bedrock_path.
+func (m *Manager) resolveBedrockPath(version string) (string, error) {
+	if version != "" {
+		if !installer.ValidVersion(version) {
+			return "", fmt.Errorf("invalid Bedrock version %q", version)
+		}
+		path, err := filepath.Abs(filepath.Join(m.config.GetVersionDir(version), "bedrock_server"))
+		if err != nil {
+			return "", err
+		}
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		}
+		m.logger.Warnf("Bedrock %s is not installed in %s, using %s", version, m.config.Server.VersionsDir, m.bedrockPath)
+	}
+
+	if _, err := os.Stat(m.bedrockPath); err != nil {
+		return "", fmt.Errorf("Bedrock server executable not found at %s", m.bedrockPath)
+	}
+	return m.bedrockPath, nil
+}
+
+// installedVersions lists the versions in the versions store
+func (m *Manager) installedVersions() (map[string]string, error) {
+	entries, err := os.ReadDir(m.config.Server.VersionsDir)
+	if os.IsNotExist(err) {
+		return map[string]string{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read versions directory: %w", err)
+	}
+
+	installed := make(map[string]string)
+	for _, entry := range entries {
+		if !entry.IsDir() || !installer.ValidVersion(entry.Name()) {
+			continue
+		}
+		path, err := filepath.Abs(filepath.Join(m.config.GetVersionDir(entry.Name()), "bedrock_server"))
+		if err != nil {
+			continue
+		}
+		if _, err := os.Stat(path); err == nil {
+			installed[entry.Name()] = path
+		}
+	}
+	return installed, nil
+}
+
+// Versions reports every installed or configured Bedrock version and the
+// servers using it
+func (m *Manager) Versions() ([]VersionInfo, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	installed, err := m.installedVersions()
+	if err != nil {
+		return nil, err
+	}
+
+	versions := make(map[string]*VersionInfo)
+	get := func(version string) *VersionInfo {
+		if info, ok := versions[version]; ok {
+			return info
+		}
+		info := &VersionInfo{Version: version, Servers: []string{}, Running: []string{}}
+		if path, ok := installed[version]; ok {
+			info.Path = path
+			info.Installed = true
+		}
+		versions[version] = info
+		return info
+	}
+
+	for version := range installed {
+		get(version)
+	}
+	if m.lastConfig != nil {
+		for _, serverConfig := range m.lastConfig.Servers {
+			if serverConfig.Version != "" {
+				info := get(serverConfig.Version)
+				info.Servers = append(info.Servers, serverConfig.Name)
+			}
+		}
+	}
+	for name, server := range m.servers {
+		for _, info := range versions {
+			if info.Path != "" && info.Path == server.BedrockPath {
+				info.Running = append(info.Running, name)
+			}
+		}
+	}
+
+	result := make([]VersionInfo, 0, len(versions))
+	for _, info := range versions {
+		sort.Strings(info.Servers)
+		sort.Strings(info.Running)
+		result = append(result, *info)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return compareVersions(result[i].Version, result[j].Version) > 0
+	})
+	return result, nil
+}
+
+// parseVersionLine returns the version from a Bedrock startup line, if it is one
+func parseVersionLine(line string) (string, bool) {
+	match := versionLine.FindStringSubmatch(line)
+	if match == nil {
+		return "", false
+	}
+	return match[1], true
+}
+
+// versionMatches reports whether a detected version satisfies the configured one.
+// "1.20.50" is satisfied by any "1.20.50.x" build.
+func versionMatches(configured, detected string) bool {
+	if configured == "" || detected == "" {
+		return true
+	}
+	return detected == configured || strings.HasPrefix(detected, configured+".")
+}
+
+// compareVersions compares dotted versions numerically
+func compareVersions(a, b string) int {
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(as) || i < len(bs); i++ {
+		var x, y int
+		if i < len(as) {
+			fmt.Sscanf(as[i], "%d", &x)
+		}
+		if i < len(bs) {
+			fmt.Sscanf(bs[i], "%d", &y)
+		}
+		if x != y {
+			if x < y {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
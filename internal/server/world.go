@@ -0,0 +1,157 @@
+package server
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"minecraft-server-manager/internal/backup"
+)
+
+// ImportResult describes an installed .mcworld
+type ImportResult struct {
+	LevelName     string `json:"level_name,omitempty"`     // From levelname.txt
+	PreviousWorld string `json:"previous_world,omitempty"` // Where the replaced world was moved
+	Restarted     bool   `json:"restarted"`
+}
+
+// ExportWorld writes a server's world to w as a .mcworld. A running server's
+// saves are held while the world is copied to a spool file, as for a hot
+// backup, so a slow download holds up neither saves nor backups.
+func (m *Manager) ExportWorld(name string, w io.Writer) error {
+	m.mu.RLock()
+	cfg := m.config
+	server := m.servers[name]
+	if server != nil && !server.isRunning() {
+		server = nil
+	}
+	serverConfig := m.serverConfigLocked(name)
+	m.mu.RUnlock()
+
+	if serverConfig == nil {
+		return ErrUnknownServer
+	}
+
+	worldsDir := cfg.GetWorldsDir(name)
+	spool, err := os.CreateTemp(worldsDir, ".export-*.mcworld")
+	if err != nil {
+		return fmt.Errorf("failed to create export file: %w", err)
+	}
+	defer os.Remove(spool.Name())
+	defer spool.Close()
+
+	if err := m.spoolWorld(spool, server, worldsDir, serverConfig.WorldName); err != nil {
+		return err
+	}
+
+	if _, err := spool.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("failed to read export file: %w", err)
+	}
+	if _, err := io.Copy(w, spool); err != nil {
+		return fmt.Errorf("failed to send world: %w", err)
+	}
+	return nil
+}
+
+// spoolWorld writes a world to a .mcworld file, holding a running server's saves
+func (m *Manager) spoolWorld(spool *os.File, server *MinecraftServer, worldsDir, worldName string) error {
+	// Keep restores and imports from replacing the world mid-copy
+	m.backupMu.Lock()
+	defer m.backupMu.Unlock()
+
+	if server != nil {
+		return m.withSaveHold(server, func(files []backup.File) error {
+			return backup.WriteMCWorld(spool, worldsDir, worldName, files)
+		})
+	}
+
+	files, err := backup.WalkFiles(worldsDir, worldName)
+	if err != nil {
+		return err
+	}
+	return backup.WriteMCWorld(spool, worldsDir, worldName, files)
+}
+
+// ImportWorld installs an uploaded .mcworld as a server's world. The upload is
+// validated before anything is stopped; the replaced world is kept.
+func (m *Manager) ImportWorld(name string, upload io.Reader) (*ImportResult, error) {
+	m.mu.RLock()
+	cfg := m.config
+	known := m.serverConfigLocked(name) != nil
+	m.mu.RUnlock()
+
+	if !known {
+		return nil, ErrUnknownServer
+	}
+
+	worldsDir := cfg.GetWorldsDir(name)
+	if err := os.MkdirAll(worldsDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create worlds directory: %w", err)
+	}
+
+	// A zip needs random access, so the upload is spooled to disk first
+	spool, err := os.CreateTemp(worldsDir, ".upload-*.mcworld")
+	if err != nil {
+		return nil, fmt.Errorf("failed to store upload: %w", err)
+	}
+	defer os.Remove(spool.Name())
+	defer spool.Close()
+
+	size, err := io.Copy(spool, upload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to store upload: %w", err)
+	}
+
+	// Unpack next to the world so the final rename stays on one filesystem
+	unpackDir, err := os.MkdirTemp(worldsDir, ".import-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create import directory: %w", err)
+	}
+	defer os.RemoveAll(unpackDir)
+
+	levelName, err := backup.UnpackMCWorld(spool, size, unpackDir)
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	serverConfig := m.serverConfigLocked(name)
+	if serverConfig == nil {
+		return nil, ErrUnknownServer
+	}
+
+	m.backupMu.Lock()
+	defer m.backupMu.Unlock()
+
+	server, exists := m.servers[name]
+	running := exists && server.isRunning()
+	if running {
+		m.logger.Infof("Stopping %s to import a world", name)
+	}
+	// A crashed or stopped server's process is gone, but its entry is cleared too
+	m.stopServer(name)
+
+	previous, err := backup.InstallWorld(unpackDir, worldsDir, serverConfig.WorldName, "pre-import")
+	if err != nil {
+		err = fmt.Errorf("import into %s failed: %w", name, err)
+	} else {
+		m.logger.Infof("Imported world %q into %s as %s", levelName, name, filepath.Join(worldsDir, serverConfig.WorldName))
+	}
+
+	// Bring the server back either way; a failed import leaves the original world in place
+	if running {
+		m.startServer(serverConfig)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &ImportResult{
+		LevelName:     levelName,
+		PreviousWorld: previous,
+		Restarted:     running,
+	}, nil
+}